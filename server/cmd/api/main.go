@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/andy-dam/iq-theory/server/internal/config"
+	"github.com/andy-dam/iq-theory/server/internal/events"
 	"github.com/andy-dam/iq-theory/server/internal/handlers"
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/andy-dam/iq-theory/server/internal/repository"
 	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/pkg/auth"
 	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/andy-dam/iq-theory/server/pkg/mailer"
+	"github.com/andy-dam/iq-theory/server/pkg/oidc/oidctest"
+	"github.com/andy-dam/iq-theory/server/pkg/storage"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
 	"github.com/gorilla/mux"
 )
 
@@ -19,6 +30,15 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Configure trace export
+	exporter, err := newTraceExporter(&cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to configure tracing: %v", err)
+	}
+	defer exporter.Close()
+	tracing.SetExporter(exporter)
+	tracing.SetSampleRatio(cfg.Tracing.SampleRatio)
+
 	// Initialize database connection
 	db, err := database.New(&cfg.Database)
 	if err != nil {
@@ -31,8 +51,47 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Initialize all repositories and services
+	repos := repository.NewRepositories(db)
+	mail, err := newMailer(&cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to configure mail: %v", err)
+	}
+	store, err := newStorage(&cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to configure storage: %v", err)
+	}
+	exports, err := newExportStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure export storage: %v", err)
+	}
+	services := service.NewServices(repos, cfg, mail, store, exports)
+
+	// Deliver domain events from the outbox to in-process subscribers
+	dispatcher := events.NewDispatcher(repos.Outbox, cfg.Events)
+	services.RegisterSubscribers(dispatcher)
+	go dispatcher.Run(ctx)
+
+	// Rebuild the leaderboards after quiz results change
+	go runLeaderboardRefresh(ctx, services.Leaderboard, cfg.Leaderboard.RefreshInterval)
+
+	// Purge accounts past their deletion grace period and expired exports
+	go runAccountPurge(ctx, services.Account, services.User, cfg.Account.PurgeInterval)
+
+	// Exchange Firebase ID tokens from the web client when a project is configured
+	var firebase *auth.FirebaseVerifier
+	if cfg.Firebase.ProjectID != "" {
+		firebase, err = auth.NewFirebaseVerifier(cfg.Firebase.ProjectID, cfg.Firebase.KeyFile)
+		if err != nil {
+			log.Fatalf("Failed to configure Firebase: %v", err)
+		}
+	}
+
 	// Setup routes with all dependencies
-	router := setupRoutes(db)
+	router := setupRoutes(cfg, db, services, firebase, store)
 
 	log.Printf("Server starting on %s:%s", cfg.Server.Host, cfg.Server.Port)
 	if err := http.ListenAndServe(":"+cfg.Server.Port, router); err != nil {
@@ -41,25 +100,100 @@ func main() {
 }
 
 // setupRoutes initializes and configures all routes with their handlers
-func setupRoutes(db *database.DB) *mux.Router {
+func setupRoutes(cfg *config.Config, db *database.DB, services *service.Services, firebase *auth.FirebaseVerifier, store storage.Storage) *mux.Router {
 	r := mux.NewRouter()
 
-	// Initialize all repositories
-	repos := repository.NewRepositories(db)
+	if cfg.Server.TrustProxy {
+		r.Use(middleware.RealIP(cfg.Server.TrustedProxies))
+	}
+	r.Use(middleware.Tracing)
 
-	// Initialize all services
-	services := service.NewServices(repos)
+	tokens := auth.NewTokenManager(cfg.JWT.Secret, cfg.JWT.TTL)
+	authenticator := &middleware.Authenticator{
+		Tokens:    tokens,
+		Users:     services.User,
+		Sessions:  services.Session,
+		APITokens: services.APIToken,
+	}
 
 	// Initialize handlers
 	authHandler := &handlers.AuthHandler{
+		UserService:    services.User,
+		SessionService: services.Session,
+		MFAService:     services.MFA,
+		Tokens:         tokens,
+		Authenticator:  authenticator,
+		Firebase:       firebase,
+	}
+	oidcHandler := &handlers.OIDCHandler{
+		IdentityService: services.Identity,
+		SessionService:  services.Session,
+		MFAService:      services.MFA,
+		Tokens:          tokens,
+		AppURL:          cfg.Account.AppURL,
+	}
+	mfaHandler := &handlers.MFAHandler{
+		MFAService: services.MFA,
+	}
+	groupHandler := &handlers.GroupHandler{
+		GroupService: services.Group,
+	}
+	accountHandler := &handlers.AccountHandler{
+		AccountService: services.Account,
+	}
+	sessionHandler := &handlers.SessionHandler{
+		SessionService: services.Session,
+	}
+	apiTokenHandler := &handlers.APITokenHandler{
+		APITokenService: services.APIToken,
+	}
+	friendshipHandler := &handlers.FriendshipHandler{
+		FriendshipService: services.Friendship,
+	}
+	activityHandler := &handlers.ActivityHandler{
+		ActivityService: services.Activity,
+	}
+	bootstrapHandler := &handlers.BootstrapHandler{
+		UserService:        services.User,
+		FeatureFlagService: services.FeatureFlag,
+	}
+	permissionHandler := &handlers.PermissionHandler{
+		PermissionService: services.Permission,
+	}
+	leaderboardHandler := &handlers.LeaderboardHandler{
+		LeaderboardService: services.Leaderboard,
+	}
+	featureFlagHandler := &handlers.FeatureFlagHandler{
+		FeatureFlagService: services.FeatureFlag,
+	}
+	userHandler := &handlers.UserHandler{
 		UserService: services.User,
 	}
-	// userHandler := &handlers.UserHandler{
-	// 	UserService: services.User,
-	// }
-	// quizHandler := &handlers.QuizHandler{
-	// 	QuizService: services.Quiz,
-	// }
+	avatarHandler := &handlers.AvatarHandler{
+		AvatarService: services.Avatar,
+		MaxBytes:      cfg.Avatar.MaxBytes,
+	}
+	preferencesHandler := &handlers.PreferencesHandler{
+		PreferencesService: services.Preferences,
+	}
+	quizHandler := &handlers.QuizHandler{
+		QuizService: services.Quiz,
+	}
+
+	// Local OIDC provider for development sign-in without real credentials
+	if cfg.OIDC.FakeProvider {
+		fake, err := oidctest.NewProvider(cfg.Server.PublicURL + "/dev/oidc")
+		if err != nil {
+			log.Fatalf("Failed to start fake OIDC provider: %v", err)
+		}
+		log.Printf("Fake OIDC provider enabled at %s/dev/oidc", cfg.Server.PublicURL)
+		r.PathPrefix("/dev/oidc").Handler(http.StripPrefix("/dev/oidc", fake))
+	}
+
+	// Local storage is served by the API itself
+	if local, ok := store.(*storage.Local); ok {
+		r.PathPrefix("/media/").Handler(http.StripPrefix("/media", local.Handler())).Methods("GET", "HEAD")
+	}
 
 	// Create API subrouter with /api prefix
 	apiRouter := r.PathPrefix("/api").Subrouter()
@@ -67,6 +201,101 @@ func setupRoutes(db *database.DB) *mux.Router {
 	// Define API routes (these will be prefixed with /api)
 	apiRouter.HandleFunc("/health", healthHandler(db)).Methods("GET")
 	apiRouter.HandleFunc("/auth/register", authHandler.Register).Methods("POST")
+	apiRouter.HandleFunc("/auth/login", authHandler.Login).Methods("POST")
+	apiRouter.HandleFunc("/auth/login/mfa", authHandler.LoginMFA).Methods("POST")
+	apiRouter.HandleFunc("/auth/login/code", authHandler.LoginCode).Methods("POST")
+	if firebase != nil {
+		apiRouter.HandleFunc("/auth/firebase", authHandler.LoginFirebase).Methods("POST")
+	}
+	apiRouter.HandleFunc("/auth/guest", authHandler.CreateGuest).Methods("POST")
+	apiRouter.HandleFunc("/auth/unlock", authHandler.UnlockAccount).Methods("POST")
+	apiRouter.HandleFunc("/auth/verify-email", authHandler.VerifyEmail).Methods("POST")
+	apiRouter.HandleFunc("/auth/password-reset", authHandler.RequestPasswordReset).Methods("POST")
+	apiRouter.HandleFunc("/auth/password-reset/confirm", authHandler.ResetPassword).Methods("POST")
+	apiRouter.HandleFunc("/auth/oidc/providers", oidcHandler.Providers).Methods("GET")
+	apiRouter.HandleFunc("/auth/oidc/{provider}/login", oidcHandler.Login).Methods("GET")
+	apiRouter.HandleFunc("/auth/oidc/{provider}/callback", oidcHandler.Callback).Methods("GET")
+
+	// Routes below require a valid bearer token. Personal access tokens can
+	// only call the routes wrapped in AllowAPITokens, with the given scope.
+	protected := apiRouter.NewRoute().Subrouter()
+	protected.Use(authenticator.Authenticate)
+
+	authenticator.AllowAPITokens(models.ScopeReadProfile, protected.HandleFunc("/bootstrap", bootstrapHandler.Get).Methods("GET"))
+	protected.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST")
+	protected.HandleFunc("/auth/guest/upgrade", authHandler.UpgradeGuest).Methods("POST")
+	protected.HandleFunc("/auth/sessions", sessionHandler.List).Methods("GET")
+	protected.HandleFunc("/auth/sessions", sessionHandler.RevokeAll).Methods("DELETE")
+	protected.HandleFunc("/auth/sessions/{sessionID}", sessionHandler.Revoke).Methods("DELETE")
+	authenticator.AllowAPITokens(models.ScopeWriteQuiz, protected.HandleFunc("/quiz/sessions", quizHandler.CreateSession).Methods("POST"))
+	authenticator.AllowAPITokens(models.ScopeWriteQuiz, protected.HandleFunc("/quiz/sessions/{sessionID}/complete", quizHandler.CompleteSession).Methods("POST"))
+	authenticator.AllowAPITokens(models.ScopeReadProfile, protected.HandleFunc("/users/me/permissions", permissionHandler.Mine).Methods("GET"))
+	authenticator.AllowAPITokens(models.ScopeReadProfile, protected.HandleFunc("/users/me/preferences", preferencesHandler.Get).Methods("GET"))
+	authenticator.AllowAPITokens(models.ScopeWriteProfile, protected.HandleFunc("/users/me/preferences", preferencesHandler.Update).Methods("PATCH"))
+
+	// Routes below are not available to guests
+	registered := protected.NewRoute().Subrouter()
+	registered.Use(middleware.RequireRegistered)
+
+	registered.HandleFunc("/auth/guest/merge", authHandler.MergeGuest).Methods("POST")
+	registered.HandleFunc("/auth/change-password", authHandler.ChangePassword).Methods("POST")
+	registered.HandleFunc("/auth/verify-email/resend", authHandler.ResendVerification).Methods("POST")
+	registered.HandleFunc("/auth/oidc/{provider}/link", oidcHandler.Link).Methods("POST")
+	registered.HandleFunc("/auth/identities", oidcHandler.ListIdentities).Methods("GET")
+	registered.HandleFunc("/auth/identities/{identityID}", oidcHandler.Unlink).Methods("DELETE")
+	registered.HandleFunc("/auth/mfa", mfaHandler.Status).Methods("GET")
+	registered.HandleFunc("/auth/mfa/totp", mfaHandler.BeginTOTP).Methods("POST")
+	registered.HandleFunc("/auth/mfa/totp/confirm", mfaHandler.ConfirmTOTP).Methods("POST")
+	registered.HandleFunc("/auth/mfa/disable", mfaHandler.Disable).Methods("POST")
+	registered.HandleFunc("/auth/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes).Methods("POST")
+	authenticator.AllowAPITokens(models.ScopeWriteGroups, registered.HandleFunc("/groups", groupHandler.Create).Methods("POST"))
+	registered.HandleFunc("/groups/{groupID}/require-admin-2fa", groupHandler.SetAdminMFARequired).Methods("PUT")
+	authenticator.AllowAPITokens(models.ScopeWriteGroups, registered.HandleFunc("/groups/{groupID}/students", groupHandler.ImportRoster).Methods("POST"))
+	authenticator.AllowAPITokens(models.ScopeWriteGroups, registered.HandleFunc("/groups/{groupID}/students/{userID}/credentials", groupHandler.ResetStudentCredential).Methods("POST"))
+	authenticator.AllowAPITokens(models.ScopeReadProfile, registered.HandleFunc("/users/search", userHandler.Search).Methods("GET"))
+	authenticator.AllowAPITokens(models.ScopeReadProfile, registered.HandleFunc("/users/{userID:[0-9a-fA-F-]{36}}", userHandler.GetProfile).Methods("GET"))
+	authenticator.AllowAPITokens(models.ScopeReadQuiz, registered.HandleFunc("/users/{userID:[0-9a-fA-F-]{36}}/compare", userHandler.Compare).Methods("GET"))
+	registered.HandleFunc("/users/me", accountHandler.ScheduleDeletion).Methods("DELETE")
+	authenticator.AllowAPITokens(models.ScopeWriteProfile, registered.HandleFunc("/users/me/avatar", avatarHandler.Upload).Methods("POST"))
+	authenticator.AllowAPITokens(models.ScopeWriteProfile, registered.HandleFunc("/users/me/avatar", avatarHandler.Delete).Methods("DELETE"))
+	registered.HandleFunc("/users/me/deletion/cancel", accountHandler.CancelDeletion).Methods("POST")
+	authenticator.AllowAPITokens(models.ScopeReadExports, registered.HandleFunc("/users/me/exports", accountHandler.ListExports).Methods("GET"))
+	authenticator.AllowAPITokens(models.ScopeWriteExports, registered.HandleFunc("/users/me/exports", accountHandler.RequestExport).Methods("POST"))
+	authenticator.AllowAPITokens(models.ScopeReadExports, registered.HandleFunc("/users/me/exports/{exportID}/download", accountHandler.DownloadExport).Methods("GET"))
+	registered.HandleFunc("/users/me/api-tokens", apiTokenHandler.List).Methods("GET")
+	registered.HandleFunc("/users/me/api-tokens", apiTokenHandler.Create).Methods("POST")
+	registered.HandleFunc("/users/me/api-tokens/{tokenID}", apiTokenHandler.Revoke).Methods("DELETE")
+	registered.HandleFunc("/friends", friendshipHandler.List).Methods("GET")
+	registered.HandleFunc("/friends/requests", friendshipHandler.Incoming).Methods("GET")
+	registered.HandleFunc("/friends/requests/outgoing", friendshipHandler.Outgoing).Methods("GET")
+	registered.HandleFunc("/friends/request", friendshipHandler.SendRequest).Methods("POST")
+	registered.HandleFunc("/friends/activity", activityHandler.Feed).Methods("GET")
+	registered.HandleFunc("/friends/suggestions", friendshipHandler.Suggestions).Methods("GET")
+	registered.HandleFunc("/friends/blocked", friendshipHandler.Blocked).Methods("GET")
+	registered.HandleFunc("/friends/blocked/{userID}", friendshipHandler.Block).Methods("PUT")
+	registered.HandleFunc("/friends/blocked/{userID}", friendshipHandler.Unblock).Methods("DELETE")
+	registered.HandleFunc("/friends/{friendshipID}/accept", friendshipHandler.Accept).Methods("PUT")
+	registered.HandleFunc("/friends/{friendshipID}/decline", friendshipHandler.Decline).Methods("PUT")
+	registered.HandleFunc("/friends/{friendshipID}", friendshipHandler.Remove).Methods("DELETE")
+	authenticator.AllowAPITokens(models.ScopeReadQuiz, registered.HandleFunc("/leaderboard/friends", leaderboardHandler.Friends).Methods("GET"))
+
+	// Routes below require a site permission
+	refresh := protected.PathPrefix("/leaderboard/refresh").Subrouter()
+	refresh.Use(middleware.RequirePermission(services.Permission, models.PermissionRefreshLeaderboards))
+	refresh.HandleFunc("", leaderboardHandler.Refresh).Methods("POST")
+
+	adminUsers := protected.PathPrefix("/admin/users").Subrouter()
+	adminUsers.Use(middleware.RequirePermission(services.Permission, models.PermissionManageUsers))
+	adminUsers.HandleFunc("", permissionHandler.ListByRole).Methods("GET")
+	adminUsers.HandleFunc("/{userID}/role", permissionHandler.SetRole).Methods("PUT")
+	adminUsers.HandleFunc("/{userID}/lockout", authHandler.ClearLockout).Methods("DELETE")
+
+	adminFlags := protected.PathPrefix("/admin/feature-flags").Subrouter()
+	adminFlags.Use(middleware.RequirePermission(services.Permission, models.PermissionManageFeatureFlags))
+	adminFlags.HandleFunc("", featureFlagHandler.List).Methods("GET")
+	adminFlags.HandleFunc("", featureFlagHandler.Create).Methods("POST")
+	adminFlags.HandleFunc("/{key}", featureFlagHandler.Update).Methods("PUT")
+	adminFlags.HandleFunc("/{key}", featureFlagHandler.Delete).Methods("DELETE")
 
 	return r
 }
@@ -83,3 +312,123 @@ func healthHandler(db *database.DB) http.HandlerFunc {
 		w.Write([]byte("OK"))
 	}
 }
+
+// runAccountPurge periodically purges deleted accounts, expired data exports,
+// stale sign-in lockout counters and unused guests until ctx is cancelled
+func runAccountPurge(ctx context.Context, account service.AccountService, users service.UserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := account.PurgeDeletedAccounts(ctx); err != nil {
+			log.Printf("Account purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d deleted accounts", n)
+		}
+		if _, err := account.PurgeExpiredExports(ctx); err != nil {
+			log.Printf("Export cleanup failed: %v", err)
+		}
+		if _, err := users.PurgeLoginThrottles(ctx); err != nil {
+			log.Printf("Login throttle cleanup failed: %v", err)
+		}
+		if n, err := users.PurgeStaleGuests(ctx); err != nil {
+			log.Printf("Guest cleanup failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Deleted %d unused guest accounts", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runLeaderboardRefresh refreshes the leaderboards once per interval if
+// quiz results changed, until ctx is cancelled
+func runLeaderboardRefresh(ctx context.Context, leaderboards service.LeaderboardService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := leaderboards.RefreshIfStale(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Leaderboard refresh failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newTraceExporter builds the span exporter selected in the configuration
+func newTraceExporter(cfg *config.TracingConfig) (tracing.Exporter, error) {
+	switch cfg.Exporter {
+	case "", "none":
+		return tracing.NoopExporter{}, nil
+	case "stdout":
+		return tracing.NewStdoutExporter(), nil
+	case "file":
+		return tracing.NewFileExporter(cfg.FilePath)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+}
+
+// newMailer builds the mail transport selected in the configuration
+func newMailer(cfg *config.MailConfig) (mailer.Mailer, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return mailer.LogMailer{}, nil
+	case "file":
+		return mailer.NewFileMailer(cfg.OutboxDir, cfg.From)
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// newStorage builds the file storage selected in the configuration
+func newStorage(cfg *config.StorageConfig) (storage.Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return storage.NewLocal(cfg.LocalDir, cfg.PublicURL)
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKeyID,
+			SecretKey: cfg.S3SecretAccessKey,
+			PathStyle: cfg.S3PathStyle,
+			PublicURL: cfg.PublicURL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// newExportStorage builds the private storage for data export archives. It
+// uses the same driver as uploads, but local exports are kept outside the
+// served directory and S3 exports may go to their own bucket.
+func newExportStorage(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.Storage.Driver {
+	case "", "local":
+		return storage.NewLocal(cfg.Account.ExportDir, "")
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.Storage.S3Endpoint,
+			Region:    cfg.Storage.S3Region,
+			Bucket:    cfg.Storage.S3ExportBucket,
+			AccessKey: cfg.Storage.S3AccessKeyID,
+			SecretKey: cfg.Storage.S3SecretAccessKey,
+			PathStyle: cfg.Storage.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
@@ -4,10 +4,9 @@ go 1.25.0
 
 require github.com/gorilla/mux v1.8.1
 
-require github.com/google/uuid v1.6.0
-
 require (
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	golang.org/x/crypto v0.41.0 // indirect
+	github.com/google/uuid v1.6.0
+	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.41.0
 )
@@ -1,21 +1,57 @@
 package config
 
 import (
+	"fmt"
+	"net/netip"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	Sessions    SessionConfig
+	Firebase    FirebaseConfig
+	OIDC        OIDCConfig
+	Account     AccountConfig
+	MFA         MFAConfig
+	Password    PasswordConfig
+	Lockout     LockoutConfig
+	Mail        MailConfig
+	Storage     StorageConfig
+	Avatar      AvatarConfig
+	Events      EventsConfig
+	Leaderboard LeaderboardConfig
+	Admin       AdminConfig
+	Features    FeaturesConfig
+	Tracing     TracingConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
+	// Environment is "development" on a developer's machine. Anywhere else
+	// the placeholder JWT secret is refused.
+	Environment string
+	Port        string
+	Host        string
+	// PublicURL is the externally reachable base URL of this API, used to
+	// build OAuth redirect URLs
+	PublicURL string
+	// TrustProxy takes client IPs from X-Forwarded-For / X-Real-IP set by
+	// TrustedProxies. Enable it only behind a reverse proxy that sets those
+	// headers.
+	TrustProxy bool
+	// TrustedProxies are the addresses of the reverse proxies in front of
+	// the API. The client IP is the right-most forwarded address outside
+	// them.
+	TrustedProxies []netip.Prefix
 }
 
 type DatabaseConfig struct {
@@ -25,20 +61,261 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// Connection pool settings
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// QueryTimeout bounds every query that goes through database.DB.
+	// Zero disables the default timeout.
+	QueryTimeout time.Duration
+	// SlowQueryThreshold is the duration above which queries are logged.
+	// Zero disables slow-query logging.
+	SlowQueryThreshold time.Duration
+
+	// Read replicas share the primary's credentials and database name.
+	// Each entry is a host or host:port.
+	ReplicaHosts []string
+	// ReplicaHealthInterval is how often replicas are pinged.
+	ReplicaHealthInterval time.Duration
+	// ReadYourWritesWindow pins a user's reads to the primary for this long
+	// after they write, so they never see replica lag on their own changes.
+	ReadYourWritesWindow time.Duration
+}
+
+// placeholderJWTSecrets are the JWT secrets shipped as defaults and
+// examples, which anyone can use to forge tokens
+var placeholderJWTSecrets = []string{
+	"your-secret-key-change-this",
+	"your-super-secret-jwt-key-change-this-in-production",
 }
 
 type JWTConfig struct {
 	Secret string
+	TTL    time.Duration
+}
+
+// SessionConfig controls login session tracking
+type SessionConfig struct {
+	// TouchInterval is how often a session's last-seen time is updated
+	TouchInterval time.Duration
+}
+
+// FirebaseConfig enables Firebase ID tokens as API credentials
+type FirebaseConfig struct {
+	// ProjectID is the Firebase project whose tokens are accepted. Empty
+	// disables Firebase sign-in.
+	ProjectID string
+	// KeyFile holds the token signing keys, either Google's certificate map
+	// or a JWKS document. It is re-read when it changes.
+	KeyFile string
+}
+
+// OIDCConfig lists the OpenID Connect providers users can sign in with
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+	// StateTTL is how long a user has to finish signing in at the provider
+	StateTTL time.Duration
+	// FakeProvider mounts a local provider at /dev/oidc for development and
+	// tests. Never enable it in production.
+	FakeProvider bool
+}
+
+// OIDCProviderConfig configures one OpenID Connect provider
+type OIDCProviderConfig struct {
+	// Name appears in the login URL, e.g. /api/auth/oidc/google/login
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// AccountConfig controls account lifecycle emails
+type AccountConfig struct {
+	// AppURL is the public URL of the web client, used to build links in emails
+	AppURL string
+	// VerificationTokenTTL is how long an email verification link stays valid
+	VerificationTokenTTL time.Duration
+	// VerificationResendInterval is the minimum time between verification emails
+	VerificationResendInterval time.Duration
+	// PasswordResetTokenTTL is how long a password reset link stays valid
+	PasswordResetTokenTTL time.Duration
+	// PasswordResetResendInterval is the minimum time between reset emails
+	PasswordResetResendInterval time.Duration
+	// ExportDir is where the local storage driver keeps personal data export
+	// archives. It is never served by the API.
+	ExportDir string
+	// ExportTTL is how long a finished export can be downloaded
+	ExportTTL time.Duration
+	// ExportInterval is the minimum time between a user's exports
+	ExportInterval time.Duration
+	// DeletionGracePeriod is how long a deleted account can still be restored
+	// before it is purged
+	DeletionGracePeriod time.Duration
+	// PurgeInterval is how often due deletions and expired exports are processed
+	PurgeInterval time.Duration
+	// GuestRetention is how long an unused guest account is kept
+	GuestRetention time.Duration
+	// GuestsPerIP is how many guest accounts a client IP can create in
+	// GuestWindow. Zero disables the limit.
+	GuestsPerIP int
+	GuestWindow time.Duration
+}
+
+// PasswordConfig is the policy for new passwords and how they are hashed
+type PasswordConfig struct {
+	MinLength int
+	// BlockCommon rejects passwords on the built-in common passwords list
+	BlockCommon bool
+	// RejectUsername rejects passwords that contain the username
+	RejectUsername bool
+	// BcryptCost is the hashing cost. Raising it rehashes passwords as
+	// users sign in.
+	BcryptCost int
+}
+
+// LockoutConfig controls brute-force protection on password sign-in
+type LockoutConfig struct {
+	// AccountThreshold is how many failures lock an email address
+	AccountThreshold int
+	// IPThreshold is how many failures lock a client IP. Keep it well above
+	// AccountThreshold, since a whole classroom may share one address.
+	IPThreshold int
+	// Duration is the first lockout; each further lockout doubles it
+	Duration time.Duration
+	// MaxDuration caps the lockout
+	MaxDuration time.Duration
+	// ResetAfter forgets failures and past lockouts after this long without
+	// a failure
+	ResetAfter time.Duration
+}
+
+// MFAConfig controls two-factor authentication
+type MFAConfig struct {
+	// Issuer names the account in authenticator apps
+	Issuer string
+	// EncryptionKey encrypts TOTP secrets at rest. It defaults to the JWT
+	// secret, so rotating the JWT secret without setting it invalidates
+	// existing enrolments, as does changing it.
+	EncryptionKey string
+	// ChallengeTTL is how long a user has to enter their code after the
+	// password step
+	ChallengeTTL time.Duration
+	// MaxAttempts is how many wrong codes a login challenge accepts, and
+	// how many in a row lock a user out of disabling 2FA or regenerating
+	// recovery codes
+	MaxAttempts int
+	// LockoutDuration is how long that lockout lasts
+	LockoutDuration time.Duration
+}
+
+// MailConfig selects and configures the outgoing mail transport
+type MailConfig struct {
+	// Driver is one of smtp, file or log
+	Driver string
+	From   string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// OutboxDir is where the file driver writes .eml files
+	OutboxDir string
+}
+
+// StorageConfig selects and configures where uploaded files are stored
+type StorageConfig struct {
+	// Driver is one of local or s3
+	Driver string
+	// PublicURL is where stored files are served from. For the local driver
+	// it defaults to the API's /media path, for s3 to the bucket's address.
+	PublicURL string
+
+	// LocalDir is where the local driver writes files
+	LocalDir string
+
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	// S3PathStyle addresses the bucket as a path rather than a subdomain
+	S3PathStyle bool
+	// S3ExportBucket holds personal data exports and must not be publicly
+	// readable. It defaults to S3Bucket, whose policy must then only make
+	// the avatars/ prefix public.
+	S3ExportBucket string
+}
+
+// AvatarConfig limits avatar uploads
+type AvatarConfig struct {
+	// MaxBytes is the largest accepted upload
+	MaxBytes int64
+	// MaxPixels is the largest accepted width × height, which bounds the
+	// memory needed to decode an upload
+	MaxPixels int
+}
+
+// AdminConfig lists the users allowed to use the admin endpoints
+type AdminConfig struct {
+	UserIDs []uuid.UUID
+}
+
+// TracingConfig controls span export
+type TracingConfig struct {
+	// Exporter is one of none, stdout or file
+	Exporter string
+	// FilePath is where the file exporter appends JSON-lines spans
+	FilePath string
+	// SampleRatio is the fraction (0-1) of new traces that are recorded
+	SampleRatio float64
+}
+
+// FeaturesConfig controls the feature flag store
+type FeaturesConfig struct {
+	// CacheTTL is how long flags are served from memory before reloading
+	CacheTTL time.Duration
+}
+
+// EventsConfig controls delivery of domain events from the outbox
+type EventsConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Lease is how long a claimed event is hidden from other dispatchers
+	Lease time.Duration
+	// MaxAttempts is how many deliveries are tried before an event is failed
+	MaxAttempts  int
+	RetryBackoff time.Duration
+}
+
+// LeaderboardConfig controls how often the leaderboards view is rebuilt
+type LeaderboardConfig struct {
+	// RefreshInterval is how often the view is refreshed when quiz results
+	// have changed, so a burst of completions costs one refresh
+	RefreshInterval time.Duration
 }
 
 func Load() (*Config, error) {
 	// Load .env file if it exists (optional)
 	godotenv.Load()
 
+	trustedProxies, err := getEnvAsPrefixes("SERVER_TRUSTED_PROXIES", privateNetworks)
+	if err != nil {
+		return nil, err
+	}
+
 	config := &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "localhost"),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			Host:           getEnv("SERVER_HOST", "localhost"),
+			PublicURL:      strings.TrimSuffix(getEnv("API_URL", "http://localhost:8080"), "/"),
+			TrustProxy:     getEnvAsBool("SERVER_TRUST_PROXY", false),
+			TrustedProxies: trustedProxies,
+			Environment:    getEnv("APP_ENV", "production"),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -47,15 +324,177 @@ func Load() (*Config, error) {
 			Password: getEnv("DB_PASSWORD", ""),
 			DBName:   getEnv("DB_NAME", "iq-theory"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+			MaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 25),
+			ConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			ConnMaxIdleTime: getEnvAsDuration("DB_CONN_MAX_IDLE_TIME", 0),
+
+			QueryTimeout:       getEnvAsDuration("DB_QUERY_TIMEOUT", 10*time.Second),
+			SlowQueryThreshold: getEnvAsDuration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+
+			ReplicaHosts:          getEnvAsSlice("DB_REPLICA_HOSTS", nil),
+			ReplicaHealthInterval: getEnvAsDuration("DB_REPLICA_HEALTH_INTERVAL", 10*time.Second),
+			ReadYourWritesWindow:  getEnvAsDuration("DB_READ_YOUR_WRITES_WINDOW", 5*time.Second),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key-change-this"),
+			Secret: getEnv("JWT_SECRET", placeholderJWTSecrets[0]),
+			TTL:    getEnvAsDuration("JWT_TTL", 24*time.Hour),
+		},
+		Sessions: SessionConfig{
+			TouchInterval: getEnvAsDuration("SESSION_TOUCH_INTERVAL", 5*time.Minute),
+		},
+		Firebase: FirebaseConfig{
+			ProjectID: getEnv("FIREBASE_PROJECT_ID", ""),
+			KeyFile:   getEnv("FIREBASE_KEY_FILE", "firebase-certs.json"),
+		},
+		OIDC: OIDCConfig{
+			StateTTL:     getEnvAsDuration("OIDC_STATE_TTL", 10*time.Minute),
+			FakeProvider: getEnvAsBool("OIDC_FAKE_PROVIDER", false),
+		},
+		Account: AccountConfig{
+			AppURL:                      getEnv("APP_URL", "http://localhost:5173"),
+			VerificationTokenTTL:        getEnvAsDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			VerificationResendInterval:  getEnvAsDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute),
+			PasswordResetTokenTTL:       getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour),
+			PasswordResetResendInterval: getEnvAsDuration("PASSWORD_RESET_RESEND_INTERVAL", time.Minute),
+			ExportDir:                   getEnv("ACCOUNT_EXPORT_DIR", "exports"),
+			ExportTTL:                   getEnvAsDuration("ACCOUNT_EXPORT_TTL", 7*24*time.Hour),
+			ExportInterval:              getEnvAsDuration("ACCOUNT_EXPORT_INTERVAL", 24*time.Hour),
+			DeletionGracePeriod:         getEnvAsDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+			PurgeInterval:               getEnvAsDuration("ACCOUNT_PURGE_INTERVAL", time.Hour),
+			GuestRetention:              getEnvAsDuration("ACCOUNT_GUEST_RETENTION", 30*24*time.Hour),
+			GuestsPerIP:                 getEnvAsInt("ACCOUNT_GUESTS_PER_IP", 60),
+			GuestWindow:                 getEnvAsDuration("ACCOUNT_GUEST_WINDOW", time.Hour),
+		},
+		Password: PasswordConfig{
+			MinLength:      getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+			BlockCommon:    getEnvAsBool("PASSWORD_BLOCK_COMMON", true),
+			RejectUsername: getEnvAsBool("PASSWORD_REJECT_USERNAME", true),
+			BcryptCost:     getEnvAsInt("PASSWORD_BCRYPT_COST", 10),
+		},
+		Lockout: LockoutConfig{
+			AccountThreshold: getEnvAsInt("LOGIN_LOCKOUT_ACCOUNT_THRESHOLD", 5),
+			IPThreshold:      getEnvAsInt("LOGIN_LOCKOUT_IP_THRESHOLD", 50),
+			Duration:         getEnvAsDuration("LOGIN_LOCKOUT_DURATION", time.Minute),
+			MaxDuration:      getEnvAsDuration("LOGIN_LOCKOUT_MAX_DURATION", time.Hour),
+			ResetAfter:       getEnvAsDuration("LOGIN_LOCKOUT_RESET_AFTER", 24*time.Hour),
+		},
+		MFA: MFAConfig{
+			Issuer:          getEnv("MFA_ISSUER", "IQ Theory"),
+			EncryptionKey:   getEnv("MFA_ENCRYPTION_KEY", ""),
+			ChallengeTTL:    getEnvAsDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+			MaxAttempts:     getEnvAsInt("MFA_MAX_ATTEMPTS", 5),
+			LockoutDuration: getEnvAsDuration("MFA_LOCKOUT_DURATION", 15*time.Minute),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "log"),
+			From:         getEnv("MAIL_FROM", "IQ Theory <no-reply@localhost>"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			OutboxDir:    getEnv("MAIL_OUTBOX_DIR", "mail-outbox"),
+		},
+		Storage: StorageConfig{
+			Driver:            getEnv("STORAGE_DRIVER", "local"),
+			PublicURL:         getEnv("STORAGE_PUBLIC_URL", ""),
+			LocalDir:          getEnv("STORAGE_LOCAL_DIR", "uploads"),
+			S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+			S3Region:          getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:          getEnv("S3_BUCKET", ""),
+			S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+			S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+			S3PathStyle:       getEnvAsBool("S3_PATH_STYLE", false),
+			S3ExportBucket:    getEnv("S3_EXPORT_BUCKET", getEnv("S3_BUCKET", "")),
+		},
+		Avatar: AvatarConfig{
+			MaxBytes:  int64(getEnvAsInt("AVATAR_MAX_BYTES", 5<<20)),
+			MaxPixels: getEnvAsInt("AVATAR_MAX_PIXELS", 16_000_000),
+		},
+		Features: FeaturesConfig{
+			CacheTTL: getEnvAsDuration("FEATURE_FLAG_CACHE_TTL", 30*time.Second),
 		},
+		Tracing: TracingConfig{
+			Exporter:    getEnv("TRACING_EXPORTER", "none"),
+			FilePath:    getEnv("TRACING_FILE", "traces.jsonl"),
+			SampleRatio: getEnvAsFloat("TRACING_SAMPLE_RATIO", 1.0),
+		},
+		Events: EventsConfig{
+			PollInterval: getEnvAsDuration("EVENTS_POLL_INTERVAL", time.Second),
+			BatchSize:    getEnvAsInt("EVENTS_BATCH_SIZE", 50),
+			Lease:        getEnvAsDuration("EVENTS_LEASE", 30*time.Second),
+			MaxAttempts:  getEnvAsInt("EVENTS_MAX_ATTEMPTS", 10),
+			RetryBackoff: getEnvAsDuration("EVENTS_RETRY_BACKOFF", 5*time.Second),
+		},
+		Leaderboard: LeaderboardConfig{
+			RefreshInterval: getEnvAsDuration("LEADERBOARD_REFRESH_INTERVAL", 10*time.Second),
+		},
+	}
+
+	if config.Password.BcryptCost < bcrypt.MinCost || config.Password.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("PASSWORD_BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	// The JWT secret signs every access token and is the default TOTP
+	// encryption key
+	if config.Server.Environment != "development" && slices.Contains(placeholderJWTSecrets, config.JWT.Secret) {
+		return nil, fmt.Errorf("JWT_SECRET must be set to a random value unless APP_ENV=development")
+	}
+
+	if config.MFA.EncryptionKey == "" {
+		config.MFA.EncryptionKey = config.JWT.Secret
+	}
+
+	if config.Storage.Driver == "local" && config.Storage.PublicURL == "" {
+		config.Storage.PublicURL = config.Server.PublicURL + "/media"
+	}
+
+	adminIDs, err := getEnvAsUUIDs("ADMIN_USER_IDS")
+	if err != nil {
+		return nil, err
+	}
+	config.Admin.UserIDs = adminIDs
+
+	providers, err := loadOIDCProviders(config.Server.PublicURL, config.OIDC.FakeProvider)
+	if err != nil {
+		return nil, err
 	}
+	config.OIDC.Providers = providers
 
 	return config, nil
 }
 
+// loadOIDCProviders reads OIDC_PROVIDERS and the OIDC_<NAME>_* settings of
+// each listed provider
+func loadOIDCProviders(publicURL string, fake bool) ([]OIDCProviderConfig, error) {
+	var providers []OIDCProviderConfig
+	for _, name := range getEnvAsSlice("OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       getEnvAsSlice(prefix+"SCOPES", nil),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %q needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		providers = append(providers, provider)
+	}
+
+	if fake {
+		providers = append(providers, OIDCProviderConfig{
+			Name:     "fake",
+			Issuer:   publicURL + "/dev/oidc",
+			ClientID: "iq-theory-dev",
+		})
+	}
+	return providers, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -71,3 +510,83 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// privateNetworks are the loopback and private ranges a reverse proxy in
+// the same deployment connects from
+var privateNetworks = []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7"}
+
+// getEnvAsPrefixes reads a list of CIDR ranges, taking a bare address as a
+// range of one
+func getEnvAsPrefixes(key string, defaultValue []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range getEnvAsSlice(key, defaultValue) {
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q in %s: %w", value, key, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q in %s: %w", value, key, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func getEnvAsUUIDs(key string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for _, value := range getEnvAsSlice(key, nil) {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UUID %q in %s: %w", value, key, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestLoadPlaceholderJWTSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		secret  string
+		wantErr bool
+	}{
+		{"development with the example secret", "development", placeholderJWTSecrets[1], false},
+		{"development with the default secret", "development", "", false},
+		{"production with the example secret", "production", placeholderJWTSecrets[1], true},
+		{"unset environment with the default secret", "", "", true},
+		{"production with a random secret", "production", "0c1f5e0a9d6b4b8e8a7f2d3c4b5a6978", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.env)
+			t.Setenv("JWT_SECRET", tt.secret)
+
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.env != "" && cfg.Server.Environment != tt.env {
+				t.Errorf("Environment = %q, want %q", cfg.Server.Environment, tt.env)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/config"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+)
+
+// maxRetryBackoff caps the exponential delay between delivery attempts
+const maxRetryBackoff = time.Hour
+
+// Handler processes a delivered event. Delivery is at-least-once: an event
+// is redelivered to every subscriber of its type until all of them succeed,
+// so handlers must be idempotent.
+type Handler func(ctx context.Context, envelope Envelope) error
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+// Dispatcher polls the outbox and delivers events to in-process subscribers
+type Dispatcher struct {
+	outboxRepo repository.OutboxRepository
+	cfg        config.EventsConfig
+
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+}
+
+// NewDispatcher creates a dispatcher for the outbox
+func NewDispatcher(outboxRepo repository.OutboxRepository, cfg config.EventsConfig) *Dispatcher {
+	return &Dispatcher{
+		outboxRepo:  outboxRepo,
+		cfg:         cfg,
+		subscribers: make(map[string][]subscriber),
+	}
+}
+
+// Subscribe registers a named handler for an event type
+func (d *Dispatcher) Subscribe(eventType, name string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.subscribers[eventType] = append(d.subscribers[eventType], subscriber{name: name, handler: handler})
+}
+
+// Run delivers pending events until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches back to back, then wait for the next tick
+		for {
+			n, err := d.dispatchBatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Event dispatcher failed to claim events: %v", err)
+			}
+			if n < d.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchBatch claims and delivers one batch, returning how many events
+// were claimed
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	pending, err := d.outboxRepo.ClaimPending(ctx, d.cfg.BatchSize, d.cfg.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range pending {
+		d.deliver(ctx, event)
+	}
+	return len(pending), nil
+}
+
+// deliver hands one event to all its subscribers and records the outcome
+func (d *Dispatcher) deliver(ctx context.Context, event *models.OutboxEvent) {
+	envelope := Envelope{
+		ID:          event.ID,
+		Type:        event.EventType,
+		AggregateID: event.AggregateID,
+		Payload:     event.Payload,
+		OccurredAt:  event.OccurredAt,
+		Attempt:     event.Attempts,
+	}
+
+	d.mu.RLock()
+	subscribers := d.subscribers[event.EventType]
+	d.mu.RUnlock()
+
+	var failures []string
+	for _, sub := range subscribers {
+		if err := invoke(ctx, sub, envelope); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", sub.name, err))
+		}
+	}
+
+	if len(failures) == 0 {
+		if err := d.outboxRepo.MarkDelivered(ctx, event.ID); err != nil {
+			log.Printf("Failed to mark event %s delivered: %v", event.ID, err)
+		}
+		return
+	}
+
+	lastError := strings.Join(failures, "; ")
+	var retryAt *time.Time
+	if event.Attempts < d.cfg.MaxAttempts {
+		next := time.Now().Add(d.backoff(event.Attempts))
+		retryAt = &next
+	} else {
+		log.Printf("Giving up on %s event %s after %d attempts: %s", event.EventType, event.ID, event.Attempts, lastError)
+	}
+
+	if err := d.outboxRepo.MarkFailed(ctx, event.ID, lastError, retryAt); err != nil {
+		log.Printf("Failed to record delivery failure for event %s: %v", event.ID, err)
+	}
+}
+
+// backoff returns the delay before the attempt after the given one
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.RetryBackoff
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryBackoff)
+}
+
+// invoke calls a subscriber, converting panics into errors
+func invoke(ctx context.Context, sub subscriber, envelope Envelope) (err error) {
+	ctx, span := tracing.Start(ctx, "Event "+envelope.Type+" -> "+sub.name)
+	span.SetAttribute("event.id", envelope.ID.String())
+	span.SetAttribute("event.attempt", envelope.Attempt)
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.New(fmt.Sprint("panic: ", p))
+		}
+		span.RecordError(err)
+		span.End()
+	}()
+	return sub.handler(ctx, envelope)
+}
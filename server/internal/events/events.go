@@ -0,0 +1,102 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	TypeQuizCompleted     = "quiz.completed"
+	TypeFriendRequestSent = "friendship.request_sent"
+	TypeMemberJoinedGroup = "group.member_joined"
+	TypeExportRequested   = "account.export_requested"
+	TypeGuestUpgraded     = "account.guest_upgraded"
+)
+
+// Event is a domain event that can be written to the outbox
+type Event interface {
+	// EventType is the stable name subscribers register for
+	EventType() string
+	// AggregateID identifies the entity the event is about
+	AggregateID() uuid.UUID
+}
+
+// QuizCompleted is published when a quiz session is completed
+type QuizCompleted struct {
+	SessionID          uuid.UUID `json:"session_id"`
+	UserID             uuid.UUID `json:"user_id"`
+	Clef               string    `json:"clef"`
+	DurationSeconds    int       `json:"duration_seconds"`
+	MaxLedgerLines     int       `json:"max_ledger_lines"`
+	Score              int       `json:"score"`
+	CorrectAnswers     int       `json:"correct_answers"`
+	TotalQuestions     int       `json:"total_questions"`
+	AccuracyPercentage float64   `json:"accuracy_percentage"`
+	TimeTakenSeconds   int       `json:"time_taken_seconds"`
+	CompletedAt        time.Time `json:"completed_at"`
+}
+
+func (e QuizCompleted) EventType() string      { return TypeQuizCompleted }
+func (e QuizCompleted) AggregateID() uuid.UUID { return e.SessionID }
+
+// FriendRequestSent is published when a user sends a friend request
+type FriendRequestSent struct {
+	FriendshipID uuid.UUID `json:"friendship_id"`
+	RequesterID  uuid.UUID `json:"requester_id"`
+	AddresseeID  uuid.UUID `json:"addressee_id"`
+}
+
+func (e FriendRequestSent) EventType() string      { return TypeFriendRequestSent }
+func (e FriendRequestSent) AggregateID() uuid.UUID { return e.FriendshipID }
+
+// MemberJoinedGroup is published when a user joins a group
+type MemberJoinedGroup struct {
+	MembershipID uuid.UUID `json:"membership_id"`
+	GroupID      uuid.UUID `json:"group_id"`
+	UserID       uuid.UUID `json:"user_id"`
+	Role         string    `json:"role"`
+	JoinedAt     time.Time `json:"joined_at"`
+}
+
+func (e MemberJoinedGroup) EventType() string      { return TypeMemberJoinedGroup }
+func (e MemberJoinedGroup) AggregateID() uuid.UUID { return e.GroupID }
+
+// ExportRequested is published when a user asks for a copy of their data
+type ExportRequested struct {
+	ExportID uuid.UUID `json:"export_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+func (e ExportRequested) EventType() string      { return TypeExportRequested }
+func (e ExportRequested) AggregateID() uuid.UUID { return e.ExportID }
+
+// GuestUpgraded is published when a guest's quiz history joins a registered
+// account, either by signing up or by merging into an existing account.
+// UserID equals GuestID for sign-ups; merged guests no longer exist.
+type GuestUpgraded struct {
+	GuestID        uuid.UUID `json:"guest_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	MergedSessions int       `json:"merged_sessions"`
+}
+
+func (e GuestUpgraded) EventType() string      { return TypeGuestUpgraded }
+func (e GuestUpgraded) AggregateID() uuid.UUID { return e.UserID }
+
+// Envelope is an event as delivered to subscribers
+type Envelope struct {
+	ID          uuid.UUID
+	Type        string
+	AggregateID uuid.UUID
+	Payload     json.RawMessage
+	OccurredAt  time.Time
+	// Attempt is 1 on first delivery and increases with every retry
+	Attempt int
+}
+
+// Decode unmarshals the payload into one of the event structs
+func (e Envelope) Decode(event Event) error {
+	return json.Unmarshal(e.Payload, event)
+}
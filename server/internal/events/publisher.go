@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Publisher records domain events for later delivery
+type Publisher interface {
+	// Publish writes the event to the outbox. Call it with the transactional
+	// context of the change the event describes so both commit together.
+	Publish(ctx context.Context, event Event) error
+}
+
+// outboxPublisher implements Publisher on top of the outbox table
+type outboxPublisher struct {
+	outboxRepo repository.OutboxRepository
+}
+
+// NewPublisher creates a publisher that writes to the transactional outbox
+func NewPublisher(outboxRepo repository.OutboxRepository) Publisher {
+	return &outboxPublisher{outboxRepo: outboxRepo}
+}
+
+// Publish serializes the event and stores it in the outbox
+func (p *outboxPublisher) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.EventType(), err)
+	}
+
+	now := time.Now()
+	outboxEvent := &models.OutboxEvent{
+		ID:            uuid.New(),
+		EventType:     event.EventType(),
+		AggregateID:   event.AggregateID(),
+		Payload:       payload,
+		OccurredAt:    now,
+		Status:        "pending",
+		NextAttemptAt: now,
+	}
+
+	if err := p.outboxRepo.Create(ctx, outboxEvent); err != nil {
+		return fmt.Errorf("failed to write %s event to outbox: %w", event.EventType(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// AccountHandler lets users export their data and delete their account
+type AccountHandler struct {
+	AccountService service.AccountService
+}
+
+func (ah *AccountHandler) RequestExport(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	export, err := ah.AccountService.RequestExport(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, export)
+}
+
+func (ah *AccountHandler) ListExports(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	exports, err := ah.AccountService.ListExports(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, exports)
+}
+
+func (ah *AccountHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	exportID, err := uuid.Parse(mux.Vars(r)["exportID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid export ID")
+		return
+	}
+
+	export, archive, err := ah.AccountService.OpenExport(r.Context(), userID, exportID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("iq-theory-export-%s.zip", export.CreatedAt.UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, filename, *export.CompletedAt, bytes.NewReader(archive))
+}
+
+func (ah *AccountHandler) ScheduleDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req models.DeleteAccountRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	}
+
+	scheduledAt, err := ah.AccountService.ScheduleDeletion(r.Context(), userID, req.Password)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, &models.AccountDeletionResponse{DeletionScheduledAt: scheduledAt})
+}
+
+func (ah *AccountHandler) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	if err := ah.AccountService.CancelDeletion(r.Context(), userID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+)
+
+// ActivityHandler serves the friend activity feed
+type ActivityHandler struct {
+	ActivityService service.ActivityService
+}
+
+// Feed returns a page of the current user's friends' activity. Takes an
+// optional cursor from the previous page and limit.
+func (ah *ActivityHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	feed, err := ah.ActivityService.GetFeed(r.Context(), userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, feed)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// APITokenHandler lets users manage their personal access tokens
+type APITokenHandler struct {
+	APITokenService service.APITokenService
+}
+
+// List returns the current user's tokens, without the secrets
+func (th *APITokenHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	tokens, err := th.APITokenService.ListTokens(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tokens)
+}
+
+// Create issues a token. The response is the only time it can be read.
+func (th *APITokenHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req models.CreateAPITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	token, err := th.APITokenService.CreateToken(r.Context(), userID, &req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, token)
+}
+
+// Revoke deletes one of the current user's tokens
+func (th *APITokenHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	tokenID, err := uuid.Parse(mux.Vars(r)["tokenID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	if err := th.APITokenService.RevokeToken(r.Context(), userID, tokenID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -4,12 +4,25 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
 	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/andy-dam/iq-theory/server/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 )
 
 type AuthHandler struct {
-	UserService service.UserService
+	UserService    service.UserService
+	SessionService service.SessionService
+	MFAService     service.MFAService
+	Tokens         *auth.TokenManager
+	// Authenticator checks guest tokens handed over for merging
+	Authenticator *middleware.Authenticator
+	// Firebase verifies ID tokens exchanged at /auth/firebase. Nil when
+	// Firebase sign-in is off.
+	Firebase *auth.FirebaseVerifier
 }
 
 func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -27,7 +40,8 @@ func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	// Call the service to register the user
 	usr, err := ah.UserService.CreateUser(r.Context(), &req)
 	if err != nil {
-		http.Error(w, `{"error": "Failed to register user"}`, http.StatusInternalServerError)
+		// Conflicts and password policy violations are reported to the client
+		writeServiceError(w, err)
 		return
 	}
 
@@ -41,3 +55,259 @@ func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	w.Write(response)
 }
+
+func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	var usr *models.User
+	var err error
+	if req.Username != "" {
+		// Managed students may have no email address
+		usr, err = ah.UserService.AuthenticateStudent(r.Context(), req.Username, req.Password, utils.ClientIP(r))
+	} else {
+		usr, err = ah.UserService.AuthenticateUser(r.Context(), req.Email, req.Password, utils.ClientIP(r))
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	ah.completeLogin(w, r, usr)
+}
+
+// LoginCode signs a managed student in with a login code printed by their
+// teacher
+func (ah *AuthHandler) LoginCode(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	usr, err := ah.UserService.AuthenticateLoginCode(r.Context(), req.Code, utils.ClientIP(r))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	ah.completeLogin(w, r, usr)
+}
+
+// LoginFirebase exchanges a Firebase ID token from the web client for an
+// access token, provisioning or linking the user on first use. It goes
+// through completeLogin like a password sign-in, so accounts with two-factor
+// authentication still need their code.
+func (ah *AuthHandler) LoginFirebase(w http.ResponseWriter, r *http.Request) {
+	var req models.FirebaseLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IDToken == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	claims, err := ah.Firebase.Verify(req.IDToken)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	usr, err := ah.UserService.AuthenticateFirebaseUser(r.Context(), &models.ExternalIdentity{
+		Provider:      "firebase",
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		DisplayName:   claims.Name,
+		AvatarURL:     claims.Picture,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	// Signing out everywhere also retires ID tokens Firebase issued before
+	if middleware.Revoked(usr, claims.IssuedAt) {
+		utils.WriteError(w, http.StatusUnauthorized, "Token has been revoked")
+		return
+	}
+
+	ah.completeLogin(w, r, usr)
+}
+
+// completeLogin responds to a checked sign-in. Accounts with two-factor
+// authentication get a challenge to redeem at /auth/login/mfa instead of a
+// token.
+func (ah *AuthHandler) completeLogin(w http.ResponseWriter, r *http.Request, usr *models.User) {
+	challenge, err := ah.MFAService.BeginLogin(r.Context(), usr.ID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if challenge != nil {
+		utils.WriteJSON(w, http.StatusOK, challenge)
+		return
+	}
+
+	ah.startSession(w, r, usr)
+}
+
+// LoginMFA completes a login with the TOTP or recovery code for the
+// challenge returned by Login
+func (ah *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	usr, err := ah.MFAService.CompleteLogin(r.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	ah.startSession(w, r, usr)
+}
+
+// startSession records the login and responds with an access token
+func (ah *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, usr *models.User) {
+	session, err := ah.SessionService.StartSession(r.Context(), usr.ID, r.UserAgent(), utils.ClientIP(r))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	token, expiresAt, err := ah.Tokens.Generate(usr.ID, session.ID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, &models.LoginResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      usr,
+	})
+}
+
+// Logout revokes the session of the token making the request
+func (ah *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+	sessionID, _ := middleware.SessionIDFromContext(r.Context())
+
+	if err := ah.SessionService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePassword changes the signed-in user's password after checking the
+// current one
+func (ah *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CurrentPassword == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := ah.UserService.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ah *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := ah.UserService.ConfirmEmail(r.Context(), req.Token); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ah *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	if err := ah.UserService.SendVerificationEmail(r.Context(), userID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (ah *AuthHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req models.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := ah.UserService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	// Same response whether or not the email is registered
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (ah *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ConfirmPasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := ah.UserService.ResetPassword(r.Context(), req.Token, req.NewPassword); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlockAccount lifts a sign-in lockout with the token from the emailed link
+func (ah *AuthHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	var req models.UnlockAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := ah.UserService.UnlockAccount(r.Context(), req.Token); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ClearLockout lets a site admin lift a user's sign-in lockout
+func (ah *AuthHandler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mux.Vars(r)["userID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := ah.UserService.ClearLockout(r.Context(), userID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
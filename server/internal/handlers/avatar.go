@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+)
+
+// avatarField is the multipart form field holding the image
+const avatarField = "avatar"
+
+type AvatarHandler struct {
+	AvatarService service.AvatarService
+	// MaxBytes is the largest accepted image
+	MaxBytes int64
+}
+
+// Upload replaces the current user's avatar with a PNG or JPEG sent as the
+// "avatar" field of a multipart form, and returns the updated user
+func (ah *AvatarHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	// Leave room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, ah.MaxBytes+64<<10)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Expected a multipart form")
+		return
+	}
+
+	var data []byte
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		if part.FormName() != avatarField {
+			continue
+		}
+		data, err = io.ReadAll(io.LimitReader(part, ah.MaxBytes+1))
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		break
+	}
+	if data == nil {
+		utils.WriteError(w, http.StatusBadRequest, "Missing avatar file")
+		return
+	}
+	if int64(len(data)) > ah.MaxBytes {
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, "Avatar file is too large")
+		return
+	}
+
+	user, err := ah.AvatarService.UploadAvatar(r.Context(), userID, data)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, user)
+}
+
+// Delete removes the current user's avatar and returns the updated user
+func (ah *AvatarHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	user, err := ah.AvatarService.DeleteAvatar(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, user)
+}
+
+// writeUploadError reports a failure reading the request body
+func writeUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, "Avatar file is too large")
+		return
+	}
+	utils.WriteError(w, http.StatusBadRequest, "Invalid multipart form")
+}
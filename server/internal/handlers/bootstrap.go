@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+)
+
+// BootstrapHandler serves the state the client loads right after sign-in
+type BootstrapHandler struct {
+	UserService        service.UserService
+	FeatureFlagService service.FeatureFlagService
+}
+
+func (bh *BootstrapHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	usr, err := bh.UserService.GetUserByID(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	flags, err := bh.FeatureFlagService.EvaluateAll(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, &models.BootstrapResponse{
+		User:         usr,
+		FeatureFlags: flags,
+	})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+)
+
+// writeServiceError maps service errors to HTTP responses. Unexpected errors
+// are logged and reported without internal details.
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		utils.WriteError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrConflict):
+		utils.WriteError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrInvalidInput), errors.Is(err, service.ErrInvalidToken):
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrForbidden), errors.Is(err, service.ErrEmailNotVerified):
+		utils.WriteError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidCredentials):
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, service.ErrTooManyRequests):
+		utils.WriteError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		log.Printf("Internal error: %v", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/gorilla/mux"
+)
+
+type FeatureFlagHandler struct {
+	FeatureFlagService service.FeatureFlagService
+}
+
+func (fh *FeatureFlagHandler) List(w http.ResponseWriter, r *http.Request) {
+	flags, err := fh.FeatureFlagService.ListFlags(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, flags)
+}
+
+func (fh *FeatureFlagHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req models.FeatureFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	flag, err := fh.FeatureFlagService.CreateFlag(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, flag)
+}
+
+func (fh *FeatureFlagHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req models.FeatureFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	// The key in the path is authoritative
+	req.Key = mux.Vars(r)["key"]
+
+	flag, err := fh.FeatureFlagService.UpdateFlag(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, flag)
+}
+
+func (fh *FeatureFlagHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := fh.FeatureFlagService.DeleteFlag(r.Context(), mux.Vars(r)["key"]); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
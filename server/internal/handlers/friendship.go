@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// FriendshipHandler serves friend lists, friend requests and blocks
+type FriendshipHandler struct {
+	FriendshipService service.FriendshipService
+}
+
+// List returns the current user's friends
+func (fh *FriendshipHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	friends, err := fh.FriendshipService.GetUserFriends(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, friends)
+}
+
+// Incoming returns the pending friend requests sent to the current user
+func (fh *FriendshipHandler) Incoming(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	requests, err := fh.FriendshipService.GetIncomingRequests(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, requests)
+}
+
+// Outgoing returns the pending friend requests the current user has sent
+func (fh *FriendshipHandler) Outgoing(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	requests, err := fh.FriendshipService.GetOutgoingRequests(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, requests)
+}
+
+// SendRequest sends a friend request. If the addressee had already sent one,
+// the returned friendship is accepted.
+func (fh *FriendshipHandler) SendRequest(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req models.FriendRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AddresseeID == uuid.Nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	friendship, err := fh.FriendshipService.SendFriendRequest(r.Context(), userID, req.AddresseeID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, friendship)
+}
+
+// Accept accepts a friend request sent to the current user
+func (fh *FriendshipHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	fh.withFriendship(w, r, fh.FriendshipService.AcceptFriendRequest)
+}
+
+// Decline declines a friend request sent to the current user
+func (fh *FriendshipHandler) Decline(w http.ResponseWriter, r *http.Request) {
+	fh.withFriendship(w, r, fh.FriendshipService.DeclineFriendRequest)
+}
+
+// Remove ends a friendship or withdraws a sent request
+func (fh *FriendshipHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	fh.withFriendship(w, r, fh.FriendshipService.RemoveFriend)
+}
+
+// Suggestions returns users the current user may know. Takes an optional
+// limit.
+func (fh *FriendshipHandler) Suggestions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	suggestions, err := fh.FriendshipService.GetSuggestions(r.Context(), userID, limit)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, suggestions)
+}
+
+// Blocked returns the users the current user has blocked
+func (fh *FriendshipHandler) Blocked(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	blocks, err := fh.FriendshipService.GetBlockedUsers(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, blocks)
+}
+
+// Block blocks the user in the path
+func (fh *FriendshipHandler) Block(w http.ResponseWriter, r *http.Request) {
+	fh.withUser(w, r, fh.FriendshipService.BlockUser)
+}
+
+// Unblock lifts the current user's block on the user in the path
+func (fh *FriendshipHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	fh.withUser(w, r, fh.FriendshipService.UnblockUser)
+}
+
+// withFriendship calls action with the current user and the friendship in
+// the path, answering 204 on success
+func (fh *FriendshipHandler) withFriendship(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, userID, friendshipID uuid.UUID) error) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	friendshipID, err := uuid.Parse(mux.Vars(r)["friendshipID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid friendship ID")
+		return
+	}
+
+	if err := action(r.Context(), userID, friendshipID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// withUser calls action with the current user and the user in the path,
+// answering 204 on success
+func (fh *FriendshipHandler) withUser(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, userID, otherID uuid.UUID) error) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	otherID, err := uuid.Parse(mux.Vars(r)["userID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := action(r.Context(), userID, otherID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
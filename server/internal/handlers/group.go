@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// GroupHandler exposes group administration
+type GroupHandler struct {
+	GroupService service.GroupService
+}
+
+// Create creates a group with the caller as its admin. Needs the
+// groups.create permission.
+func (gh *GroupHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req models.CreateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	var description string
+	if req.Description != nil {
+		description = *req.Description
+	}
+
+	group, err := gh.GroupService.CreateGroup(r.Context(), userID, req.Name, description, req.MaxMembers)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, group)
+}
+
+// SetAdminMFARequired turns the group's 2FA requirement for admins on or off
+func (gh *GroupHandler) SetAdminMFARequired(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	groupID, err := uuid.Parse(mux.Vars(r)["groupID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+
+	var req models.GroupAdminMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := gh.GroupService.SetAdminMFARequired(r.Context(), userID, groupID, req.Required); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxRosterBytes bounds the size of an uploaded roster
+const maxRosterBytes = 256 << 10
+
+// ImportRoster creates managed student accounts from a CSV roster in the
+// request body and adds them to the group. The response holds each
+// student's generated password or login code, which cannot be read again.
+func (gh *GroupHandler) ImportRoster(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	groupID, err := uuid.Parse(mux.Vars(r)["groupID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRosterBytes)
+	credentials, err := gh.GroupService.ImportRoster(r.Context(), userID, groupID, r.Body, r.URL.Query().Get("credential"))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.WriteError(w, http.StatusRequestEntityTooLarge, "Roster is too large")
+			return
+		}
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, credentials)
+}
+
+// ResetStudentCredential issues a student managed by the group a new
+// password or login code and signs them out everywhere
+func (gh *GroupHandler) ResetStudentCredential(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	groupID, err := uuid.Parse(mux.Vars(r)["groupID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid group ID")
+		return
+	}
+	studentID, err := uuid.Parse(mux.Vars(r)["userID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.ResetStudentCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	credential, err := gh.GroupService.ResetStudentCredential(r.Context(), userID, groupID, studentID, req.Credential)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, credential)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+)
+
+// CreateGuest issues a guest account and signs it in, so new players can
+// try a quiz before signing up. Guests are limited per client IP.
+func (ah *AuthHandler) CreateGuest(w http.ResponseWriter, r *http.Request) {
+	usr, err := ah.UserService.CreateGuest(r.Context(), utils.ClientIP(r))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	ah.startSession(w, r, usr)
+}
+
+// UpgradeGuest registers the signed-in guest with the credentials in the
+// body, keeping its quiz history and token
+func (ah *AuthHandler) UpgradeGuest(w http.ResponseWriter, r *http.Request) {
+	guestID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req models.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	usr, err := ah.UserService.UpgradeGuest(r.Context(), guestID, &req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, usr)
+}
+
+// MergeGuest moves the quiz history of the guest whose token is in the body
+// into the signed-in account and deletes the guest. Clients call it after
+// a guest signs in to an account that already exists.
+func (ah *AuthHandler) MergeGuest(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req models.MergeGuestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.GuestToken == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	guestID, err := ah.Authenticator.Identify(r.Context(), req.GuestToken)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	merged, err := ah.UserService.MergeGuest(r.Context(), userID, guestID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, &models.MergeGuestResponse{MergedSessions: merged})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+)
+
+// LeaderboardHandler exposes leaderboards
+type LeaderboardHandler struct {
+	LeaderboardService service.LeaderboardService
+}
+
+// Refresh recomputes the leaderboards straight away rather than waiting
+// for the next completed quiz
+func (lh *LeaderboardHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if err := lh.LeaderboardService.RefreshLeaderboards(r.Context()); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Friends returns the leaderboard of the current user and their friends for
+// one quiz configuration. Takes clef, duration and maxLedgerLines plus
+// optional limit and offset.
+func (lh *LeaderboardHandler) Friends(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	duration, err := queryInt(r, "duration", 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid duration")
+		return
+	}
+	maxLedgerLines, err := queryInt(r, "maxLedgerLines", -1)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid maxLedgerLines")
+		return
+	}
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid offset")
+		return
+	}
+
+	entries, err := lh.LeaderboardService.GetFriendsLeaderboard(r.Context(), userID,
+		r.URL.Query().Get("clef"), duration, maxLedgerLines, limit, offset)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+)
+
+// MFAHandler lets users set up and manage two-factor authentication
+type MFAHandler struct {
+	MFAService service.MFAService
+}
+
+func (mh *MFAHandler) Status(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	status, err := mh.MFAService.Status(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, status)
+}
+
+// BeginTOTP starts authenticator app enrolment. Calling it again before
+// confirming replaces the pending secret.
+func (mh *MFAHandler) BeginTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	enrollment, err := mh.MFAService.BeginEnrollment(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, enrollment)
+}
+
+func (mh *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	code, ok := decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := mh.MFAService.ConfirmEnrollment(r.Context(), userID, code)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, &models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (mh *MFAHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	code, ok := decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	if err := mh.MFAService.Disable(r.Context(), userID, code); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (mh *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	code, ok := decodeMFACode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := mh.MFAService.RegenerateRecoveryCodes(r.Context(), userID, code)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, &models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// decodeMFACode reads an MFACodeRequest, writing a 400 if it is missing
+func decodeMFACode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return "", false
+	}
+	return req.Code, true
+}
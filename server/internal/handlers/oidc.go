@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/andy-dam/iq-theory/server/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// OIDCHandler signs users in through external OpenID Connect providers and
+// manages their linked identities
+type OIDCHandler struct {
+	IdentityService service.IdentityService
+	SessionService  service.SessionService
+	MFAService      service.MFAService
+	Tokens          *auth.TokenManager
+	// AppURL is the web client the callback redirects back to
+	AppURL string
+}
+
+func (oh *OIDCHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, oh.IdentityService.Providers())
+}
+
+// Login redirects the browser to the provider's sign-in page
+func (oh *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	authURL, err := oh.IdentityService.StartLogin(r.Context(), mux.Vars(r)["provider"], nil)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Link starts linking a provider to the signed-in user. The client sends the
+// browser to the returned URL; the callback completes the link.
+func (oh *OIDCHandler) Link(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	authURL, err := oh.IdentityService.StartLogin(r.Context(), mux.Vars(r)["provider"], &userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, &models.OIDCLinkResponse{AuthorizationURL: authURL})
+}
+
+// Callback completes a login or link and redirects back to the web client.
+// The outcome is passed in the URL fragment so the token never reaches
+// server logs.
+func (oh *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+	query := r.URL.Query()
+
+	if providerError := query.Get("error"); providerError != "" {
+		oh.redirect(w, r, url.Values{"error": {providerError}})
+		return
+	}
+
+	result, err := oh.IdentityService.CompleteLogin(r.Context(), provider, query.Get("state"), query.Get("code"))
+	if err != nil {
+		oh.redirect(w, r, url.Values{"error": {callbackError(err)}})
+		return
+	}
+	if result.Linked {
+		oh.redirect(w, r, url.Values{"linked": {provider}})
+		return
+	}
+
+	challenge, err := oh.MFAService.BeginLogin(r.Context(), result.User.ID)
+	if err != nil {
+		oh.redirect(w, r, url.Values{"error": {callbackError(err)}})
+		return
+	}
+	if challenge != nil {
+		// The client finishes with POST /api/auth/login/mfa
+		oh.redirect(w, r, url.Values{
+			"mfa_token":  {challenge.MFAToken},
+			"expires_at": {strconv.FormatInt(challenge.ExpiresAt.Unix(), 10)},
+		})
+		return
+	}
+
+	session, err := oh.SessionService.StartSession(r.Context(), result.User.ID, r.UserAgent(), utils.ClientIP(r))
+	if err != nil {
+		oh.redirect(w, r, url.Values{"error": {callbackError(err)}})
+		return
+	}
+	token, expiresAt, err := oh.Tokens.Generate(result.User.ID, session.ID)
+	if err != nil {
+		oh.redirect(w, r, url.Values{"error": {callbackError(err)}})
+		return
+	}
+
+	oh.redirect(w, r, url.Values{
+		"token":      {token},
+		"expires_at": {strconv.FormatInt(expiresAt.Unix(), 10)},
+	})
+}
+
+func (oh *OIDCHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	identities, err := oh.IdentityService.ListIdentities(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, identities)
+}
+
+func (oh *OIDCHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	identityID, err := uuid.Parse(mux.Vars(r)["identityID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid identity ID")
+		return
+	}
+
+	if err := oh.IdentityService.UnlinkIdentity(r.Context(), userID, identityID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (oh *OIDCHandler) redirect(w http.ResponseWriter, r *http.Request, fragment url.Values) {
+	http.Redirect(w, r, oh.AppURL+"/auth/callback#"+fragment.Encode(), http.StatusFound)
+}
+
+// callbackError turns a service error into a message safe to show the user
+func callbackError(err error) string {
+	switch {
+	case errors.Is(err, service.ErrNotFound),
+		errors.Is(err, service.ErrConflict),
+		errors.Is(err, service.ErrInvalidInput),
+		errors.Is(err, service.ErrInvalidToken),
+		errors.Is(err, service.ErrInvalidCredentials):
+		return err.Error()
+	default:
+		log.Printf("Internal error: %v", err)
+		return "sign-in failed"
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// PermissionHandler exposes site roles and permissions
+type PermissionHandler struct {
+	PermissionService service.PermissionService
+}
+
+// Mine returns the current user's site role and permissions, so clients
+// can show or hide teacher and admin features
+func (ph *PermissionHandler) Mine(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	permissions, err := ph.PermissionService.GetPermissions(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, permissions)
+}
+
+// ListByRole lists the users holding the role given in the role query
+// parameter
+func (ph *PermissionHandler) ListByRole(w http.ResponseWriter, r *http.Request) {
+	users, err := ph.PermissionService.ListUsersWithRole(r.Context(), r.URL.Query().Get("role"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, users)
+}
+
+// SetRole grants a user a site role and returns the updated user
+func (ph *PermissionHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	actorID, _ := middleware.UserIDFromContext(r.Context())
+
+	userID, err := uuid.Parse(mux.Vars(r)["userID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.SetSiteRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := ph.PermissionService.SetSiteRole(r.Context(), actorID, userID, req.Role)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+)
+
+// PreferencesHandler exposes the current user's preferences
+type PreferencesHandler struct {
+	PreferencesService service.PreferencesService
+}
+
+// Get returns the current user's preferences, with defaults filled in
+func (ph *PreferencesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	prefs, err := ph.PreferencesService.GetPreferences(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, prefs)
+}
+
+// Update changes only the fields present in the body. A null quiz default
+// clears it so quizzes fall back to the system default again.
+func (ph *PreferencesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	prefs, err := ph.PreferencesService.GetPreferences(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(prefs); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	prefs, err = ph.PreferencesService.UpdatePreferences(r.Context(), userID, prefs)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, prefs)
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// queryInt reads an optional integer query parameter, returning def when it
+// is absent
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	return strconv.Atoi(raw)
+}
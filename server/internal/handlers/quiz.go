@@ -1,18 +1,63 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 )
 
 type QuizHandler struct {
-	QuizService *service.QuizService
+	QuizService service.QuizService
+}
+
+// CreateSession starts a quiz for the current user. An empty body or
+// omitted fields use the user's saved quiz defaults.
+func (qh *QuizHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	var req models.StartQuizRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	session, err := qh.QuizService.CreateQuizSession(r.Context(), userID, &req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, session)
+}
+
+// CompleteSession finishes one of the current user's quiz sessions
+func (qh *QuizHandler) CompleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	sessionID, err := uuid.Parse(mux.Vars(r)["sessionID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	session, err := qh.QuizService.CompleteQuizSession(r.Context(), userID, sessionID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, session)
 }
 
-// TODO: Add quiz-related handlers here
+// TODO: Add the remaining quiz handlers here
 // Examples:
-// - GetQuiz (by ID)
-// - CreateQuiz
-// - UpdateQuiz
-// - DeleteQuiz
-// - GetQuizzes (list all quizzes)
-// - SubmitQuizAnswer
+// - GetSession
+// - SubmitAnswer
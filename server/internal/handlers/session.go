@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// SessionHandler lets users see and sign out their signed-in devices
+type SessionHandler struct {
+	SessionService service.SessionService
+}
+
+func (sh *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+	currentID, _ := middleware.SessionIDFromContext(r.Context())
+
+	sessions, err := sh.SessionService.ListSessions(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentID
+	}
+
+	utils.WriteJSON(w, http.StatusOK, sessions)
+}
+
+func (sh *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	sessionID, err := uuid.Parse(mux.Vars(r)["sessionID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := sh.SessionService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (sh *SessionHandler) RevokeAll(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+
+	if err := sh.SessionService.RevokeAllSessions(r.Context(), userID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
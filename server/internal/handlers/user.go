@@ -1,16 +1,75 @@
 package handlers
 
 import (
+	"net/http"
+
+	"github.com/andy-dam/iq-theory/server/internal/middleware"
 	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 )
 
 type UserHandler struct {
 	UserService service.UserService
 }
 
-// TODO: Add more user-related handlers here
-// Examples:
-// - GetUser (by ID)
-// - CreateUser
-// - UpdateUser
-// - DeleteUser
+// Search finds users by username or display name. Takes q plus optional
+// limit and offset.
+func (uh *UserHandler) Search(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid offset")
+		return
+	}
+
+	results, err := uh.UserService.SearchUsers(r.Context(), r.URL.Query().Get("q"), limit, offset)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, results)
+}
+
+// GetProfile returns a user's public profile with quiz stats
+func (uh *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(mux.Vars(r)["userID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	profile, err := uh.UserService.GetPublicProfile(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, profile)
+}
+
+// Compare compares the current user's quiz results with a friend's or
+// group-mate's
+func (uh *UserHandler) Compare(w http.ResponseWriter, r *http.Request) {
+	currentUserID, _ := middleware.UserIDFromContext(r.Context())
+
+	userID, err := uuid.Parse(mux.Vars(r)["userID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	comparison, err := uh.UserService.CompareUsers(r.Context(), currentUserID, userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, comparison)
+}
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/internal/utils"
+	"github.com/andy-dam/iq-theory/server/pkg/auth"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	sessionIDKey
+	guestKey
+	scopesKey
+)
+
+// UserLookup loads the account a token was issued to
+type UserLookup interface {
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+}
+
+// SessionValidator checks that a token's login session is still active
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, sessionID, userID uuid.UUID) error
+}
+
+// APITokenValidator resolves personal access tokens
+type APITokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (*models.APIToken, error)
+}
+
+// Authenticator resolves bearer tokens to users. It accepts the API's own
+// access tokens and personal access tokens on the routes opened to them with
+// AllowAPITokens. Firebase ID tokens are exchanged for access tokens at
+// /auth/firebase instead, so two-factor checks and sessions apply to them.
+type Authenticator struct {
+	Tokens    *auth.TokenManager
+	Users     UserLookup
+	Sessions  SessionValidator
+	APITokens APITokenValidator
+
+	// tokenRoutes maps the routes personal access tokens can call to the
+	// scope they need there
+	tokenRoutes map[*mux.Route]models.APIScope
+}
+
+// AllowAPITokens lets personal access tokens with the scope call the routes.
+// Tokens are refused everywhere else. Routes must be registered before the
+// server starts.
+func (a *Authenticator) AllowAPITokens(scope models.APIScope, routes ...*mux.Route) {
+	if a.tokenRoutes == nil {
+		a.tokenRoutes = make(map[*mux.Route]models.APIScope)
+	}
+	for _, route := range routes {
+		a.tokenRoutes[route] = scope
+	}
+}
+
+// Authenticate requires a valid bearer token for an active account whose
+// session has not been revoked, and stores the user ID (and the session ID
+// for access tokens) on the request context. Personal access tokens also
+// need the scope their route was opened with.
+func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			utils.WriteError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+
+		ctx, err := a.authenticate(r.Context(), token)
+		if err != nil {
+			var authErr authError
+			if errors.As(err, &authErr) {
+				utils.WriteError(w, http.StatusUnauthorized, string(authErr))
+				return
+			}
+			log.Printf("Failed to authenticate request: %v", err)
+			utils.WriteError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+
+		if scopes, ok := ctx.Value(scopesKey).([]models.APIScope); ok {
+			scope, allowed := a.tokenRoutes[mux.CurrentRoute(r)]
+			if !allowed {
+				utils.WriteError(w, http.StatusForbidden, "API tokens cannot be used for this endpoint")
+				return
+			}
+			if !slices.Contains(scopes, scope) {
+				utils.WriteError(w, http.StatusForbidden, fmt.Sprintf("Token needs the %q scope", scope))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authError is an authentication failure reported to the client as a 401
+type authError string
+
+func (e authError) Error() string { return string(e) }
+
+const errInvalidToken = authError("Invalid or expired token")
+
+// authenticate validates the token and returns ctx with the caller's identity
+func (a *Authenticator) authenticate(ctx context.Context, token string) (context.Context, error) {
+	if strings.HasPrefix(token, models.APITokenPrefix) && a.APITokens != nil {
+		return a.authenticateAPIToken(ctx, token)
+	}
+
+	claims, err := a.Tokens.Validate(token)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	// Tokens issued before sessions existed carry no sid and cannot be
+	// revoked, so they are not accepted
+	sessionID, err := claims.SessionID()
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	user, err := a.Users.GetUserByID(ctx, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token owner %s: %w", userID, err)
+	}
+	if Revoked(user, claims.IssuedAt) {
+		return nil, authError("Token has been revoked")
+	}
+
+	err = a.Sessions.ValidateSession(ctx, sessionID, userID)
+	if errors.Is(err, service.ErrInvalidToken) {
+		return nil, authError("Session has been revoked or has expired")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate session %s: %w", sessionID, err)
+	}
+
+	ctx = context.WithValue(ctx, sessionIDKey, sessionID)
+	return withUser(ctx, user), nil
+}
+
+// authenticateAPIToken accepts a personal access token and stores its scopes
+// on the context for Authenticate to check against the route
+func (a *Authenticator) authenticateAPIToken(ctx context.Context, token string) (context.Context, error) {
+	apiToken, err := a.APITokens.ValidateToken(ctx, token)
+	if errors.Is(err, service.ErrInvalidToken) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate API token: %w", err)
+	}
+
+	user, err := a.Users.GetUserByID(ctx, apiToken.UserID)
+	if errors.Is(err, service.ErrNotFound) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token owner %s: %w", apiToken.UserID, err)
+	}
+	// A password reset or sign-out everywhere revokes API tokens too
+	if Revoked(user, apiToken.CreatedAt.Unix()) {
+		return nil, authError("Token has been revoked")
+	}
+
+	ctx = context.WithValue(ctx, scopesKey, apiToken.Scopes)
+	return withUser(ctx, user), nil
+}
+
+// Revoked reports whether a token issued at issuedAt predates the user's
+// last mass revocation. Token iat claims have one-second resolution, so the
+// comparison is on whole seconds: a token issued in the same second as the
+// revocation stays valid rather than one signed just after it being refused.
+func Revoked(user *models.User, issuedAt int64) bool {
+	return user.TokensValidAfter != nil && issuedAt < user.TokensValidAfter.Unix()
+}
+
+func withUser(ctx context.Context, user *models.User) context.Context {
+	ctx = context.WithValue(ctx, userIDKey, user.ID)
+	ctx = context.WithValue(ctx, guestKey, user.IsGuest)
+	// Pin this user's replica reads to the primary right after they write
+	return database.WithUser(ctx, user.ID.String())
+}
+
+// Identify returns the user a bearer token was issued to, applying the same
+// checks as Authenticate. It is for tokens passed in a request body, such as
+// a guest's token when merging it into an account, so rejected tokens are
+// reported as service.ErrInvalidToken. Personal access tokens are refused.
+func (a *Authenticator) Identify(ctx context.Context, token string) (uuid.UUID, error) {
+	ctx, err := a.authenticate(ctx, token)
+	var authErr authError
+	if errors.As(err, &authErr) {
+		return uuid.Nil, service.ErrInvalidToken
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if _, ok := ctx.Value(scopesKey).([]models.APIScope); ok {
+		return uuid.Nil, service.ErrInvalidToken
+	}
+	userID, _ := UserIDFromContext(ctx)
+	return userID, nil
+}
+
+// RequireRegistered turns guests away from routes that need a registered
+// account. It must run after Authenticate.
+func RequireRegistered(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsGuest(r.Context()) {
+			utils.WriteError(w, http.StatusForbidden, "Sign up to use this feature")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PermissionChecker reports whether a user holds a site permission
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, userID uuid.UUID, permission models.Permission) (bool, error)
+}
+
+// RequirePermission only lets users whose site role grants the permission
+// through. It must run after Authenticate.
+func RequirePermission(checker PermissionChecker, permission models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := UserIDFromContext(r.Context())
+			if !ok {
+				utils.WriteError(w, http.StatusForbidden, "Permission required")
+				return
+			}
+
+			allowed, err := checker.HasPermission(r.Context(), userID, permission)
+			if err != nil {
+				log.Printf("Failed to check permission %s for user %s: %v", permission, userID, err)
+				utils.WriteError(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			if !allowed {
+				utils.WriteError(w, http.StatusForbidden, fmt.Sprintf("Permission %q required", permission))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UserIDFromContext returns the authenticated user's ID
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(userIDKey).(uuid.UUID)
+	return userID, ok
+}
+
+// SessionIDFromContext returns the login session of the authenticated request
+func SessionIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	sessionID, ok := ctx.Value(sessionIDKey).(uuid.UUID)
+	return sessionID, ok
+}
+
+// IsGuest reports whether the authenticated user is a guest
+func IsGuest(ctx context.Context) bool {
+	guest, _ := ctx.Value(guestKey).(bool)
+	return guest
+}
+
+// bearerToken extracts the token from the Authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" {
+		return "", false
+	}
+	return token, true
+}
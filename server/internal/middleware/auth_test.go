@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/service"
+	"github.com/andy-dam/iq-theory/server/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type fakeUsers map[uuid.UUID]*models.User
+
+func (f fakeUsers) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, ok := f[userID]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+	return user, nil
+}
+
+type fakeAPITokens map[string]*models.APIToken
+
+func (f fakeAPITokens) ValidateToken(ctx context.Context, token string) (*models.APIToken, error) {
+	apiToken, ok := f[token]
+	if !ok {
+		return nil, service.ErrInvalidToken
+	}
+	return apiToken, nil
+}
+
+// serve sends a GET with the bearer token to path on a router whose
+// protected routes all answer 200
+func serve(a *Authenticator, configure func(protected *mux.Router), path, token string) int {
+	r := mux.NewRouter()
+	protected := r.NewRoute().Subrouter()
+	protected.Use(a.Authenticate)
+	configure(protected)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func ok(w http.ResponseWriter, r *http.Request) {}
+
+func TestRevoked(t *testing.T) {
+	validAfter := time.Date(2026, time.October, 18, 15, 30, 0, 700_000_000, time.UTC)
+	user := &models.User{TokensValidAfter: &validAfter}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		issuedAt time.Time
+		want     bool
+	}{
+		{"never revoked", &models.User{}, validAfter.Add(-time.Hour), false},
+		{"issued a second earlier", user, validAfter.Add(-time.Second), true},
+		{"issued earlier in the same second", user, validAfter.Add(-500 * time.Millisecond), false},
+		{"issued later in the same second", user, validAfter.Add(200 * time.Millisecond), false},
+		{"issued after", user, validAfter.Add(time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Revoked(tt.user, tt.issuedAt.Unix()); got != tt.want {
+				t.Errorf("Revoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPITokenScopes(t *testing.T) {
+	revokedAt := time.Now()
+	owner := &models.User{ID: uuid.New()}
+	revokedOwner := &models.User{ID: uuid.New(), TokensValidAfter: &revokedAt}
+	token := func(user *models.User, createdAt time.Time, scopes ...models.APIScope) *models.APIToken {
+		return &models.APIToken{ID: uuid.New(), UserID: user.ID, Scopes: scopes, CreatedAt: createdAt}
+	}
+
+	a := &Authenticator{
+		Users: fakeUsers{owner.ID: owner, revokedOwner.ID: revokedOwner},
+		APITokens: fakeAPITokens{
+			models.APITokenPrefix + "quiz":    token(owner, time.Now(), models.ScopeReadQuiz, models.ScopeWriteQuiz),
+			models.APITokenPrefix + "profile": token(owner, time.Now(), models.ScopeReadProfile),
+			models.APITokenPrefix + "old":     token(revokedOwner, revokedAt.Add(-time.Hour), models.ScopeWriteQuiz),
+		},
+	}
+	configure := func(protected *mux.Router) {
+		a.AllowAPITokens(models.ScopeWriteQuiz, protected.HandleFunc("/quiz", ok))
+		protected.HandleFunc("/settings", ok)
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"token with the route's scope", "/quiz", "quiz", http.StatusOK},
+		{"token without the route's scope", "/quiz", "profile", http.StatusForbidden},
+		{"route not opened to tokens", "/settings", "quiz", http.StatusForbidden},
+		{"token created before sign-out everywhere", "/quiz", "old", http.StatusUnauthorized},
+		{"unknown token", "/quiz", "unknown", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(a, configure, tt.path, models.APITokenPrefix+tt.token); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeSessions map[uuid.UUID]bool
+
+func (f fakeSessions) ValidateSession(ctx context.Context, sessionID, userID uuid.UUID) error {
+	if !f[sessionID] {
+		return service.ErrInvalidToken
+	}
+	return nil
+}
+
+func TestAuthenticateSessions(t *testing.T) {
+	tokens := auth.NewTokenManager("test-secret", time.Hour)
+	signedOutAt := time.Now().Add(2 * time.Second)
+	user := &models.User{ID: uuid.New()}
+	signedOut := &models.User{ID: uuid.New(), TokensValidAfter: &signedOutAt}
+	active, revoked := uuid.New(), uuid.New()
+
+	a := &Authenticator{
+		Tokens:   tokens,
+		Users:    fakeUsers{user.ID: user, signedOut.ID: signedOut},
+		Sessions: fakeSessions{active: true, revoked: false},
+	}
+	configure := func(protected *mux.Router) {
+		protected.HandleFunc("/settings", ok)
+	}
+	token := func(userID, sessionID uuid.UUID) string {
+		t.Helper()
+		token, _, err := tokens.Generate(userID, sessionID)
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"active session", token(user.ID, active), http.StatusOK},
+		{"revoked session", token(user.ID, revoked), http.StatusUnauthorized},
+		{"unknown session", token(user.ID, uuid.New()), http.StatusUnauthorized},
+		{"issued before sign-out everywhere", token(signedOut.ID, active), http.StatusUnauthorized},
+		{"unknown user", token(uuid.New(), active), http.StatusUnauthorized},
+		{"not a token", "garbage", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(a, configure, "/settings", tt.token); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		forwardedFor  []string
+		realIP        string
+		wantRemoteIP  string
+		wantUnchanged bool
+	}{
+		{
+			name:         "client behind one proxy",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: []string{"203.0.113.7"},
+			wantRemoteIP: "203.0.113.7",
+		},
+		{
+			name:         "spoofed entries left of the client are ignored",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.7"},
+			wantRemoteIP: "203.0.113.7",
+		},
+		{
+			name:         "chain of trusted proxies",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.7, 10.0.0.9", "10.0.0.3"},
+			wantRemoteIP: "203.0.113.7",
+		},
+		{
+			name:          "untrusted peer",
+			remoteAddr:    "198.51.100.9:5000",
+			forwardedFor:  []string{"203.0.113.7"},
+			wantUnchanged: true,
+		},
+		{
+			name:         "X-Real-IP without X-Forwarded-For",
+			remoteAddr:   "[::1]:5000",
+			realIP:       "203.0.113.7",
+			wantRemoteIP: "203.0.113.7",
+		},
+		{
+			name:         "X-Forwarded-For wins over X-Real-IP",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: []string{"203.0.113.7"},
+			realIP:       "198.51.100.1",
+			wantRemoteIP: "203.0.113.7",
+		},
+		{
+			name:         "IPv4-mapped client",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: []string{"::ffff:203.0.113.7"},
+			wantRemoteIP: "203.0.113.7",
+		},
+		{
+			name:         "every hop trusted",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: []string{"10.0.0.8, 10.0.0.9"},
+			wantRemoteIP: "10.0.0.8",
+		},
+		{
+			name:         "garbage stops the walk at the last trusted hop",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: []string{"203.0.113.7, not-an-ip, 10.0.0.9"},
+			wantRemoteIP: "10.0.0.9",
+		},
+		{
+			name:          "no headers",
+			remoteAddr:    "10.0.0.2:5000",
+			wantUnchanged: true,
+		},
+		{
+			name:          "invalid X-Real-IP",
+			remoteAddr:    "10.0.0.2:5000",
+			realIP:        "unknown",
+			wantUnchanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			var got string
+			RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			want := tt.remoteAddr
+			if !tt.wantUnchanged {
+				want = tt.wantRemoteIP + ":0"
+			}
+			if got != want {
+				t.Errorf("RemoteAddr = %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,6 +19,79 @@ type User struct {
 	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 	IsActive      bool      `json:"is_active" db:"is_active"`
 	EmailVerified bool      `json:"email_verified" db:"email_verified"`
+	// SiteRole is the user's site-wide role, one of the SiteRole constants
+	SiteRole string `json:"site_role" db:"site_role"`
+	// IsGuest marks an anonymous account issued to play before signing up
+	IsGuest bool `json:"is_guest" db:"is_guest"`
+	// ManagedByGroupID is set on student accounts created from a group
+	// roster, whose credentials the group's admins manage
+	ManagedByGroupID *uuid.UUID `json:"managed_by_group_id,omitempty" db:"managed_by_group_id"`
+	// PasswordChangeRequired is set while the user still has a generated
+	// initial password
+	PasswordChangeRequired bool `json:"password_change_required" db:"password_change_required"`
+	// TokensValidAfter revokes every access token issued before it
+	TokensValidAfter *time.Time `json:"-" db:"tokens_valid_after"`
+	// FirebaseUID links the user to their Firebase Authentication account
+	FirebaseUID *string `json:"-" db:"firebase_uid"`
+	// AvatarKey is the storage key of an uploaded avatar, from which
+	// AvatarURL is served
+	AvatarKey *string `json:"-" db:"avatar_key"`
+	// DeletionScheduledAt is when the account will be purged, unless the
+	// user cancels before then
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" db:"deletion_scheduled_at"`
+	// DeletedAt is when the account was purged and anonymized
+	DeletedAt *time.Time `json:"-" db:"deleted_at"`
+}
+
+// Site-wide roles. Students play quizzes, teachers can also run groups, and
+// admins can do everything.
+const (
+	SiteRoleStudent = "student"
+	SiteRoleTeacher = "teacher"
+	SiteRoleAdmin   = "admin"
+)
+
+// Permission is a site-wide action granted by role
+type Permission string
+
+// Permissions checked by services and routes
+const (
+	PermissionCreateGroup         Permission = "groups.create"
+	PermissionRefreshLeaderboards Permission = "leaderboards.refresh"
+	PermissionModerate            Permission = "moderate"
+	PermissionManageUsers         Permission = "users.manage"
+	PermissionManageFeatureFlags  Permission = "feature_flags.manage"
+)
+
+// APIScope is something a personal access token is allowed to do
+type APIScope string
+
+// Scopes that can be granted to personal access tokens
+const (
+	ScopeReadProfile  APIScope = "read:profile"
+	ScopeWriteProfile APIScope = "write:profile"
+	ScopeReadQuiz     APIScope = "read:quiz"
+	ScopeWriteQuiz    APIScope = "write:quiz"
+	ScopeWriteGroups  APIScope = "write:groups"
+	ScopeReadExports  APIScope = "read:exports"
+	ScopeWriteExports APIScope = "write:exports"
+)
+
+// APIScopes lists every scope a token can be granted
+var APIScopes = []APIScope{
+	ScopeReadProfile, ScopeWriteProfile, ScopeReadQuiz, ScopeWriteQuiz, ScopeWriteGroups,
+	ScopeReadExports, ScopeWriteExports,
+}
+
+// UserPermissions is the caller's role and what it allows
+type UserPermissions struct {
+	Role        string       `json:"role"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// SetSiteRoleRequest grants a user a site role
+type SetSiteRoleRequest struct {
+	Role string `json:"role"`
 }
 
 // Group represents a classroom or study group
@@ -31,6 +105,9 @@ type Group struct {
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 	IsActive    bool      `json:"is_active" db:"is_active"`
 	MaxMembers  int       `json:"max_members" db:"max_members"`
+	// RequireAdmin2FA stops admins without two-factor authentication from
+	// managing the group
+	RequireAdmin2FA bool `json:"require_admin_2fa" db:"require_admin_2fa"`
 }
 
 // GroupMembership represents user membership in a group
@@ -42,14 +119,102 @@ type GroupMembership struct {
 	JoinedAt time.Time `json:"joined_at" db:"joined_at"`
 }
 
-// Friendship represents friend relationships between users
+// Friendship represents friend relationships between users. A pair of users
+// has at most one.
 type Friendship struct {
 	ID          uuid.UUID `json:"id" db:"id"`
 	RequesterID uuid.UUID `json:"requester_id" db:"requester_id"`
 	AddresseeID uuid.UUID `json:"addressee_id" db:"addressee_id"`
-	Status      string    `json:"status" db:"status"` // pending, accepted, declined, blocked
+	Status      string    `json:"status" db:"status"` // pending, accepted, declined
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	// User is the other user, filled in when listing a user's friendships
+	User *UserSummary `json:"user,omitempty" db:"-"`
+}
+
+// Friendship statuses
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+	FriendshipDeclined = "declined"
+)
+
+// UserBlock is a block one user placed on another. Blocks are one-way: two
+// users can block each other.
+type UserBlock struct {
+	BlockerID uuid.UUID `json:"blocker_id" db:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id" db:"blocked_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// User is the blocked user, filled in when listing a user's blocks
+	User *UserSummary `json:"user,omitempty" db:"-"`
+}
+
+// FriendSuggestion is a user someone may know, with the reasons they were
+// suggested
+type FriendSuggestion struct {
+	UserSummary
+	SharedGroups  int `json:"shared_groups"`
+	MutualFriends int `json:"mutual_friends"`
+	// RecentlyActive marks a group-mate who completed a quiz recently
+	RecentlyActive bool `json:"recently_active"`
+}
+
+// Activity kinds
+const (
+	ActivityPersonalBest    = "personal_best"
+	ActivityStreakMilestone = "streak_milestone"
+	ActivityJoinedGroup     = "joined_group"
+)
+
+// Activity is a notable event shown in the user's friends' activity feeds.
+// Data holds a PersonalBestActivity, StreakActivity or JoinedGroupActivity,
+// depending on Kind.
+type Activity struct {
+	ID     uuid.UUID `json:"id" db:"id"`
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	Kind   string    `json:"kind" db:"kind"`
+	// DedupeKey identifies the event within the user and kind, so
+	// recording it again is a no-op
+	DedupeKey string          `json:"-" db:"dedupe_key"`
+	GroupID   *uuid.UUID      `json:"-" db:"group_id"`
+	Data      json.RawMessage `json:"data" db:"data"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	// User is the user the activity is about, filled in for feeds
+	User *UserSummary `json:"user,omitempty" db:"-"`
+}
+
+// PersonalBestActivity is a score that beat the user's previous best for a
+// quiz configuration
+type PersonalBestActivity struct {
+	Clef            string `json:"clef"`
+	DurationSeconds int    `json:"duration_seconds"`
+	MaxLedgerLines  int    `json:"max_ledger_lines"`
+	Score           int    `json:"score"`
+	PreviousBest    int    `json:"previous_best"`
+}
+
+// StreakActivity is a daily streak reaching a milestone
+type StreakActivity struct {
+	Days int `json:"days"`
+}
+
+// JoinedGroupActivity is the user joining a group
+type JoinedGroupActivity struct {
+	GroupID   uuid.UUID `json:"group_id"`
+	GroupName string    `json:"group_name"`
+}
+
+// ActivityCursor is the position after the last activity of a feed page
+type ActivityCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// ActivityFeed is a page of a user's friend activity feed
+type ActivityFeed struct {
+	Activities []*Activity `json:"activities"`
+	// NextCursor fetches the next page; it is empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // ClefType represents a musical clef type
@@ -88,6 +253,39 @@ type AvailableQuizConfiguration struct {
 	IsAvailable       bool   `json:"is_available" db:"is_available"`
 }
 
+// Note naming systems
+const (
+	NoteNamingEnglish = "english" // C D E F G A B
+	NoteNamingGerman  = "german"  // C D E F G A H, with B for B flat
+	NoteNamingSolfege = "solfege" // Fixed do: do re mi fa sol la si
+)
+
+// Answer input modes
+const (
+	AnswerInputButtons  = "buttons"  // One button per note name
+	AnswerInputPiano    = "piano"    // On-screen piano keyboard
+	AnswerInputKeyboard = "keyboard" // Typing note names
+)
+
+// UserPreferences holds a user's quiz defaults and display preferences. The
+// quiz defaults are nil until chosen.
+type UserPreferences struct {
+	UserID                 uuid.UUID `json:"-" db:"user_id"`
+	DefaultClef            *string   `json:"default_clef" db:"default_clef"`
+	DefaultDurationSeconds *int      `json:"default_duration_seconds" db:"default_duration_seconds"`
+	DefaultMaxLedgerLines  *int      `json:"default_max_ledger_lines" db:"default_max_ledger_lines"`
+	NoteNaming             string    `json:"note_naming" db:"note_naming"`
+	AnswerInput            string    `json:"answer_input" db:"answer_input"`
+	SoundEnabled           bool      `json:"sound_enabled" db:"sound_enabled"`
+	// Timezone is an IANA name such as Europe/London
+	Timezone string `json:"timezone" db:"timezone"`
+	// ShareActivity shows the user's activity in their friends' feeds
+	ShareActivity bool `json:"share_activity" db:"share_activity"`
+	// Discoverable lets the user appear in other users' friend suggestions
+	Discoverable bool      `json:"discoverable" db:"discoverable"`
+	UpdatedAt    time.Time `json:"updated_at,omitzero" db:"updated_at"`
+}
+
 // QuizSession represents an individual quiz attempt
 type QuizSession struct {
 	ID     uuid.UUID `json:"id" db:"id"`
@@ -121,6 +319,94 @@ type QuizAnswer struct {
 	AnsweredAt     time.Time `json:"answered_at" db:"answered_at"`
 }
 
+// ClefBestScore is a user's best completed score on one clef
+type ClefBestScore struct {
+	Clef      string `json:"clef" db:"clef"`
+	BestScore int    `json:"best_score" db:"best_score"`
+}
+
+// ProfileStats aggregates a user's completed quizzes
+type ProfileStats struct {
+	TotalQuizzes int              `json:"total_quizzes"`
+	BestScores   []*ClefBestScore `json:"best_scores"`
+	// CurrentStreak counts consecutive days with a completed quiz, ending
+	// today or yesterday (UTC)
+	CurrentStreak int `json:"current_streak"`
+}
+
+// ConfigStats is a user's completed quizzes on one quiz configuration
+type ConfigStats struct {
+	UserID          uuid.UUID `json:"-"`
+	Clef            string    `json:"-"`
+	DurationSeconds int       `json:"-"`
+	MaxLedgerLines  int       `json:"-"`
+	BestScore       int       `json:"best_score"`
+	AverageAccuracy float64   `json:"average_accuracy"`
+	Attempts        int       `json:"attempts"`
+	// Trend is the average score of the latest attempts minus that of the
+	// attempts before them, or nil if there are too few to tell
+	Trend *float64 `json:"trend"`
+}
+
+// NoteAccuracy is how often a user named one note correctly on one clef
+type NoteAccuracy struct {
+	UserID   uuid.UUID `json:"-"`
+	Clef     string    `json:"-"`
+	Note     string    `json:"-"`
+	Answers  int       `json:"answers"`
+	Correct  int       `json:"correct"`
+	Accuracy float64   `json:"accuracy"`
+}
+
+// ConfigComparison sets two users' stats on a quiz configuration side by
+// side. A user who never completed it has nil stats.
+type ConfigComparison struct {
+	Clef            string       `json:"clef"`
+	DurationSeconds int          `json:"duration_seconds"`
+	MaxLedgerLines  int          `json:"max_ledger_lines"`
+	User            *ConfigStats `json:"user"`
+	Other           *ConfigStats `json:"other"`
+}
+
+// NoteComparison sets two users' accuracy on a note side by side
+type NoteComparison struct {
+	Clef  string        `json:"clef"`
+	Note  string        `json:"note"`
+	User  *NoteAccuracy `json:"user"`
+	Other *NoteAccuracy `json:"other"`
+}
+
+// HeadToHead compares the current user with a friend or group-mate
+type HeadToHead struct {
+	User           *UserSummary        `json:"user"`
+	Other          *UserSummary        `json:"other"`
+	Configurations []*ConfigComparison `json:"configurations"`
+	Notes          []*NoteComparison   `json:"notes"`
+}
+
+// UserSummary is the public view of a user in lists and search results
+type UserSummary struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   *string   `json:"avatar_url,omitempty"`
+}
+
+// PublicProfile is what other users can see of an account
+type PublicProfile struct {
+	UserSummary
+	JoinedAt time.Time     `json:"joined_at"`
+	Stats    *ProfileStats `json:"stats"`
+}
+
+// UserSearchResponse is a page of user search results
+type UserSearchResponse struct {
+	Users   []*UserSummary `json:"users"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+	HasMore bool           `json:"has_more"`
+}
+
 // LeaderboardEntry represents a leaderboard entry (from materialized view)
 type LeaderboardEntry struct {
 	Clef            string     `json:"clef" db:"clef"`
@@ -137,6 +423,206 @@ type LeaderboardEntry struct {
 	AverageScore    float64    `json:"average_score" db:"average_score"`
 	LastAttempt     *time.Time `json:"last_attempt" db:"last_attempt"`
 	GlobalRank      int        `json:"global_rank" db:"global_rank"`
+	// Rank is the position within a friends leaderboard
+	Rank int `json:"rank,omitempty" db:"rank"`
+}
+
+// OutboxEvent represents a domain event stored in the transactional outbox
+type OutboxEvent struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	EventType     string          `json:"event_type" db:"event_type"`
+	AggregateID   uuid.UUID       `json:"aggregate_id" db:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at" db:"occurred_at"`
+	Status        string          `json:"status" db:"status"` // pending, delivered, failed
+	Attempts      int             `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     *string         `json:"last_error" db:"last_error"`
+	DeliveredAt   *time.Time      `json:"delivered_at" db:"delivered_at"`
+}
+
+// FeatureFlag represents a feature flag with its rollout and targeting rules
+type FeatureFlag struct {
+	Key         string `json:"key" db:"key"`
+	Description string `json:"description" db:"description"`
+	// Enabled is the kill switch; a disabled flag is off for everyone
+	Enabled bool `json:"enabled" db:"enabled"`
+	// RolloutPercentage turns the flag on for a stable slice of users (0-100)
+	RolloutPercentage int         `json:"rollout_percentage" db:"rollout_percentage"`
+	TargetUserIDs     []uuid.UUID `json:"target_user_ids" db:"target_user_ids"`
+	TargetGroupIDs    []uuid.UUID `json:"target_group_ids" db:"target_group_ids"`
+	TargetRoles       []string    `json:"target_roles" db:"target_roles"`
+	CreatedAt         time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// Purposes of single-use user tokens
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeAccountUnlock     = "account_unlock"
+)
+
+// Scopes of failed sign-in counters. LoginThrottleGuestIP counts guest
+// accounts created from a client IP instead of failures, and
+// LoginThrottleMFA counts wrong two-factor codes per user ID.
+const (
+	LoginThrottleAccount = "account"
+	LoginThrottleIP      = "ip"
+	LoginThrottleGuestIP = "guest_ip"
+	LoginThrottleMFA     = "mfa"
+)
+
+// LoginThrottle counts failed sign-ins for an email address or client IP
+type LoginThrottle struct {
+	Scope         string     `db:"scope"`
+	Subject       string     `db:"subject"`
+	Failures      int        `db:"failures"`
+	Lockouts      int        `db:"lockouts"`
+	LockedUntil   *time.Time `db:"locked_until"`
+	LastFailureAt time.Time  `db:"last_failure_at"`
+}
+
+// UserToken is a single-use token emailed to a user. Only its hash is stored.
+type UserToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Purpose   string     `json:"purpose" db:"purpose"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// StudentLoginCode is a printable code that signs a managed student in.
+// Only its hash is stored.
+type StudentLoginCode struct {
+	UserID     uuid.UUID  `db:"user_id"`
+	CodeHash   string     `db:"code_hash"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+}
+
+// ExternalIdentity is a user as asserted by a verified token from an
+// external identity provider
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	DisplayName   string
+	AvatarURL     string
+}
+
+// UserIdentity links a user to an account at an external OIDC provider
+type UserIdentity struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	Provider   string    `json:"provider" db:"provider"`
+	Subject    string    `json:"-" db:"subject"`
+	Email      string    `json:"email" db:"email"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+// OIDCLoginState carries an in-flight OIDC login from start to callback
+type OIDCLoginState struct {
+	StateHash    string     `db:"state_hash"`
+	Provider     string     `db:"provider"`
+	CodeVerifier string     `db:"code_verifier"`
+	Nonce        string     `db:"nonce"`
+	UserID       *uuid.UUID `db:"user_id"` // set when linking to a signed-in user
+	ExpiresAt    time.Time  `db:"expires_at"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// OIDCLoginResult is the outcome of an OIDC callback
+type OIDCLoginResult struct {
+	User *User
+	// Linked is true when the identity was linked to a signed-in user rather
+	// than used to sign in
+	Linked bool
+}
+
+// UserTOTP is a user's authenticator app enrolment
+type UserTOTP struct {
+	UserID uuid.UUID `db:"user_id"`
+	// Secret is encrypted at rest
+	Secret       string     `db:"secret"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"` // nil while enrolment is pending
+	LastUsedStep int64      `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+// MFAChallenge is a pending second login step
+type MFAChallenge struct {
+	TokenHash string    `db:"token_hash"`
+	UserID    uuid.UUID `db:"user_id"`
+	Attempts  int       `db:"attempts"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Session represents a signed-in device. Every access token belongs to one.
+type Session struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+	IPAddress  string     `json:"ip_address" db:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	// Current marks the session of the token making the request
+	Current bool `json:"current" db:"-"`
+}
+
+// APITokenPrefix starts every personal access token, telling them apart
+// from session access tokens
+const APITokenPrefix = "iqt_"
+
+// APIToken is a personal access token a user created for scripts and
+// integrations. Only its hash is stored.
+type APIToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"token_prefix"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Scopes     []APIScope `json:"scopes" db:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+// Statuses of a data export
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is a user's request for a copy of their personal data
+type DataExport struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	Status      string     `json:"status" db:"status"` // pending, ready, failed
+	SizeBytes   *int64     `json:"size_bytes,omitempty" db:"size_bytes"`
+	Error       *string    `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
+
+// AccountData is everything stored about a user, as included in an export
+type AccountData struct {
+	User             *User
+	Friendships      []*Friendship
+	Blocks           []*UserBlock
+	GroupMemberships []*GroupMembership
+	QuizSessions     []*QuizSession
+	QuizAnswers      []*QuizAnswer
+	Preferences      *UserPreferences
 }
 
 // DTOs for API requests/responses
@@ -149,12 +635,169 @@ type CreateUserRequest struct {
 	Password    string `json:"password" validate:"required,min=8"`
 }
 
-// LoginRequest represents a login request
+// LoginRequest represents a login request. Managed students, who may have
+// no email address, sign in with their username instead.
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
+	Email    string `json:"email,omitempty" validate:"required_without=Username,omitempty,email"`
+	Username string `json:"username,omitempty"`
 	Password string `json:"password" validate:"required"`
 }
 
+// FirebaseLoginRequest exchanges a Firebase ID token for an access token
+type FirebaseLoginRequest struct {
+	IDToken string `json:"id_token" validate:"required"`
+}
+
+// LoginCodeRequest signs a managed student in with a printed login code
+type LoginCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// ChangePasswordRequest changes the signed-in user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// LoginResponse represents a successful login
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      *User     `json:"user"`
+}
+
+// MergeGuestRequest moves the history of the guest that holds GuestToken
+// into the signed-in account
+type MergeGuestRequest struct {
+	GuestToken string `json:"guest_token" validate:"required"`
+}
+
+// MergeGuestResponse reports how many quiz sessions a guest merge moved
+type MergeGuestResponse struct {
+	MergedSessions int `json:"merged_sessions"`
+}
+
+// UnlockAccountRequest redeems an emailed account unlock token
+type UnlockAccountRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// MFAChallengeResponse is returned by login instead of a token when the
+// account has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// MFALoginRequest completes a login with a TOTP or recovery code
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFACodeRequest carries a TOTP or recovery code confirming an action
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFAStatus describes a user's two-factor authentication setup
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// TOTPEnrollment is what an authenticator app needs to add the account
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	// QRCode is a data: URI of a PNG encoding URI
+	QRCode string `json:"qr_code"`
+}
+
+// RecoveryCodesResponse lists newly generated recovery codes. They are
+// shown once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// VerifyEmailRequest represents the request to confirm an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// PasswordResetRequest represents the request to email a password reset link
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ConfirmPasswordResetRequest represents the request to set a new password
+// with a reset token
+type ConfirmPasswordResetRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+// DeleteAccountRequest represents the request to schedule account deletion.
+// Accounts with a password must confirm it.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// AccountDeletionResponse reports when a scheduled deletion will happen
+type AccountDeletionResponse struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// OIDCLinkResponse carries the provider URL that continues an account link
+type OIDCLinkResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// CreateAPITokenRequest creates a personal access token. Tokens without
+// ExpiresInDays never expire.
+type CreateAPITokenRequest struct {
+	Name          string     `json:"name" validate:"required,max=100"`
+	Scopes        []APIScope `json:"scopes" validate:"required,min=1"`
+	ExpiresInDays int        `json:"expires_in_days,omitempty" validate:"omitempty,min=1,max=365"`
+}
+
+// CreateAPITokenResponse is a new personal access token. Token cannot be
+// read again.
+type CreateAPITokenResponse struct {
+	*APIToken
+	Token string `json:"token"`
+}
+
+// Kinds of initial credential issued to managed students
+const (
+	StudentCredentialPassword  = "password"
+	StudentCredentialLoginCode = "login_code"
+)
+
+// StudentCredential is the printable sign-in for a managed student. Only
+// one of Password and LoginCode is set, and neither can be read again.
+type StudentCredential struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Email       *string   `json:"email,omitempty"`
+	Password    string    `json:"password,omitempty"`
+	LoginCode   string    `json:"login_code,omitempty"`
+}
+
+// ResetStudentCredentialRequest issues a managed student a new password or
+// login code
+type ResetStudentCredentialRequest struct {
+	Credential string `json:"credential" validate:"omitempty,oneof=password login_code"`
+}
+
+// FriendRequestRequest represents a request to send a friend request
+type FriendRequestRequest struct {
+	AddresseeID uuid.UUID `json:"addressee_id"`
+}
+
 // CreateGroupRequest represents the request to create a new group
 type CreateGroupRequest struct {
 	Name        string  `json:"name" validate:"required,min=1,max=100"`
@@ -162,16 +805,22 @@ type CreateGroupRequest struct {
 	MaxMembers  int     `json:"max_members" validate:"min=1,max=1000"`
 }
 
+// GroupAdminMFARequest sets whether a group requires 2FA for its admins
+type GroupAdminMFARequest struct {
+	Required bool `json:"required"`
+}
+
 // JoinGroupRequest represents the request to join a group
 type JoinGroupRequest struct {
 	JoinCode string `json:"join_code" validate:"required"`
 }
 
-// StartQuizRequest represents the request to start a new quiz
+// StartQuizRequest represents the request to start a new quiz. Omitted
+// parameters fall back to the user's preferences.
 type StartQuizRequest struct {
-	Clef            string `json:"clef" validate:"required,oneof=treble bass alto tenor"`
-	DurationSeconds int    `json:"duration_seconds" validate:"required,oneof=30 60 120"`
-	MaxLedgerLines  int    `json:"max_ledger_lines" validate:"required,min=0,max=3"`
+	Clef            *string `json:"clef" validate:"omitempty,oneof=treble bass alto tenor"`
+	DurationSeconds *int    `json:"duration_seconds" validate:"omitempty,oneof=30 60 120"`
+	MaxLedgerLines  *int    `json:"max_ledger_lines" validate:"omitempty,min=0,max=3"`
 }
 
 // SubmitAnswerRequest represents the request to submit a single answer (legacy - use batch instead)
@@ -235,3 +884,20 @@ type Question struct {
 	CorrectAnswer string   `json:"correctAnswer"`
 	Answers       []string `json:"answers"`
 }
+
+// FeatureFlagRequest represents the request to create or update a feature flag
+type FeatureFlagRequest struct {
+	Key               string      `json:"key" validate:"required,max=100"`
+	Description       string      `json:"description"`
+	Enabled           bool        `json:"enabled"`
+	RolloutPercentage int         `json:"rollout_percentage" validate:"min=0,max=100"`
+	TargetUserIDs     []uuid.UUID `json:"target_user_ids"`
+	TargetGroupIDs    []uuid.UUID `json:"target_group_ids"`
+	TargetRoles       []string    `json:"target_roles"`
+}
+
+// BootstrapResponse is everything the client needs right after sign-in
+type BootstrapResponse struct {
+	User         *User           `json:"user"`
+	FeatureFlags map[string]bool `json:"feature_flags"`
+}
@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+)
+
+// dataExportRepository implements the DataExportRepository interface
+type dataExportRepository struct {
+	db *database.DB
+}
+
+// NewDataExportRepository creates a new data export repository instance
+func NewDataExportRepository(db *database.DB) DataExportRepository {
+	return &dataExportRepository{db: db}
+}
+
+// Create stores a new export request
+func (r *dataExportRepository) Create(ctx context.Context, export *models.DataExport) error {
+	query := `
+		INSERT INTO data_exports (id, user_id, status, created_at)
+		VALUES ($1, $2, $3, $4)`
+
+	_, err := r.db.ExecContext(ctx, query, export.ID, export.UserID, export.Status, export.CreatedAt)
+	return err
+}
+
+// GetByID retrieves an export by ID
+func (r *dataExportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error) {
+	query := `
+		SELECT id, user_id, status, size_bytes, error, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE id = $1`
+
+	export, err := scanDataExport(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// GetUserExports retrieves a user's exports, newest first
+func (r *dataExportRepository) GetUserExports(ctx context.Context, userID uuid.UUID) ([]*models.DataExport, error) {
+	query := `
+		SELECT id, user_id, status, size_bytes, error, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE user_id = $1
+		ORDER BY created_at DESC`
+
+	return r.list(ctx, query, userID)
+}
+
+// MarkReady records that an export's archive has been written
+func (r *dataExportRepository) MarkReady(ctx context.Context, id uuid.UUID, sizeBytes int64, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'ready', size_bytes = $2, error = NULL, completed_at = NOW(), expires_at = $3
+		WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, sizeBytes, expiresAt)
+	return err
+}
+
+// MarkFailed records why an export could not be built
+func (r *dataExportRepository) MarkFailed(ctx context.Context, id uuid.UUID, reason string) error {
+	query := `
+		UPDATE data_exports
+		SET status = 'failed', error = $2, completed_at = NOW()
+		WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, reason)
+	return err
+}
+
+// GetExpired retrieves exports past their expiry
+func (r *dataExportRepository) GetExpired(ctx context.Context, limit int) ([]*models.DataExport, error) {
+	query := `
+		SELECT id, user_id, status, size_bytes, error, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE expires_at <= NOW()
+		ORDER BY expires_at
+		LIMIT $1`
+
+	return r.list(ctx, query, limit)
+}
+
+// Delete removes an export record
+func (r *dataExportRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM data_exports WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *dataExportRepository) list(ctx context.Context, query string, args ...any) ([]*models.DataExport, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []*models.DataExport
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+
+	return exports, rows.Err()
+}
+
+// scanDataExport scans a data_exports row selected in column order
+func scanDataExport(row rowScanner) (*models.DataExport, error) {
+	export := &models.DataExport{}
+	err := row.Scan(
+		&export.ID, &export.UserID, &export.Status, &export.SizeBytes, &export.Error,
+		&export.CreatedAt, &export.CompletedAt, &export.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// accountDataRepository implements the AccountDataRepository interface
+type accountDataRepository struct {
+	db *database.DB
+}
+
+// NewAccountDataRepository creates a new account data repository instance
+func NewAccountDataRepository(db *database.DB) AccountDataRepository {
+	return &accountDataRepository{db: db}
+}
+
+// Export reads the user's profile, friendships, the blocks they made, group
+// memberships, quiz sessions and quiz answers. Deactivated users are included so an export
+// requested before deactivation can still be built.
+func (r *accountDataRepository) Export(ctx context.Context, userID uuid.UUID) (*models.AccountData, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := &models.AccountData{User: user}
+
+	if data.Friendships, err = r.friendships(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Blocks, err = NewBlockRepository(r.db).ListByBlocker(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.GroupMemberships, err = r.groupMemberships(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.QuizSessions, err = r.quizSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.QuizAnswers, err = r.quizAnswers(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Preferences, err = NewUserPreferencesRepository(r.db).Get(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Purge erases the user's personal data. Friendships, blocks either way,
+// feed activities, sessions, tokens, API tokens, linked identities,
+// preferences, login codes and exports are deleted; quiz sessions, answers
+// and group memberships are kept against the anonymized user row so group
+// and leaderboard aggregates do not change.
+func (r *accountDataRepository) Purge(ctx context.Context, userID uuid.UUID) error {
+	statements := []string{
+		`DELETE FROM friendships WHERE requester_id = $1 OR addressee_id = $1`,
+		`DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1`,
+		`DELETE FROM user_activities WHERE user_id = $1`,
+		`DELETE FROM sessions WHERE user_id = $1`,
+		`DELETE FROM api_tokens WHERE user_id = $1`,
+		`DELETE FROM user_tokens WHERE user_id = $1`,
+		`DELETE FROM user_identities WHERE user_id = $1`,
+		`DELETE FROM oidc_login_states WHERE user_id = $1`,
+		`DELETE FROM data_exports WHERE user_id = $1`,
+		`DELETE FROM user_totp WHERE user_id = $1`,
+		`DELETE FROM mfa_recovery_codes WHERE user_id = $1`,
+		`DELETE FROM mfa_challenges WHERE user_id = $1`,
+		`DELETE FROM user_preferences WHERE user_id = $1`,
+		`DELETE FROM student_login_codes WHERE user_id = $1`,
+		`UPDATE quiz_sessions SET status = 'abandoned' WHERE user_id = $1 AND status = 'in_progress'`,
+		`
+		UPDATE users
+		SET email = 'deleted-' || id || '@deleted.invalid',
+		    username = 'deleted_' || replace(id::text, '-', ''),
+		    display_name = 'Deleted user', password_hash = '', avatar_url = NULL,
+		    avatar_key = NULL, site_role = 'student', firebase_uid = NULL,
+		    is_active = false, email_verified = false,
+		    managed_by_group_id = NULL, password_change_required = false,
+		    tokens_valid_after = NOW(), deletion_scheduled_at = NULL,
+		    deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1`,
+	}
+
+	for _, statement := range statements {
+		if _, err := r.db.ExecContext(ctx, statement, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *accountDataRepository) friendships(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error) {
+	query := `
+		SELECT id, requester_id, addressee_id, status, created_at, updated_at
+		FROM friendships
+		WHERE requester_id = $1 OR addressee_id = $1
+		ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var friendships []*models.Friendship
+	for rows.Next() {
+		friendship := &models.Friendship{}
+		err := rows.Scan(
+			&friendship.ID, &friendship.RequesterID, &friendship.AddresseeID,
+			&friendship.Status, &friendship.CreatedAt, &friendship.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		friendships = append(friendships, friendship)
+	}
+
+	return friendships, rows.Err()
+}
+
+func (r *accountDataRepository) groupMemberships(ctx context.Context, userID uuid.UUID) ([]*models.GroupMembership, error) {
+	query := `
+		SELECT id, user_id, group_id, role, joined_at
+		FROM group_memberships
+		WHERE user_id = $1
+		ORDER BY joined_at`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*models.GroupMembership
+	for rows.Next() {
+		membership, err := scanGroupMembership(rows)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, membership)
+	}
+
+	return memberships, rows.Err()
+}
+
+func (r *accountDataRepository) quizSessions(ctx context.Context, userID uuid.UUID) ([]*models.QuizSession, error) {
+	query := `
+		SELECT id, user_id, clef, duration_seconds, max_ledger_lines, score,
+		       total_questions, correct_answers, time_taken_seconds, started_at,
+		       completed_at, status, accuracy_percentage
+		FROM quiz_sessions
+		WHERE user_id = $1
+		ORDER BY started_at`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.QuizSession
+	for rows.Next() {
+		session, err := scanQuizSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *accountDataRepository) quizAnswers(ctx context.Context, userID uuid.UUID) ([]*models.QuizAnswer, error) {
+	query := `
+		SELECT qa.id, qa.quiz_session_id, qa.question_number, qa.correct_note,
+		       qa.user_answer, qa.is_correct, qa.time_taken_ms, qa.answered_at
+		FROM quiz_answers qa
+		JOIN quiz_sessions qs ON qs.id = qa.quiz_session_id
+		WHERE qs.user_id = $1
+		ORDER BY qs.started_at, qa.question_number`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var answers []*models.QuizAnswer
+	for rows.Next() {
+		answer := &models.QuizAnswer{}
+		err := rows.Scan(
+			&answer.ID, &answer.QuizSessionID, &answer.QuestionNumber, &answer.CorrectNote,
+			&answer.UserAnswer, &answer.IsCorrect, &answer.TimeTakenMs, &answer.AnsweredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, answer)
+	}
+
+	return answers, rows.Err()
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+)
+
+// activityRepository implements the ActivityRepository interface
+type activityRepository struct {
+	db *database.DB
+}
+
+// NewActivityRepository creates a new activity repository instance
+func NewActivityRepository(db *database.DB) ActivityRepository {
+	return &activityRepository{db: db}
+}
+
+// Create stores an activity, doing nothing if the user already has one of
+// the same kind and dedupe key
+func (r *activityRepository) Create(ctx context.Context, activity *models.Activity) error {
+	query := `
+		INSERT INTO user_activities (id, user_id, kind, dedupe_key, group_id, data, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, kind, dedupe_key) DO NOTHING`
+
+	_, err := r.db.ExecContext(ctx, query,
+		activity.ID, activity.UserID, activity.Kind, activity.DedupeKey,
+		activity.GroupID, []byte(activity.Data), activity.CreatedAt)
+
+	return err
+}
+
+// GetFeed retrieves up to limit activities of a user's accepted friends,
+// newest first, starting after the cursor if there is one. Friends who hide
+// their activity are left out.
+func (r *activityRepository) GetFeed(ctx context.Context, userID uuid.UUID, after *models.ActivityCursor, limit int) ([]*models.Activity, error) {
+	query := `
+		WITH friends AS (
+			SELECT CASE WHEN requester_id = $1 THEN addressee_id ELSE requester_id END AS user_id
+			FROM friendships
+			WHERE (requester_id = $1 OR addressee_id = $1) AND status = 'accepted'
+		)
+		SELECT a.id, a.user_id, a.kind, a.data, a.created_at,
+		       u.username, u.display_name, u.avatar_url
+		FROM user_activities a
+		JOIN friends f ON f.user_id = a.user_id
+		JOIN users u ON u.id = a.user_id
+		LEFT JOIN user_preferences p ON p.user_id = a.user_id
+		WHERE COALESCE(p.share_activity, true)
+		  AND ($2::timestamptz IS NULL OR (a.created_at, a.id) < ($2, $3))
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $4`
+
+	var afterTime any
+	var afterID uuid.UUID
+	if after != nil {
+		afterTime, afterID = after.CreatedAt, after.ID
+	}
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query, userID, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []*models.Activity
+	for rows.Next() {
+		activity := &models.Activity{User: &models.UserSummary{}}
+		var data []byte
+		err := rows.Scan(
+			&activity.ID, &activity.UserID, &activity.Kind, &data, &activity.CreatedAt,
+			&activity.User.Username, &activity.User.DisplayName, &activity.User.AvatarURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		activity.Data = data
+		activity.User.ID = activity.UserID
+		activities = append(activities, activity)
+	}
+
+	return activities, rows.Err()
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// apiTokenRepository implements the APITokenRepository interface
+type apiTokenRepository struct {
+	db *database.DB
+}
+
+// NewAPITokenRepository creates a new API token repository instance
+func NewAPITokenRepository(db *database.DB) APITokenRepository {
+	return &apiTokenRepository{db: db}
+}
+
+// apiTokenColumns lists the api_tokens columns in the order scanAPIToken reads them
+const apiTokenColumns = `id, user_id, name, token_prefix, token_hash, scopes, created_at, expires_at, last_used_at`
+
+// Create stores a new token
+func (r *apiTokenRepository) Create(ctx context.Context, token *models.APIToken) error {
+	query := `
+		INSERT INTO api_tokens (id, user_id, name, token_prefix, token_hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		token.ID, token.UserID, token.Name, token.Prefix, token.TokenHash,
+		pq.Array(scopeStrings(token.Scopes)), token.CreatedAt, token.ExpiresAt)
+
+	return err
+}
+
+// GetByHash retrieves a token by its hash, whether or not it has expired
+func (r *apiTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	query := `
+		SELECT ` + apiTokenColumns + `
+		FROM api_tokens
+		WHERE token_hash = $1`
+
+	token, err := scanAPIToken(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ListByUser retrieves a user's tokens, newest first
+func (r *apiTokenRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
+	query := `
+		SELECT ` + apiTokenColumns + `
+		FROM api_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*models.APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// CountByUser counts a user's tokens, expired ones included
+func (r *apiTokenRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_tokens WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+// Touch records that a token was just used
+func (r *apiTokenRepository) Touch(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Delete deletes one of a user's tokens, returning sql.ErrNoRows if the user
+// has no such token
+func (r *apiTokenRepository) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	query := `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// scanAPIToken scans an api_tokens row selected with apiTokenColumns
+func scanAPIToken(row rowScanner) (*models.APIToken, error) {
+	token := &models.APIToken{}
+	var scopes []string
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.Name, &token.Prefix, &token.TokenHash,
+		pq.Array(&scopes), &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = make([]models.APIScope, len(scopes))
+	for i, scope := range scopes {
+		token.Scopes[i] = models.APIScope(scope)
+	}
+	return token, nil
+}
+
+// scopeStrings converts scopes for use with pq.Array
+func scopeStrings(scopes []models.APIScope) []string {
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+	return values
+}
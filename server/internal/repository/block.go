@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+)
+
+// blockRepository implements the BlockRepository interface
+type blockRepository struct {
+	db *database.DB
+}
+
+// NewBlockRepository creates a new user block repository instance
+func NewBlockRepository(db *database.DB) BlockRepository {
+	return &blockRepository{db: db}
+}
+
+// Create stores a block, doing nothing if it already exists
+func (r *blockRepository) Create(ctx context.Context, block *models.UserBlock) error {
+	query := `
+		INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING`
+
+	_, err := r.db.ExecContext(ctx, query, block.BlockerID, block.BlockedID, block.CreatedAt)
+	return err
+}
+
+// GetBetween retrieves the blocks between two users, in either direction
+func (r *blockRepository) GetBetween(ctx context.Context, userID, otherID uuid.UUID) ([]*models.UserBlock, error) {
+	query := `
+		SELECT blocker_id, blocked_id, created_at
+		FROM user_blocks
+		WHERE (blocker_id = $1 AND blocked_id = $2)
+		   OR (blocker_id = $2 AND blocked_id = $1)`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, otherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []*models.UserBlock
+	for rows.Next() {
+		block := &models.UserBlock{}
+		if err := rows.Scan(&block.BlockerID, &block.BlockedID, &block.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, rows.Err()
+}
+
+// ListByBlocker retrieves the blocks a user has made, each with a summary of
+// the blocked user, newest first
+func (r *blockRepository) ListByBlocker(ctx context.Context, blockerID uuid.UUID) ([]*models.UserBlock, error) {
+	query := `
+		SELECT b.blocker_id, b.blocked_id, b.created_at,
+		       u.id, u.username, u.display_name, u.avatar_url
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []*models.UserBlock
+	for rows.Next() {
+		block := &models.UserBlock{User: &models.UserSummary{}}
+		err := rows.Scan(
+			&block.BlockerID, &block.BlockedID, &block.CreatedAt,
+			&block.User.ID, &block.User.Username, &block.User.DisplayName, &block.User.AvatarURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, rows.Err()
+}
+
+// Delete lifts a block, returning sql.ErrNoRows if there is no such block
+func (r *blockRepository) Delete(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+	result, err := r.db.ExecContext(ctx, query, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
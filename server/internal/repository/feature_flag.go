@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// featureFlagRepository implements the FeatureFlagRepository interface
+type featureFlagRepository struct {
+	db *database.DB
+}
+
+// NewFeatureFlagRepository creates a new feature flag repository instance
+func NewFeatureFlagRepository(db *database.DB) FeatureFlagRepository {
+	return &featureFlagRepository{db: db}
+}
+
+// List retrieves all feature flags
+func (r *featureFlagRepository) List(ctx context.Context) ([]*models.FeatureFlag, error) {
+	query := `
+		SELECT key, description, enabled, rollout_percentage, target_user_ids,
+		       target_group_ids, target_roles, created_at, updated_at
+		FROM feature_flags
+		ORDER BY key`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []*models.FeatureFlag
+	for rows.Next() {
+		flag, err := scanFeatureFlag(rows)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+
+	return flags, rows.Err()
+}
+
+// GetByKey retrieves a feature flag by key
+func (r *featureFlagRepository) GetByKey(ctx context.Context, key string) (*models.FeatureFlag, error) {
+	query := `
+		SELECT key, description, enabled, rollout_percentage, target_user_ids,
+		       target_group_ids, target_roles, created_at, updated_at
+		FROM feature_flags
+		WHERE key = $1`
+
+	flag, err := scanFeatureFlag(r.db.QueryRowContext(ctx, query, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return flag, nil
+}
+
+// Create creates a new feature flag
+func (r *featureFlagRepository) Create(ctx context.Context, flag *models.FeatureFlag) error {
+	query := `
+		INSERT INTO feature_flags (key, description, enabled, rollout_percentage, target_user_ids,
+		                           target_group_ids, target_roles, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		flag.Key, flag.Description, flag.Enabled, flag.RolloutPercentage,
+		pq.Array(uuidStrings(flag.TargetUserIDs)), pq.Array(uuidStrings(flag.TargetGroupIDs)),
+		pq.Array(flag.TargetRoles), flag.CreatedAt, flag.UpdatedAt)
+
+	return err
+}
+
+// Update updates an existing feature flag
+func (r *featureFlagRepository) Update(ctx context.Context, flag *models.FeatureFlag) error {
+	query := `
+		UPDATE feature_flags
+		SET description = $2, enabled = $3, rollout_percentage = $4, target_user_ids = $5,
+		    target_group_ids = $6, target_roles = $7, updated_at = $8
+		WHERE key = $1`
+
+	_, err := r.db.ExecContext(ctx, query,
+		flag.Key, flag.Description, flag.Enabled, flag.RolloutPercentage,
+		pq.Array(uuidStrings(flag.TargetUserIDs)), pq.Array(uuidStrings(flag.TargetGroupIDs)),
+		pq.Array(flag.TargetRoles), flag.UpdatedAt)
+
+	return err
+}
+
+// Delete removes a feature flag
+func (r *featureFlagRepository) Delete(ctx context.Context, key string) error {
+	query := `DELETE FROM feature_flags WHERE key = $1`
+	_, err := r.db.ExecContext(ctx, query, key)
+	return err
+}
+
+// scanFeatureFlag scans a feature_flags row selected in column order
+func scanFeatureFlag(row rowScanner) (*models.FeatureFlag, error) {
+	flag := &models.FeatureFlag{}
+	var userIDs, groupIDs, roles []string
+	err := row.Scan(
+		&flag.Key, &flag.Description, &flag.Enabled, &flag.RolloutPercentage,
+		pq.Array(&userIDs), pq.Array(&groupIDs), pq.Array(&roles),
+		&flag.CreatedAt, &flag.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag.TargetUserIDs, err = parseUUIDs(userIDs); err != nil {
+		return nil, err
+	}
+	if flag.TargetGroupIDs, err = parseUUIDs(groupIDs); err != nil {
+		return nil, err
+	}
+	flag.TargetRoles = roles
+
+	return flag, nil
+}
+
+// uuidStrings converts IDs for use with pq.Array
+func uuidStrings(ids []uuid.UUID) []string {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+	return values
+}
+
+// parseUUIDs converts a scanned UUID[] column back into IDs
+func parseUUIDs(values []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, value := range values {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
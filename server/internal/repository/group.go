@@ -2,86 +2,296 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/andy-dam/iq-theory/server/pkg/database"
 	"github.com/google/uuid"
 )
 
-// Placeholder implementations - TODO: Implement these properly
-
+// groupRepository implements the GroupRepository interface
 type groupRepository struct {
 	db *database.DB
 }
 
+// NewGroupRepository creates a new group repository instance
 func NewGroupRepository(db *database.DB) GroupRepository {
 	return &groupRepository{db: db}
 }
 
+// Create creates a new group
 func (r *groupRepository) Create(ctx context.Context, group *models.Group) error {
-	// TODO: Implement
-	return nil
+	query := `
+		INSERT INTO groups (id, name, description, join_code, created_by, created_at, updated_at, is_active, max_members, require_admin_2fa)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		group.ID, group.Name, group.Description, group.JoinCode, group.CreatedBy,
+		group.CreatedAt, group.UpdatedAt, group.IsActive, group.MaxMembers, group.RequireAdmin2FA)
+
+	return err
 }
 
+// GetByID retrieves an active group by ID
 func (r *groupRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Group, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, name, description, join_code, created_by, created_at, updated_at, is_active, max_members,
+		       require_admin_2fa
+		FROM groups
+		WHERE id = $1 AND is_active = true`
+
+	group, err := scanGroup(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return group, nil
 }
 
+// LockByID retrieves an active group and locks its row until the
+// transaction ends, so concurrent joins are checked against the member limit
+// one at a time
+func (r *groupRepository) LockByID(ctx context.Context, id uuid.UUID) (*models.Group, error) {
+	query := `
+		SELECT id, name, description, join_code, created_by, created_at, updated_at, is_active, max_members,
+		       require_admin_2fa
+		FROM groups
+		WHERE id = $1 AND is_active = true
+		FOR UPDATE`
+
+	group, err := scanGroup(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// GetByJoinCode retrieves an active group by its join code
 func (r *groupRepository) GetByJoinCode(ctx context.Context, joinCode string) (*models.Group, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, name, description, join_code, created_by, created_at, updated_at, is_active, max_members,
+		       require_admin_2fa
+		FROM groups
+		WHERE join_code = $1 AND is_active = true`
+
+	group, err := scanGroup(r.db.QueryRowContext(ctx, query, joinCode))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return group, nil
 }
 
+// GetUserGroups retrieves all active groups a user is a member of
 func (r *groupRepository) GetUserGroups(ctx context.Context, userID uuid.UUID) ([]*models.Group, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT g.id, g.name, g.description, g.join_code, g.created_by, g.created_at,
+		       g.updated_at, g.is_active, g.max_members, g.require_admin_2fa
+		FROM groups g
+		JOIN group_memberships gm ON gm.group_id = g.id
+		WHERE gm.user_id = $1 AND g.is_active = true
+		ORDER BY gm.joined_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*models.Group
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
 }
 
+// Update updates an existing group
 func (r *groupRepository) Update(ctx context.Context, group *models.Group) error {
-	// TODO: Implement
-	return nil
+	query := `
+		UPDATE groups
+		SET name = $2, description = $3, join_code = $4, updated_at = $5, is_active = $6, max_members = $7,
+		    require_admin_2fa = $8
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query,
+		group.ID, group.Name, group.Description, group.JoinCode,
+		group.UpdatedAt, group.IsActive, group.MaxMembers, group.RequireAdmin2FA)
+
+	return err
 }
 
+// Delete soft deletes a group (sets is_active to false)
 func (r *groupRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	// TODO: Implement
-	return nil
+	query := `UPDATE groups SET is_active = false, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// scanGroup scans a groups row selected in column order
+func scanGroup(row rowScanner) (*models.Group, error) {
+	group := &models.Group{}
+	err := row.Scan(
+		&group.ID, &group.Name, &group.Description, &group.JoinCode, &group.CreatedBy,
+		&group.CreatedAt, &group.UpdatedAt, &group.IsActive, &group.MaxMembers, &group.RequireAdmin2FA,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
 }
 
+// groupMembershipRepository implements the GroupMembershipRepository interface
 type groupMembershipRepository struct {
 	db *database.DB
 }
 
+// NewGroupMembershipRepository creates a new group membership repository instance
 func NewGroupMembershipRepository(db *database.DB) GroupMembershipRepository {
 	return &groupMembershipRepository{db: db}
 }
 
+// Create adds a user to a group
 func (r *groupMembershipRepository) Create(ctx context.Context, membership *models.GroupMembership) error {
-	// TODO: Implement
-	return nil
+	query := `
+		INSERT INTO group_memberships (id, user_id, group_id, role, joined_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		membership.ID, membership.UserID, membership.GroupID, membership.Role, membership.JoinedAt)
+
+	return err
 }
 
+// GetByID retrieves a membership by ID
 func (r *groupMembershipRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.GroupMembership, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, user_id, group_id, role, joined_at
+		FROM group_memberships
+		WHERE id = $1`
+
+	membership, err := scanGroupMembership(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return membership, nil
 }
 
+// GetByUserAndGroup retrieves a user's membership in a group
+func (r *groupMembershipRepository) GetByUserAndGroup(ctx context.Context, userID, groupID uuid.UUID) (*models.GroupMembership, error) {
+	query := `
+		SELECT id, user_id, group_id, role, joined_at
+		FROM group_memberships
+		WHERE user_id = $1 AND group_id = $2`
+
+	membership, err := scanGroupMembership(r.db.QueryRowContext(ctx, query, userID, groupID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// GetGroupMembers retrieves all memberships of a group
 func (r *groupMembershipRepository) GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]*models.GroupMembership, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, user_id, group_id, role, joined_at
+		FROM group_memberships
+		WHERE group_id = $1
+		ORDER BY joined_at`
+
+	return r.list(ctx, query, groupID)
 }
 
+// GetUserMemberships retrieves all memberships of a user
 func (r *groupMembershipRepository) GetUserMemberships(ctx context.Context, userID uuid.UUID) ([]*models.GroupMembership, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, user_id, group_id, role, joined_at
+		FROM group_memberships
+		WHERE user_id = $1
+		ORDER BY joined_at`
+
+	return r.list(ctx, query, userID)
 }
 
+// UpdateRole updates a member's role
 func (r *groupMembershipRepository) UpdateRole(ctx context.Context, id uuid.UUID, role string) error {
-	// TODO: Implement
-	return nil
+	query := `UPDATE group_memberships SET role = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, role)
+	return err
 }
 
+// SharesGroup reports whether two users are members of the same active group
+func (r *groupMembershipRepository) SharesGroup(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM group_memberships a
+			JOIN group_memberships b ON b.group_id = a.group_id AND b.user_id = $2
+			JOIN groups g ON g.id = a.group_id AND g.is_active
+			WHERE a.user_id = $1
+		)`
+
+	var shares bool
+	err := r.db.QueryRowContext(ctx, query, userID, otherID).Scan(&shares)
+	return shares, err
+}
+
+// Delete removes a membership
 func (r *groupMembershipRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	// TODO: Implement
-	return nil
+	query := `DELETE FROM group_memberships WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *groupMembershipRepository) list(ctx context.Context, query string, args ...any) ([]*models.GroupMembership, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*models.GroupMembership
+	for rows.Next() {
+		membership, err := scanGroupMembership(rows)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, membership)
+	}
+
+	return memberships, rows.Err()
+}
+
+// scanGroupMembership scans a group_memberships row selected in column order
+func scanGroupMembership(row rowScanner) (*models.GroupMembership, error) {
+	membership := &models.GroupMembership{}
+	err := row.Scan(
+		&membership.ID, &membership.UserID, &membership.GroupID, &membership.Role, &membership.JoinedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
 }
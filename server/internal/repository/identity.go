@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+)
+
+// userIdentityRepository implements the UserIdentityRepository interface
+type userIdentityRepository struct {
+	db *database.DB
+}
+
+// NewUserIdentityRepository creates a new user identity repository instance
+func NewUserIdentityRepository(db *database.DB) UserIdentityRepository {
+	return &userIdentityRepository{db: db}
+}
+
+// Create links an external identity to a user
+func (r *userIdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		identity.ID, identity.UserID, identity.Provider, identity.Subject,
+		identity.Email, identity.CreatedAt, identity.LastUsedAt)
+
+	return err
+}
+
+// GetByID retrieves a linked identity by ID
+func (r *userIdentityRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_used_at
+		FROM user_identities
+		WHERE id = $1`
+
+	identity, err := scanUserIdentity(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// GetByProviderSubject retrieves the identity a provider knows as subject
+func (r *userIdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_used_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2`
+
+	identity, err := scanUserIdentity(r.db.QueryRowContext(ctx, query, provider, subject))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// GetUserIdentities retrieves all identities linked to a user
+func (r *userIdentityRepository) GetUserIdentities(ctx context.Context, userID uuid.UUID) ([]*models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_used_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []*models.UserIdentity
+	for rows.Next() {
+		identity, err := scanUserIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+// Touch records a sign-in with the identity
+func (r *userIdentityRepository) Touch(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE user_identities SET last_used_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Delete unlinks an identity
+func (r *userIdentityRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM user_identities WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// scanUserIdentity scans a user_identities row selected in column order
+func scanUserIdentity(row rowScanner) (*models.UserIdentity, error) {
+	identity := &models.UserIdentity{}
+	err := row.Scan(
+		&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject,
+		&identity.Email, &identity.CreatedAt, &identity.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// oidcLoginStateRepository implements the OIDCLoginStateRepository interface
+type oidcLoginStateRepository struct {
+	db *database.DB
+}
+
+// NewOIDCLoginStateRepository creates a new OIDC login state repository instance
+func NewOIDCLoginStateRepository(db *database.DB) OIDCLoginStateRepository {
+	return &oidcLoginStateRepository{db: db}
+}
+
+// Create stores the state of a login that is being started
+func (r *oidcLoginStateRepository) Create(ctx context.Context, state *models.OIDCLoginState) error {
+	query := `
+		INSERT INTO oidc_login_states (state_hash, provider, code_verifier, nonce, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		state.StateHash, state.Provider, state.CodeVerifier, state.Nonce,
+		state.UserID, state.ExpiresAt, state.CreatedAt)
+
+	return err
+}
+
+// Consume deletes and returns an unexpired login state, so each state can
+// complete at most one login. Expired states are cleaned up on the way.
+func (r *oidcLoginStateRepository) Consume(ctx context.Context, stateHash string) (*models.OIDCLoginState, error) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM oidc_login_states WHERE expires_at < NOW()`); err != nil {
+		return nil, err
+	}
+
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1
+		RETURNING state_hash, provider, code_verifier, nonce, user_id, expires_at, created_at`
+
+	state := &models.OIDCLoginState{}
+	err := r.db.QueryRowContext(ctx, query, stateHash).Scan(
+		&state.StateHash, &state.Provider, &state.CodeVerifier, &state.Nonce,
+		&state.UserID, &state.ExpiresAt, &state.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
@@ -2,34 +2,185 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/google/uuid"
 )
 
+// Transactor runs a function inside a database transaction. Repository calls
+// made with the context passed to fn join the transaction.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 // UserRepository defines methods for user data access
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByFirebaseUID(ctx context.Context, firebaseUID string) (*models.User, error)
+	Search(ctx context.Context, query string, limit, offset int) ([]*models.User, error)
 	Update(ctx context.Context, user *models.User) error
-	Delete(ctx context.Context, id uuid.UUID) error
+	SetFirebaseUID(ctx context.Context, id uuid.UUID, firebaseUID string) error
+	SetAvatar(ctx context.Context, id uuid.UUID, avatarURL, avatarKey *string) error
+	SetSiteRole(ctx context.Context, id uuid.UUID, role string) error
+	ListBySiteRole(ctx context.Context, role string) ([]*models.User, error)
+	UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error
+	Delete(ctx context.Context, id uuid.UUID, purgeAt time.Time) error
+	GetDueForDeletion(ctx context.Context, limit int) ([]uuid.UUID, error)
+	ConvertGuest(ctx context.Context, user *models.User) error
+	DeleteGuest(ctx context.Context, id uuid.UUID) error
+	DeleteStaleGuests(ctx context.Context, cutoff time.Time, limit int) (int, error)
 }
 
 // FriendshipRepository defines methods for friendship data access
 type FriendshipRepository interface {
 	Create(ctx context.Context, friendship *models.Friendship) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Friendship, error)
+	GetBetween(ctx context.Context, userID, otherID uuid.UUID) (*models.Friendship, error)
 	GetUserFriends(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error)
+	GetIncoming(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error)
+	GetOutgoing(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error)
+	GetSuggestions(ctx context.Context, userID uuid.UUID, activeSince time.Time, limit int) ([]*models.FriendSuggestion, error)
+	Update(ctx context.Context, friendship *models.Friendship) error
 	UpdateStatus(ctx context.Context, id uuid.UUID, status string) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+// BlockRepository defines methods for user block data access
+type BlockRepository interface {
+	Create(ctx context.Context, block *models.UserBlock) error
+	GetBetween(ctx context.Context, userID, otherID uuid.UUID) ([]*models.UserBlock, error)
+	ListByBlocker(ctx context.Context, blockerID uuid.UUID) ([]*models.UserBlock, error)
+	Delete(ctx context.Context, blockerID, blockedID uuid.UUID) error
+}
+
+// ActivityRepository defines methods for friend activity feed data access
+type ActivityRepository interface {
+	Create(ctx context.Context, activity *models.Activity) error
+	GetFeed(ctx context.Context, userID uuid.UUID, after *models.ActivityCursor, limit int) ([]*models.Activity, error)
+}
+
+// UserTokenRepository defines methods for single-use user token data access
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	GetByHash(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error)
+	GetLatest(ctx context.Context, userID uuid.UUID, purpose string) (*models.UserToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+	InvalidateAll(ctx context.Context, userID uuid.UUID, purpose string) error
+}
+
+// UserIdentityRepository defines methods for linked external identity data access
+type UserIdentityRepository interface {
+	Create(ctx context.Context, identity *models.UserIdentity) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.UserIdentity, error)
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	GetUserIdentities(ctx context.Context, userID uuid.UUID) ([]*models.UserIdentity, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// OIDCLoginStateRepository defines methods for in-flight OIDC login data access
+type OIDCLoginStateRepository interface {
+	Create(ctx context.Context, state *models.OIDCLoginState) error
+	Consume(ctx context.Context, stateHash string) (*models.OIDCLoginState, error)
+}
+
+// LoginThrottleRepository defines methods for failed sign-in counter data access
+type LoginThrottleRepository interface {
+	Get(ctx context.Context, scope, subject string) (*models.LoginThrottle, error)
+	RecordFailure(ctx context.Context, scope, subject string, forgetBefore time.Time) (*models.LoginThrottle, error)
+	Lock(ctx context.Context, scope, subject string, until time.Time) error
+	Clear(ctx context.Context, scope, subject string) error
+	DeleteStale(ctx context.Context, before time.Time) (int, error)
+}
+
+// APITokenRepository defines methods for personal access token data access
+type APITokenRepository interface {
+	Create(ctx context.Context, token *models.APIToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.APIToken, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error)
+	CountByUser(ctx context.Context, userID uuid.UUID) (int, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id, userID uuid.UUID) error
+}
+
+// StudentLoginCodeRepository defines methods for managed student login code
+// data access
+type StudentLoginCodeRepository interface {
+	Upsert(ctx context.Context, code *models.StudentLoginCode) error
+	GetByHash(ctx context.Context, codeHash string) (*models.StudentLoginCode, error)
+	Touch(ctx context.Context, userID uuid.UUID) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
+
+// UserTOTPRepository defines methods for authenticator app enrolment data access
+type UserTOTPRepository interface {
+	Get(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, error)
+	// SavePending stores an unconfirmed secret. It returns sql.ErrNoRows if
+	// the user's enrolment is already confirmed.
+	SavePending(ctx context.Context, userID uuid.UUID, secret string) error
+	Confirm(ctx context.Context, userID uuid.UUID, step int64) error
+	// UseStep returns sql.ErrNoRows if the step was already used
+	UseStep(ctx context.Context, userID uuid.UUID, step int64) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
+
+// RecoveryCodeRepository defines methods for 2FA recovery code data access
+type RecoveryCodeRepository interface {
+	ReplaceAll(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	// Use returns sql.ErrNoRows if there is no matching unused code
+	Use(ctx context.Context, userID uuid.UUID, codeHash string) error
+	CountUnused(ctx context.Context, userID uuid.UUID) (int, error)
+	DeleteAll(ctx context.Context, userID uuid.UUID) error
+}
+
+// MFAChallengeRepository defines methods for pending second login step data access
+type MFAChallengeRepository interface {
+	Create(ctx context.Context, challenge *models.MFAChallenge) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.MFAChallenge, error)
+	IncrementAttempts(ctx context.Context, tokenHash string) (int, error)
+	Delete(ctx context.Context, tokenHash string) error
+}
+
+// SessionRepository defines methods for login session data access
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Session, error)
+	GetUserSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	Revoke(ctx context.Context, id uuid.UUID) error
+	RevokeAll(ctx context.Context, userID uuid.UUID) error
+}
+
+// DataExportRepository defines methods for personal data export data access
+type DataExportRepository interface {
+	Create(ctx context.Context, export *models.DataExport) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error)
+	GetUserExports(ctx context.Context, userID uuid.UUID) ([]*models.DataExport, error)
+	MarkReady(ctx context.Context, id uuid.UUID, sizeBytes int64, expiresAt time.Time) error
+	MarkFailed(ctx context.Context, id uuid.UUID, reason string) error
+	// GetExpired retrieves exports whose archive should be removed
+	GetExpired(ctx context.Context, limit int) ([]*models.DataExport, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// AccountDataRepository reads and erases everything stored about a user
+type AccountDataRepository interface {
+	Export(ctx context.Context, userID uuid.UUID) (*models.AccountData, error)
+	// Purge removes the user's personal data and anonymizes their user row,
+	// keeping quiz results and group memberships for aggregate statistics.
+	// Call it with a transactional context.
+	Purge(ctx context.Context, userID uuid.UUID) error
+}
+
 // GroupRepository defines methods for group data access
 type GroupRepository interface {
 	Create(ctx context.Context, group *models.Group) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Group, error)
+	LockByID(ctx context.Context, id uuid.UUID) (*models.Group, error)
 	GetByJoinCode(ctx context.Context, joinCode string) (*models.Group, error)
 	GetUserGroups(ctx context.Context, userID uuid.UUID) ([]*models.Group, error)
 	Update(ctx context.Context, group *models.Group) error
@@ -40,12 +191,20 @@ type GroupRepository interface {
 type GroupMembershipRepository interface {
 	Create(ctx context.Context, membership *models.GroupMembership) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.GroupMembership, error)
+	GetByUserAndGroup(ctx context.Context, userID, groupID uuid.UUID) (*models.GroupMembership, error)
 	GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]*models.GroupMembership, error)
 	GetUserMemberships(ctx context.Context, userID uuid.UUID) ([]*models.GroupMembership, error)
+	SharesGroup(ctx context.Context, userID, otherID uuid.UUID) (bool, error)
 	UpdateRole(ctx context.Context, id uuid.UUID, role string) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+// UserPreferencesRepository defines methods for user preference data access
+type UserPreferencesRepository interface {
+	Get(ctx context.Context, userID uuid.UUID) (*models.UserPreferences, error)
+	Upsert(ctx context.Context, prefs *models.UserPreferences) error
+}
+
 // QuizRepository defines methods for quiz configuration data access
 type QuizRepository interface {
 	GetClefTypes(ctx context.Context) ([]*models.ClefType, error)
@@ -61,6 +220,19 @@ type QuizSessionRepository interface {
 	GetUserSessions(ctx context.Context, userID uuid.UUID, limit int) ([]*models.QuizSession, error)
 	Update(ctx context.Context, session *models.QuizSession) error
 	Complete(ctx context.Context, id uuid.UUID, score int, timeTaken int) error
+	CountCompleted(ctx context.Context, userID uuid.UUID) (int, error)
+	GetBestScoresByClef(ctx context.Context, userID uuid.UUID) ([]*models.ClefBestScore, error)
+	// GetActiveDays returns the UTC dates with a completed session, newest
+	// first
+	GetActiveDays(ctx context.Context, userID uuid.UUID, limit int) ([]time.Time, error)
+	// GetBestScoreBefore returns the user's best score for a quiz
+	// configuration among sessions completed before the given time, or nil
+	// if there are none
+	GetBestScoreBefore(ctx context.Context, userID uuid.UUID, clef string, duration, maxLedgerLines int, before time.Time) (*int, error)
+	GetConfigStats(ctx context.Context, userID, otherID uuid.UUID, trendWindow int) ([]*models.ConfigStats, error)
+	// Reassign moves every session of one user to another and returns how
+	// many were moved
+	Reassign(ctx context.Context, fromUserID, toUserID uuid.UUID) (int, error)
 }
 
 // QuizAnswerRepository defines methods for quiz answer data access
@@ -69,11 +241,33 @@ type QuizAnswerRepository interface {
 	GetBySessionID(ctx context.Context, sessionID uuid.UUID) ([]*models.QuizAnswer, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*models.QuizAnswer, error)
 	Update(ctx context.Context, answer *models.QuizAnswer) error
+	GetNoteAccuracy(ctx context.Context, userID, otherID uuid.UUID) ([]*models.NoteAccuracy, error)
 }
 
 // LeaderboardRepository defines methods for leaderboard data access
 type LeaderboardRepository interface {
 	GetGlobalLeaderboard(ctx context.Context, clef string, duration int, maxLedgerLines int, limit int) ([]*models.LeaderboardEntry, error)
 	GetUserRanking(ctx context.Context, userID uuid.UUID, clef string, duration int, maxLedgerLines int) (*models.LeaderboardEntry, error)
+	GetFriendsLeaderboard(ctx context.Context, userID uuid.UUID, clef string, duration int, maxLedgerLines int, limit, offset int) ([]*models.LeaderboardEntry, error)
 	RefreshLeaderboard(ctx context.Context) error
 }
+
+// OutboxRepository defines methods for transactional outbox data access
+type OutboxRepository interface {
+	Create(ctx context.Context, event *models.OutboxEvent) error
+	// ClaimPending leases up to limit due events so that concurrent
+	// dispatchers do not deliver them at the same time
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error)
+	MarkDelivered(ctx context.Context, id uuid.UUID) error
+	// MarkFailed records a failed delivery. A nil retryAt gives up on the event.
+	MarkFailed(ctx context.Context, id uuid.UUID, lastError string, retryAt *time.Time) error
+}
+
+// FeatureFlagRepository defines methods for feature flag data access
+type FeatureFlagRepository interface {
+	List(ctx context.Context) ([]*models.FeatureFlag, error)
+	GetByKey(ctx context.Context, key string) (*models.FeatureFlag, error)
+	Create(ctx context.Context, flag *models.FeatureFlag) error
+	Update(ctx context.Context, flag *models.FeatureFlag) error
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+)
+
+// loginThrottleRepository implements the LoginThrottleRepository interface
+type loginThrottleRepository struct {
+	db *database.DB
+}
+
+// NewLoginThrottleRepository creates a new login throttle repository instance
+func NewLoginThrottleRepository(db *database.DB) LoginThrottleRepository {
+	return &loginThrottleRepository{db: db}
+}
+
+// Get retrieves the failed sign-in counter for a subject
+func (r *loginThrottleRepository) Get(ctx context.Context, scope, subject string) (*models.LoginThrottle, error) {
+	query := `
+		SELECT scope, subject, failures, lockouts, locked_until, last_failure_at
+		FROM login_throttles
+		WHERE scope = $1 AND subject = $2`
+
+	throttle, err := scanLoginThrottle(r.db.QueryRowContext(ctx, query, scope, subject))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return throttle, nil
+}
+
+// RecordFailure counts a failed sign-in and returns the updated counter. A
+// counter whose last failure is before forgetBefore starts over.
+func (r *loginThrottleRepository) RecordFailure(ctx context.Context, scope, subject string, forgetBefore time.Time) (*models.LoginThrottle, error) {
+	query := `
+		INSERT INTO login_throttles (scope, subject, failures, lockouts, last_failure_at)
+		VALUES ($1, $2, 1, 0, NOW())
+		ON CONFLICT (scope, subject) DO UPDATE
+		SET failures = CASE WHEN login_throttles.last_failure_at < $3 THEN 1 ELSE login_throttles.failures + 1 END,
+		    lockouts = CASE WHEN login_throttles.last_failure_at < $3 THEN 0 ELSE login_throttles.lockouts END,
+		    last_failure_at = NOW()
+		RETURNING scope, subject, failures, lockouts, locked_until, last_failure_at`
+
+	return scanLoginThrottle(r.db.QueryRowContext(ctx, query, scope, subject, forgetBefore))
+}
+
+// Lock locks a subject until the given time, counting the lockout and
+// resetting its failures
+func (r *loginThrottleRepository) Lock(ctx context.Context, scope, subject string, until time.Time) error {
+	query := `
+		UPDATE login_throttles
+		SET locked_until = $3, lockouts = lockouts + 1, failures = 0
+		WHERE scope = $1 AND subject = $2`
+
+	_, err := r.db.ExecContext(ctx, query, scope, subject, until)
+	return err
+}
+
+// Clear forgets a subject's failures and lifts any lockout
+func (r *loginThrottleRepository) Clear(ctx context.Context, scope, subject string) error {
+	query := `DELETE FROM login_throttles WHERE scope = $1 AND subject = $2`
+	_, err := r.db.ExecContext(ctx, query, scope, subject)
+	return err
+}
+
+// DeleteStale removes counters with no failures since before and no
+// lockout still running
+func (r *loginThrottleRepository) DeleteStale(ctx context.Context, before time.Time) (int, error) {
+	query := `
+		DELETE FROM login_throttles
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < NOW())`
+
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// scanLoginThrottle scans a login_throttles row selected in column order
+func scanLoginThrottle(row rowScanner) (*models.LoginThrottle, error) {
+	throttle := &models.LoginThrottle{}
+	err := row.Scan(
+		&throttle.Scope, &throttle.Subject, &throttle.Failures, &throttle.Lockouts,
+		&throttle.LockedUntil, &throttle.LastFailureAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return throttle, nil
+}
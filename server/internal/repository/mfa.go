@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+)
+
+// userTOTPRepository implements the UserTOTPRepository interface
+type userTOTPRepository struct {
+	db *database.DB
+}
+
+// NewUserTOTPRepository creates a new user TOTP repository instance
+func NewUserTOTPRepository(db *database.DB) UserTOTPRepository {
+	return &userTOTPRepository{db: db}
+}
+
+// Get retrieves a user's TOTP enrolment, confirmed or not
+func (r *userTOTPRepository) Get(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, error) {
+	query := `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM user_totp
+		WHERE user_id = $1`
+
+	totp := &models.UserTOTP{}
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&totp.UserID, &totp.Secret, &totp.ConfirmedAt, &totp.LastUsedStep, &totp.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return totp, nil
+}
+
+// SavePending stores a new unconfirmed secret, replacing an earlier pending
+// one. It returns sql.ErrNoRows if the user already has a confirmed
+// enrolment.
+func (r *userTOTPRepository) SavePending(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret, confirmed_at, last_used_step, created_at)
+		VALUES ($1, $2, NULL, 0, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_totp.confirmed_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Confirm enables a pending enrolment, recording the step of the code that
+// confirmed it
+func (r *userTOTPRepository) Confirm(ctx context.Context, userID uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET confirmed_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UseStep records that the code for step was used. It returns sql.ErrNoRows
+// if that step or a later one was already used, so a code cannot be
+// replayed.
+func (r *userTOTPRepository) UseStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`
+
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete removes a user's enrolment
+func (r *userTOTPRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM user_totp WHERE user_id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// recoveryCodeRepository implements the RecoveryCodeRepository interface
+type recoveryCodeRepository struct {
+	db *database.DB
+}
+
+// NewRecoveryCodeRepository creates a new recovery code repository instance
+func NewRecoveryCodeRepository(db *database.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+// ReplaceAll discards a user's recovery codes and stores new hashes
+func (r *recoveryCodeRepository) ReplaceAll(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	if err := r.DeleteAll(ctx, userID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+		VALUES ($1, $2, $3, NOW())`
+
+	for _, hash := range codeHashes {
+		if _, err := r.db.ExecContext(ctx, query, uuid.New(), userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Use marks an unused recovery code as used. It returns sql.ErrNoRows if
+// the user has no such unused code.
+func (r *recoveryCodeRepository) Use(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CountUnused counts a user's remaining recovery codes
+func (r *recoveryCodeRepository) CountUnused(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// DeleteAll removes all of a user's recovery codes
+func (r *recoveryCodeRepository) DeleteAll(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM mfa_recovery_codes WHERE user_id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// mfaChallengeRepository implements the MFAChallengeRepository interface
+type mfaChallengeRepository struct {
+	db *database.DB
+}
+
+// NewMFAChallengeRepository creates a new MFA challenge repository instance
+func NewMFAChallengeRepository(db *database.DB) MFAChallengeRepository {
+	return &mfaChallengeRepository{db: db}
+}
+
+// Create stores a challenge issued after the first login step. Expired
+// challenges are cleaned up on the way.
+func (r *mfaChallengeRepository) Create(ctx context.Context, challenge *models.MFAChallenge) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO mfa_challenges (token_hash, user_id, attempts, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		challenge.TokenHash, challenge.UserID, challenge.Attempts,
+		challenge.ExpiresAt, challenge.CreatedAt)
+
+	return err
+}
+
+// GetByHash retrieves a challenge by the hash of its token
+func (r *mfaChallengeRepository) GetByHash(ctx context.Context, tokenHash string) (*models.MFAChallenge, error) {
+	query := `
+		SELECT token_hash, user_id, attempts, expires_at, created_at
+		FROM mfa_challenges
+		WHERE token_hash = $1`
+
+	challenge := &models.MFAChallenge{}
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&challenge.TokenHash, &challenge.UserID, &challenge.Attempts,
+		&challenge.ExpiresAt, &challenge.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// IncrementAttempts records a failed code and returns the new attempt count
+func (r *mfaChallengeRepository) IncrementAttempts(ctx context.Context, tokenHash string) (int, error) {
+	query := `
+		UPDATE mfa_challenges
+		SET attempts = attempts + 1
+		WHERE token_hash = $1
+		RETURNING attempts`
+
+	var attempts int
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&attempts)
+	return attempts, err
+}
+
+// Delete removes a challenge once it is redeemed or exhausted
+func (r *mfaChallengeRepository) Delete(ctx context.Context, tokenHash string) error {
+	query := `DELETE FROM mfa_challenges WHERE token_hash = $1`
+	_, err := r.db.ExecContext(ctx, query, tokenHash)
+	return err
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+)
+
+// outboxRepository implements the OutboxRepository interface
+type outboxRepository struct {
+	db *database.DB
+}
+
+// NewOutboxRepository creates a new outbox repository instance
+func NewOutboxRepository(db *database.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// Create writes an event to the outbox. Call it with a transactional context
+// so the event commits or rolls back together with the change it describes.
+func (r *outboxRepository) Create(ctx context.Context, event *models.OutboxEvent) error {
+	query := `
+		INSERT INTO outbox_events (id, event_type, aggregate_id, payload, occurred_at, status, attempts, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		event.ID, event.EventType, event.AggregateID, []byte(event.Payload),
+		event.OccurredAt, event.Status, event.Attempts, event.NextAttemptAt)
+
+	return err
+}
+
+// ClaimPending leases due events and increments their attempt count
+func (r *outboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	query := `
+		UPDATE outbox_events
+		SET locked_until = NOW() + $2 * INTERVAL '1 second', attempts = attempts + 1
+		WHERE id IN (
+			SELECT id
+			FROM outbox_events
+			WHERE status = 'pending'
+			  AND next_attempt_at <= NOW()
+			  AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY occurred_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_id, payload, occurred_at, status,
+		          attempts, next_attempt_at, last_error, delivered_at`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		event := &models.OutboxEvent{}
+		var payload []byte
+		err := rows.Scan(
+			&event.ID, &event.EventType, &event.AggregateID, &payload, &event.OccurredAt,
+			&event.Status, &event.Attempts, &event.NextAttemptAt, &event.LastError, &event.DeliveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not preserve the subquery order
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].OccurredAt.Before(events[j].OccurredAt)
+	})
+
+	return events, nil
+}
+
+// MarkDelivered marks an event as delivered to every subscriber
+func (r *outboxRepository) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE outbox_events
+		SET status = 'delivered', delivered_at = NOW(), locked_until = NULL, last_error = NULL
+		WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkFailed schedules a retry, or marks the event failed when retryAt is nil
+func (r *outboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, lastError string, retryAt *time.Time) error {
+	if retryAt == nil {
+		query := `
+			UPDATE outbox_events
+			SET status = 'failed', locked_until = NULL, last_error = $2
+			WHERE id = $1`
+		_, err := r.db.ExecContext(ctx, query, id, lastError)
+		return err
+	}
+
+	query := `
+		UPDATE outbox_events
+		SET next_attempt_at = $3, locked_until = NULL, last_error = $2
+		WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, lastError, *retryAt)
+	return err
+}
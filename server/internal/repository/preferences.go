@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+)
+
+// userPreferencesRepository implements the UserPreferencesRepository interface
+type userPreferencesRepository struct {
+	db *database.DB
+}
+
+// NewUserPreferencesRepository creates a new user preferences repository instance
+func NewUserPreferencesRepository(db *database.DB) UserPreferencesRepository {
+	return &userPreferencesRepository{db: db}
+}
+
+// Get retrieves a user's stored preferences, or nil if they never saved any
+func (r *userPreferencesRepository) Get(ctx context.Context, userID uuid.UUID) (*models.UserPreferences, error) {
+	query := `
+		SELECT user_id, default_clef, default_duration_seconds, default_max_ledger_lines,
+		       note_naming, answer_input, sound_enabled, timezone, share_activity, discoverable, updated_at
+		FROM user_preferences
+		WHERE user_id = $1`
+
+	prefs, err := scanUserPreferences(r.db.QueryRowContext(ctx, query, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return prefs, nil
+}
+
+// Upsert saves every preference, creating the row on first save
+func (r *userPreferencesRepository) Upsert(ctx context.Context, prefs *models.UserPreferences) error {
+	query := `
+		INSERT INTO user_preferences (user_id, default_clef, default_duration_seconds, default_max_ledger_lines,
+		                              note_naming, answer_input, sound_enabled, timezone, share_activity, discoverable, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (user_id) DO UPDATE
+		SET default_clef = EXCLUDED.default_clef,
+		    default_duration_seconds = EXCLUDED.default_duration_seconds,
+		    default_max_ledger_lines = EXCLUDED.default_max_ledger_lines,
+		    note_naming = EXCLUDED.note_naming,
+		    answer_input = EXCLUDED.answer_input,
+		    sound_enabled = EXCLUDED.sound_enabled,
+		    timezone = EXCLUDED.timezone,
+		    share_activity = EXCLUDED.share_activity,
+		    discoverable = EXCLUDED.discoverable,
+		    updated_at = EXCLUDED.updated_at`
+
+	_, err := r.db.ExecContext(ctx, query,
+		prefs.UserID, prefs.DefaultClef, prefs.DefaultDurationSeconds, prefs.DefaultMaxLedgerLines,
+		prefs.NoteNaming, prefs.AnswerInput, prefs.SoundEnabled, prefs.Timezone, prefs.ShareActivity, prefs.Discoverable, prefs.UpdatedAt)
+
+	return err
+}
+
+// scanUserPreferences scans a user_preferences row selected in column order
+func scanUserPreferences(row rowScanner) (*models.UserPreferences, error) {
+	prefs := &models.UserPreferences{}
+	err := row.Scan(
+		&prefs.UserID, &prefs.DefaultClef, &prefs.DefaultDurationSeconds, &prefs.DefaultMaxLedgerLines,
+		&prefs.NoteNaming, &prefs.AnswerInput, &prefs.SoundEnabled, &prefs.Timezone, &prefs.ShareActivity, &prefs.Discoverable, &prefs.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/andy-dam/iq-theory/server/pkg/database"
@@ -18,19 +20,79 @@ func NewQuizRepository(db *database.DB) QuizRepository {
 	return &quizRepository{db: db}
 }
 
+// GetClefTypes retrieves the active clefs
 func (r *quizRepository) GetClefTypes(ctx context.Context) ([]*models.ClefType, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, name, display_name, is_active
+		FROM clef_types
+		WHERE is_active = true
+		ORDER BY id`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clefs []*models.ClefType
+	for rows.Next() {
+		clef := &models.ClefType{}
+		if err := rows.Scan(&clef.ID, &clef.Name, &clef.DisplayName, &clef.IsActive); err != nil {
+			return nil, err
+		}
+		clefs = append(clefs, clef)
+	}
+	return clefs, rows.Err()
 }
 
+// GetDurationOptions retrieves the active quiz durations, shortest first
 func (r *quizRepository) GetDurationOptions(ctx context.Context) ([]*models.DurationOption, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, duration_seconds, display_name, is_active
+		FROM duration_options
+		WHERE is_active = true
+		ORDER BY duration_seconds`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var options []*models.DurationOption
+	for rows.Next() {
+		option := &models.DurationOption{}
+		if err := rows.Scan(&option.ID, &option.DurationSeconds, &option.DisplayName, &option.IsActive); err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	return options, rows.Err()
 }
 
+// GetLedgerLineOptions retrieves the active ledger line limits, fewest first
 func (r *quizRepository) GetLedgerLineOptions(ctx context.Context) ([]*models.LedgerLineOption, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, max_lines, display_name, is_active
+		FROM ledger_line_options
+		WHERE is_active = true
+		ORDER BY max_lines`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var options []*models.LedgerLineOption
+	for rows.Next() {
+		option := &models.LedgerLineOption{}
+		if err := rows.Scan(&option.ID, &option.MaxLines, &option.DisplayName, &option.IsActive); err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	return options, rows.Err()
 }
 
 func (r *quizRepository) GetAvailableConfigurations(ctx context.Context) ([]*models.AvailableQuizConfiguration, error) {
@@ -46,19 +108,68 @@ func NewQuizSessionRepository(db *database.DB) QuizSessionRepository {
 	return &quizSessionRepository{db: db}
 }
 
+// Create creates a new quiz session
 func (r *quizSessionRepository) Create(ctx context.Context, session *models.QuizSession) error {
-	// TODO: Implement
-	return nil
+	query := `
+		INSERT INTO quiz_sessions (id, user_id, clef, duration_seconds, max_ledger_lines, score,
+		                           total_questions, correct_answers, started_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		session.ID, session.UserID, session.Clef, session.DurationSeconds, session.MaxLedgerLines,
+		session.Score, session.TotalQuestions, session.CorrectAnswers, session.StartedAt, session.Status)
+
+	return err
 }
 
+// GetByID retrieves a quiz session by ID
 func (r *quizSessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.QuizSession, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, user_id, clef, duration_seconds, max_ledger_lines, score,
+		       total_questions, correct_answers, time_taken_seconds, started_at,
+		       completed_at, status, accuracy_percentage
+		FROM quiz_sessions
+		WHERE id = $1`
+
+	session, err := scanQuizSession(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
 }
 
+// GetUserSessions retrieves a user's most recent quiz sessions. History
+// tolerates replica lag, so it is routed to a read replica when available.
 func (r *quizSessionRepository) GetUserSessions(ctx context.Context, userID uuid.UUID, limit int) ([]*models.QuizSession, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT id, user_id, clef, duration_seconds, max_ledger_lines, score,
+		       total_questions, correct_answers, time_taken_seconds, started_at,
+		       completed_at, status, accuracy_percentage
+		FROM quiz_sessions
+		WHERE user_id = $1
+		ORDER BY started_at DESC
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.QuizSession
+	for rows.Next() {
+		session, err := scanQuizSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
 }
 
 func (r *quizSessionRepository) Update(ctx context.Context, session *models.QuizSession) error {
@@ -66,11 +177,171 @@ func (r *quizSessionRepository) Update(ctx context.Context, session *models.Quiz
 	return nil
 }
 
+// Complete marks an in-progress session as completed with its final results
 func (r *quizSessionRepository) Complete(ctx context.Context, id uuid.UUID, score int, timeTaken int) error {
-	// TODO: Implement
+	query := `
+		UPDATE quiz_sessions
+		SET status = 'completed', score = $2, time_taken_seconds = $3, completed_at = NOW()
+		WHERE id = $1 AND status = 'in_progress'`
+
+	result, err := r.db.ExecContext(ctx, query, id, score, timeTaken)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
+// CountCompleted counts a user's completed quiz sessions
+func (r *quizSessionRepository) CountCompleted(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM quiz_sessions WHERE user_id = $1 AND status = 'completed'`
+
+	var count int
+	err := r.db.QueryRowContext(database.ReadOnly(ctx), query, userID).Scan(&count)
+	return count, err
+}
+
+// GetBestScoresByClef retrieves a user's best completed score on each clef
+func (r *quizSessionRepository) GetBestScoresByClef(ctx context.Context, userID uuid.UUID) ([]*models.ClefBestScore, error) {
+	query := `
+		SELECT clef, MAX(score)
+		FROM quiz_sessions
+		WHERE user_id = $1 AND status = 'completed'
+		GROUP BY clef
+		ORDER BY clef`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []*models.ClefBestScore
+	for rows.Next() {
+		score := &models.ClefBestScore{}
+		if err := rows.Scan(&score.Clef, &score.BestScore); err != nil {
+			return nil, err
+		}
+		scores = append(scores, score)
+	}
+
+	return scores, rows.Err()
+}
+
+// GetActiveDays retrieves the most recent UTC dates on which the user
+// completed a quiz
+func (r *quizSessionRepository) GetActiveDays(ctx context.Context, userID uuid.UUID, limit int) ([]time.Time, error) {
+	query := `
+		SELECT DISTINCT (completed_at AT TIME ZONE 'UTC')::date AS day
+		FROM quiz_sessions
+		WHERE user_id = $1 AND status = 'completed' AND completed_at IS NOT NULL
+		ORDER BY day DESC
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
+
+// GetBestScoreBefore retrieves a user's best score for a quiz configuration
+// among sessions completed before the given time
+func (r *quizSessionRepository) GetBestScoreBefore(ctx context.Context, userID uuid.UUID, clef string, duration, maxLedgerLines int, before time.Time) (*int, error) {
+	query := `
+		SELECT MAX(score)
+		FROM quiz_sessions
+		WHERE user_id = $1 AND clef = $2 AND duration_seconds = $3 AND max_ledger_lines = $4
+		  AND status = 'completed' AND completed_at < $5`
+
+	var best sql.NullInt64
+	if err := r.db.QueryRowContext(ctx, query, userID, clef, duration, maxLedgerLines, before).Scan(&best); err != nil {
+		return nil, err
+	}
+	if !best.Valid {
+		return nil, nil
+	}
+
+	score := int(best.Int64)
+	return &score, nil
+}
+
+// GetConfigStats retrieves two users' stats on every quiz configuration
+// either has completed. Trend compares the latest trendWindow attempts with
+// the trendWindow before them.
+func (r *quizSessionRepository) GetConfigStats(ctx context.Context, userID, otherID uuid.UUID, trendWindow int) ([]*models.ConfigStats, error) {
+	query := `
+		SELECT user_id, clef, duration_seconds, max_ledger_lines,
+		       MAX(score), AVG(accuracy_percentage), COUNT(*),
+		       AVG(score) FILTER (WHERE rn <= $3) - AVG(score) FILTER (WHERE rn > $3 AND rn <= 2 * $3)
+		FROM (
+			SELECT user_id, clef, duration_seconds, max_ledger_lines, score, accuracy_percentage,
+			       ROW_NUMBER() OVER (
+			           PARTITION BY user_id, clef, duration_seconds, max_ledger_lines
+			           ORDER BY completed_at DESC
+			       ) AS rn
+			FROM quiz_sessions
+			WHERE user_id IN ($1, $2) AND status = 'completed'
+		) s
+		GROUP BY user_id, clef, duration_seconds, max_ledger_lines
+		ORDER BY clef, duration_seconds, max_ledger_lines`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query, userID, otherID, trendWindow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*models.ConfigStats
+	for rows.Next() {
+		stat := &models.ConfigStats{}
+		var trend sql.NullFloat64
+		err := rows.Scan(
+			&stat.UserID, &stat.Clef, &stat.DurationSeconds, &stat.MaxLedgerLines,
+			&stat.BestScore, &stat.AverageAccuracy, &stat.Attempts, &trend,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if trend.Valid {
+			stat.Trend = &trend.Float64
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// Reassign moves every quiz session of fromUserID to toUserID
+func (r *quizSessionRepository) Reassign(ctx context.Context, fromUserID, toUserID uuid.UUID) (int, error) {
+	query := `UPDATE quiz_sessions SET user_id = $2 WHERE user_id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, fromUserID, toUserID)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
 type quizAnswerRepository struct {
 	db *database.DB
 }
@@ -99,6 +370,37 @@ func (r *quizAnswerRepository) Update(ctx context.Context, answer *models.QuizAn
 	return nil
 }
 
+// GetNoteAccuracy retrieves two users' accuracy on each note of each clef,
+// over the answers of their completed quizzes
+func (r *quizAnswerRepository) GetNoteAccuracy(ctx context.Context, userID, otherID uuid.UUID) ([]*models.NoteAccuracy, error) {
+	query := `
+		SELECT qs.user_id, qs.clef, qa.correct_note,
+		       COUNT(*), COUNT(*) FILTER (WHERE qa.is_correct)
+		FROM quiz_answers qa
+		JOIN quiz_sessions qs ON qs.id = qa.quiz_session_id
+		WHERE qs.user_id IN ($1, $2) AND qs.status = 'completed'
+		GROUP BY qs.user_id, qs.clef, qa.correct_note
+		ORDER BY qs.clef, qa.correct_note`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query, userID, otherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []*models.NoteAccuracy
+	for rows.Next() {
+		note := &models.NoteAccuracy{}
+		if err := rows.Scan(&note.UserID, &note.Clef, &note.Note, &note.Answers, &note.Correct); err != nil {
+			return nil, err
+		}
+		note.Accuracy = float64(note.Correct) / float64(note.Answers) * 100
+		notes = append(notes, note)
+	}
+
+	return notes, rows.Err()
+}
+
 type leaderboardRepository struct {
 	db *database.DB
 }
@@ -107,17 +409,159 @@ func NewLeaderboardRepository(db *database.DB) LeaderboardRepository {
 	return &leaderboardRepository{db: db}
 }
 
+// GetGlobalLeaderboard retrieves the top entries for a quiz configuration.
+// Leaderboards tolerate replica lag, so they are routed to a read replica
+// when available.
 func (r *leaderboardRepository) GetGlobalLeaderboard(ctx context.Context, clef string, duration int, maxLedgerLines int, limit int) ([]*models.LeaderboardEntry, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT clef, duration_seconds, max_ledger_lines, quiz_name, user_id, username,
+		       display_name, best_score, best_accuracy, fastest_time, total_attempts,
+		       average_score, last_attempt, global_rank
+		FROM leaderboards
+		WHERE clef = $1 AND duration_seconds = $2 AND max_ledger_lines = $3
+		ORDER BY global_rank
+		LIMIT $4`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query, clef, duration, maxLedgerLines, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.LeaderboardEntry
+	for rows.Next() {
+		entry, err := scanLeaderboardEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
 }
 
+// GetUserRanking retrieves a user's leaderboard entry for a quiz configuration
 func (r *leaderboardRepository) GetUserRanking(ctx context.Context, userID uuid.UUID, clef string, duration int, maxLedgerLines int) (*models.LeaderboardEntry, error) {
-	// TODO: Implement
-	return nil, nil
+	query := `
+		SELECT clef, duration_seconds, max_ledger_lines, quiz_name, user_id, username,
+		       display_name, best_score, best_accuracy, fastest_time, total_attempts,
+		       average_score, last_attempt, global_rank
+		FROM leaderboards
+		WHERE user_id = $1 AND clef = $2 AND duration_seconds = $3 AND max_ledger_lines = $4`
+
+	row := r.db.QueryRowContext(database.ReadOnly(ctx), query, userID, clef, duration, maxLedgerLines)
+	entry, err := scanLeaderboardEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
 }
 
+// GetFriendsLeaderboard retrieves the entries of a user and their accepted
+// friends for a quiz configuration, ranked among themselves. The friend set
+// is resolved in the query so large friend lists cost one round trip.
+func (r *leaderboardRepository) GetFriendsLeaderboard(ctx context.Context, userID uuid.UUID, clef string, duration int, maxLedgerLines int, limit, offset int) ([]*models.LeaderboardEntry, error) {
+	query := `
+		WITH members AS (
+			SELECT $1::uuid AS user_id
+			UNION
+			SELECT CASE WHEN requester_id = $1 THEN addressee_id ELSE requester_id END
+			FROM friendships
+			WHERE (requester_id = $1 OR addressee_id = $1) AND status = 'accepted'
+		)
+		SELECT l.clef, l.duration_seconds, l.max_ledger_lines, l.quiz_name, l.user_id, l.username,
+		       l.display_name, l.best_score, l.best_accuracy, l.fastest_time, l.total_attempts,
+		       l.average_score, l.last_attempt, l.global_rank,
+		       RANK() OVER (ORDER BY l.best_score DESC, l.fastest_time ASC) AS rank
+		FROM leaderboards l
+		JOIN members m ON m.user_id = l.user_id
+		WHERE l.clef = $2 AND l.duration_seconds = $3 AND l.max_ledger_lines = $4
+		ORDER BY rank, l.username
+		LIMIT $5 OFFSET $6`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query, userID, clef, duration, maxLedgerLines, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.LeaderboardEntry
+	for rows.Next() {
+		var rank int
+		entry, err := scanLeaderboardEntry(rows, &rank)
+		if err != nil {
+			return nil, err
+		}
+		entry.Rank = rank
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// RefreshLeaderboard recomputes the leaderboards materialized view. The
+// refresh runs concurrently, so readers are not blocked while it runs.
 func (r *leaderboardRepository) RefreshLeaderboard(ctx context.Context) error {
-	// TODO: Implement
-	return nil
+	_, err := r.db.ExecContext(ctx, `SELECT refresh_leaderboards()`)
+	return err
+}
+
+// rowScanner is satisfied by both *database.Row and *database.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanQuizSession scans a quiz_sessions row selected in column order
+func scanQuizSession(row rowScanner) (*models.QuizSession, error) {
+	session := &models.QuizSession{}
+	var timeTaken sql.NullInt64
+	var completedAt sql.NullTime
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.Clef, &session.DurationSeconds,
+		&session.MaxLedgerLines, &session.Score, &session.TotalQuestions,
+		&session.CorrectAnswers, &timeTaken, &session.StartedAt, &completedAt,
+		&session.Status, &session.AccuracyPercentage,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeTaken.Valid {
+		seconds := int(timeTaken.Int64)
+		session.TimeTakenSeconds = &seconds
+	}
+	if completedAt.Valid {
+		session.CompletedAt = &completedAt.Time
+	}
+
+	return session, nil
+}
+
+// scanLeaderboardEntry scans a leaderboards row selected in column order,
+// followed by any extra columns into extra
+func scanLeaderboardEntry(row rowScanner, extra ...any) (*models.LeaderboardEntry, error) {
+	entry := &models.LeaderboardEntry{}
+	var fastestTime sql.NullInt64
+	var lastAttempt sql.NullTime
+	dest := []any{
+		&entry.Clef, &entry.DurationSeconds, &entry.MaxLedgerLines, &entry.QuizName,
+		&entry.UserID, &entry.Username, &entry.DisplayName, &entry.BestScore,
+		&entry.BestAccuracy, &fastestTime, &entry.TotalAttempts, &entry.AverageScore,
+		&lastAttempt, &entry.GlobalRank,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.FastestTime = int(fastestTime.Int64)
+	if lastAttempt.Valid {
+		entry.LastAttempt = &lastAttempt.Time
+	}
+
+	return entry, nil
 }
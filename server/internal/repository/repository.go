@@ -6,26 +6,64 @@ import (
 
 // Repositories aggregates all repository implementations
 type Repositories struct {
-	User            UserRepository
-	Friendship      FriendshipRepository
-	Group           GroupRepository
-	GroupMembership GroupMembershipRepository
-	Quiz            QuizRepository
-	QuizSession     QuizSessionRepository
-	QuizAnswer      QuizAnswerRepository
-	Leaderboard     LeaderboardRepository
+	Tx Transactor
+
+	User             UserRepository
+	UserToken        UserTokenRepository
+	Session          SessionRepository
+	APIToken         APITokenRepository
+	UserIdentity     UserIdentityRepository
+	OIDCLoginState   OIDCLoginStateRepository
+	LoginThrottle    LoginThrottleRepository
+	StudentLoginCode StudentLoginCodeRepository
+	UserPreferences  UserPreferencesRepository
+	UserTOTP         UserTOTPRepository
+	RecoveryCode     RecoveryCodeRepository
+	MFAChallenge     MFAChallengeRepository
+	DataExport       DataExportRepository
+	AccountData      AccountDataRepository
+	Friendship       FriendshipRepository
+	Block            BlockRepository
+	Activity         ActivityRepository
+	Group            GroupRepository
+	GroupMembership  GroupMembershipRepository
+	Quiz             QuizRepository
+	QuizSession      QuizSessionRepository
+	QuizAnswer       QuizAnswerRepository
+	Leaderboard      LeaderboardRepository
+	Outbox           OutboxRepository
+	FeatureFlag      FeatureFlagRepository
 }
 
 // NewRepositories creates a new instance of all repositories
 func NewRepositories(db *database.DB) *Repositories {
 	return &Repositories{
-		User:            NewUserRepository(db),
-		Friendship:      NewFriendshipRepository(db),
-		Group:           NewGroupRepository(db),
-		GroupMembership: NewGroupMembershipRepository(db),
-		Quiz:            NewQuizRepository(db),
-		QuizSession:     NewQuizSessionRepository(db),
-		QuizAnswer:      NewQuizAnswerRepository(db),
-		Leaderboard:     NewLeaderboardRepository(db),
+		Tx: db,
+
+		User:             NewUserRepository(db),
+		UserToken:        NewUserTokenRepository(db),
+		Session:          NewSessionRepository(db),
+		APIToken:         NewAPITokenRepository(db),
+		UserIdentity:     NewUserIdentityRepository(db),
+		OIDCLoginState:   NewOIDCLoginStateRepository(db),
+		LoginThrottle:    NewLoginThrottleRepository(db),
+		StudentLoginCode: NewStudentLoginCodeRepository(db),
+		UserPreferences:  NewUserPreferencesRepository(db),
+		UserTOTP:         NewUserTOTPRepository(db),
+		RecoveryCode:     NewRecoveryCodeRepository(db),
+		MFAChallenge:     NewMFAChallengeRepository(db),
+		DataExport:       NewDataExportRepository(db),
+		AccountData:      NewAccountDataRepository(db),
+		Friendship:       NewFriendshipRepository(db),
+		Block:            NewBlockRepository(db),
+		Activity:         NewActivityRepository(db),
+		Group:            NewGroupRepository(db),
+		GroupMembership:  NewGroupMembershipRepository(db),
+		Quiz:             NewQuizRepository(db),
+		QuizSession:      NewQuizSessionRepository(db),
+		QuizAnswer:       NewQuizAnswerRepository(db),
+		Leaderboard:      NewLeaderboardRepository(db),
+		Outbox:           NewOutboxRepository(db),
+		FeatureFlag:      NewFeatureFlagRepository(db),
 	}
 }
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+)
+
+// studentLoginCodeRepository implements the StudentLoginCodeRepository interface
+type studentLoginCodeRepository struct {
+	db *database.DB
+}
+
+// NewStudentLoginCodeRepository creates a new student login code repository instance
+func NewStudentLoginCodeRepository(db *database.DB) StudentLoginCodeRepository {
+	return &studentLoginCodeRepository{db: db}
+}
+
+// Upsert stores a student's login code, replacing any code they had
+func (r *studentLoginCodeRepository) Upsert(ctx context.Context, code *models.StudentLoginCode) error {
+	query := `
+		INSERT INTO student_login_codes (user_id, code_hash, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET code_hash = EXCLUDED.code_hash,
+		    created_at = EXCLUDED.created_at,
+		    last_used_at = NULL`
+
+	_, err := r.db.ExecContext(ctx, query, code.UserID, code.CodeHash, code.CreatedAt)
+	return err
+}
+
+// GetByHash retrieves a login code by its hash
+func (r *studentLoginCodeRepository) GetByHash(ctx context.Context, codeHash string) (*models.StudentLoginCode, error) {
+	query := `
+		SELECT user_id, code_hash, created_at, last_used_at
+		FROM student_login_codes
+		WHERE code_hash = $1`
+
+	code := &models.StudentLoginCode{}
+	err := r.db.QueryRowContext(ctx, query, codeHash).Scan(
+		&code.UserID, &code.CodeHash, &code.CreatedAt, &code.LastUsedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// Touch records that a student signed in with their login code
+func (r *studentLoginCodeRepository) Touch(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE student_login_codes SET last_used_at = NOW() WHERE user_id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// Delete removes a student's login code
+func (r *studentLoginCodeRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM student_login_codes WHERE user_id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"strings"
+	"time"
 
 	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/andy-dam/iq-theory/server/pkg/database"
@@ -19,15 +21,26 @@ func NewUserRepository(db *database.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-// Create creates a new user in the database
+// userColumns lists the users columns in the order scanUser reads them
+const userColumns = `id, email, username, display_name, password_hash, avatar_url,
+		       created_at, updated_at, is_active, email_verified, site_role, is_guest, managed_by_group_id,
+		       password_change_required, tokens_valid_after, firebase_uid, avatar_key, deletion_scheduled_at, deleted_at`
+
+// Create creates a new user in the database. Users without a site role
+// become students.
 func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (id, email, username, display_name, password_hash, avatar_url, created_at, updated_at, is_active, email_verified)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+		INSERT INTO users (id, email, username, display_name, password_hash, avatar_url, created_at, updated_at, is_active, email_verified, site_role, is_guest,
+		                   managed_by_group_id, password_change_required)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
 
+	if user.SiteRole == "" {
+		user.SiteRole = models.SiteRoleStudent
+	}
 	_, err := r.db.ExecContext(ctx, query,
 		user.ID, user.Email, user.Username, user.DisplayName, user.PasswordHash,
-		user.AvatarURL, user.CreatedAt, user.UpdatedAt, user.IsActive, user.EmailVerified)
+		user.AvatarURL, user.CreatedAt, user.UpdatedAt, user.IsActive, user.EmailVerified, user.SiteRole, user.IsGuest,
+		user.ManagedByGroupID, user.PasswordChangeRequired)
 
 	return err
 }
@@ -35,73 +48,41 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 // GetByID retrieves a user by their ID
 func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	query := `
-		SELECT id, email, username, display_name, password_hash, avatar_url, 
-		       created_at, updated_at, is_active, email_verified
+		SELECT ` + userColumns + `
 		FROM users 
 		WHERE id = $1 AND is_active = true`
 
-	user := &models.User{}
-	row := r.db.QueryRowContext(ctx, query, id)
-	err := row.Scan(
-		&user.ID, &user.Email, &user.Username, &user.DisplayName, &user.PasswordHash,
-		&user.AvatarURL, &user.CreatedAt, &user.UpdatedAt, &user.IsActive, &user.EmailVerified,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	return user, nil
+	return r.get(ctx, query, id)
 }
 
 // GetByEmail retrieves a user by their email
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, email, username, display_name, password_hash, avatar_url, 
-		       created_at, updated_at, is_active, email_verified
+		SELECT ` + userColumns + `
 		FROM users 
 		WHERE email = $1 AND is_active = true`
 
-	user := &models.User{}
-	row := r.db.QueryRowContext(ctx, query, email)
-	err := row.Scan(
-		&user.ID, &user.Email, &user.Username, &user.DisplayName, &user.PasswordHash,
-		&user.AvatarURL, &user.CreatedAt, &user.UpdatedAt, &user.IsActive, &user.EmailVerified,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	return user, nil
+	return r.get(ctx, query, email)
 }
 
 // GetByUsername retrieves a user by their username
 func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	query := `
-		SELECT id, email, username, display_name, password_hash, avatar_url, 
-		       created_at, updated_at, is_active, email_verified
+		SELECT ` + userColumns + `
 		FROM users 
 		WHERE username = $1 AND is_active = true`
 
-	user := &models.User{}
-	row := r.db.QueryRowContext(ctx, query, username)
-	err := row.Scan(
-		&user.ID, &user.Email, &user.Username, &user.DisplayName, &user.PasswordHash,
-		&user.AvatarURL, &user.CreatedAt, &user.UpdatedAt, &user.IsActive, &user.EmailVerified,
-	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
+	return r.get(ctx, query, username)
+}
 
-	return user, nil
+// GetByFirebaseUID retrieves a user by their linked Firebase UID
+func (r *userRepository) GetByFirebaseUID(ctx context.Context, firebaseUID string) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users 
+		WHERE firebase_uid = $1 AND is_active = true`
+
+	return r.get(ctx, query, firebaseUID)
 }
 
 // Update updates an existing user
@@ -109,23 +90,249 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
 	query := `
 		UPDATE users 
 		SET email = $2, username = $3, display_name = $4, password_hash = $5, 
-		    avatar_url = $6, updated_at = $7, is_active = $8, email_verified = $9
+		    avatar_url = $6, updated_at = $7, is_active = $8, email_verified = $9,
+		    tokens_valid_after = $10, deletion_scheduled_at = $11, password_change_required = $12
 		WHERE id = $1`
 
 	_, err := r.db.ExecContext(ctx, query,
 		user.ID, user.Email, user.Username, user.DisplayName, user.PasswordHash,
-		user.AvatarURL, user.UpdatedAt, user.IsActive, user.EmailVerified)
+		user.AvatarURL, user.UpdatedAt, user.IsActive, user.EmailVerified,
+		user.TokensValidAfter, user.DeletionScheduledAt, user.PasswordChangeRequired)
+
+	return err
+}
+
+// SetFirebaseUID links a user to a Firebase account
+func (r *userRepository) SetFirebaseUID(ctx context.Context, id uuid.UUID, firebaseUID string) error {
+	query := `UPDATE users SET firebase_uid = $2, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, firebaseUID)
+	return err
+}
+
+// SetAvatar replaces a user's avatar URL and the storage key it is served
+// from. The key is nil for avatars hosted elsewhere.
+func (r *userRepository) SetAvatar(ctx context.Context, id uuid.UUID, avatarURL, avatarKey *string) error {
+	query := `UPDATE users SET avatar_url = $2, avatar_key = $3, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, avatarURL, avatarKey)
+	return err
+}
 
+// SetSiteRole changes a user's site-wide role
+func (r *userRepository) SetSiteRole(ctx context.Context, id uuid.UUID, role string) error {
+	query := `UPDATE users SET site_role = $2, updated_at = NOW() WHERE id = $1 AND is_active = true`
+	result, err := r.db.ExecContext(ctx, query, id, role)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListBySiteRole retrieves the active registered users with a site role, by
+// username
+func (r *userRepository) ListBySiteRole(ctx context.Context, role string) ([]*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE site_role = $1 AND is_active = true AND NOT is_guest
+		ORDER BY username`
+
+	rows, err := r.db.QueryContext(ctx, query, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Search finds active registered users whose username starts with or
+// resembles query, or whose display name contains a word resembling it.
+// Exact and prefix username matches rank first, then by trigram similarity.
+// Managed students are left out, so only their classmates can find them.
+func (r *userRepository) Search(ctx context.Context, query string, limit, offset int) ([]*models.User, error) {
+	sqlQuery := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE is_active = true AND NOT is_guest AND managed_by_group_id IS NULL
+		  AND (lower(username) LIKE $2 ESCAPE '\'
+		       OR lower(username) % $1
+		       OR $1 <% lower(display_name))
+		ORDER BY lower(username) = $1 DESC,
+		         lower(username) LIKE $2 ESCAPE '\' DESC,
+		         GREATEST(similarity(lower(username), $1), word_similarity($1, lower(display_name))) DESC,
+		         username
+		LIMIT $3 OFFSET $4`
+
+	query = strings.ToLower(query)
+	prefix := likeEscaper.Replace(query) + "%"
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), sqlQuery, query, prefix, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// UpdatePasswordHash replaces a user's password hash without touching the
+// rest of the row, e.g. when rehashing at a new cost
+func (r *userRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, passwordHash)
 	return err
 }
 
-// Delete soft deletes a user (sets is_active to false)
-func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `UPDATE users SET is_active = false, updated_at = NOW() WHERE id = $1`
+// ConvertGuest turns a guest into a registered account with the user's new
+// email, username, display name and password hash
+func (r *userRepository) ConvertGuest(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users
+		SET email = $2, username = $3, display_name = $4, password_hash = $5,
+		    is_guest = false, updated_at = $6
+		WHERE id = $1 AND is_guest AND is_active = true`
+
+	result, err := r.db.ExecContext(ctx, query,
+		user.ID, user.Email, user.Username, user.DisplayName, user.PasswordHash, user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteGuest permanently deletes a guest and, through cascades, everything
+// attached to it
+func (r *userRepository) DeleteGuest(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM users WHERE id = $1 AND is_guest`
 	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
 
+// DeleteStaleGuests permanently deletes up to limit guests created before
+// cutoff that have not used a session since, and returns how many it deleted
+func (r *userRepository) DeleteStaleGuests(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	query := `
+		DELETE FROM users
+		WHERE id IN (
+			SELECT u.id
+			FROM users u
+			WHERE u.is_guest AND u.created_at < $1
+			  AND NOT EXISTS (
+			      SELECT 1 FROM sessions s
+			      WHERE s.user_id = u.id AND s.last_seen_at >= $1
+			  )
+			LIMIT $2
+		)`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// Delete soft deletes a user (sets is_active to false) and schedules the
+// account to be purged at purgeAt, unless it is already scheduled sooner
+func (r *userRepository) Delete(ctx context.Context, id uuid.UUID, purgeAt time.Time) error {
+	query := `
+		UPDATE users
+		SET is_active = false, updated_at = NOW(),
+		    deletion_scheduled_at = LEAST(COALESCE(deletion_scheduled_at, $2), $2)
+		WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, purgeAt)
+	return err
+}
+
+// GetDueForDeletion retrieves the IDs of users whose scheduled deletion is
+// due, active or not
+func (r *userRepository) GetDueForDeletion(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id
+		FROM users
+		WHERE deletion_scheduled_at <= NOW() AND deleted_at IS NULL
+		ORDER BY deletion_scheduled_at
+		LIMIT $1`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// get runs a query selecting userColumns and returns nil if no user matched
+func (r *userRepository) get(ctx context.Context, query string, args ...any) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// likeEscaper escapes LIKE wildcards so user input matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// scanUser scans a users row selected with userColumns
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(
+		&user.ID, &user.Email, &user.Username, &user.DisplayName, &user.PasswordHash,
+		&user.AvatarURL, &user.CreatedAt, &user.UpdatedAt, &user.IsActive, &user.EmailVerified, &user.SiteRole,
+		&user.IsGuest, &user.ManagedByGroupID, &user.PasswordChangeRequired, &user.TokensValidAfter, &user.FirebaseUID, &user.AvatarKey, &user.DeletionScheduledAt, &user.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
 // friendshipRepository implements the FriendshipRepository interface
 type friendshipRepository struct {
 	db *database.DB
@@ -156,12 +363,7 @@ func (r *friendshipRepository) GetByID(ctx context.Context, id uuid.UUID) (*mode
 		FROM friendships 
 		WHERE id = $1`
 
-	friendship := &models.Friendship{}
-	row := r.db.QueryRowContext(ctx, query, id)
-	err := row.Scan(
-		&friendship.ID, &friendship.RequesterID, &friendship.AddresseeID,
-		&friendship.Status, &friendship.CreatedAt, &friendship.UpdatedAt,
-	)
+	friendship, err := scanFriendship(r.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -172,13 +374,140 @@ func (r *friendshipRepository) GetByID(ctx context.Context, id uuid.UUID) (*mode
 	return friendship, nil
 }
 
-// GetUserFriends retrieves all friendships for a user
-func (r *friendshipRepository) GetUserFriends(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error) {
+// GetBetween retrieves the friendship between two users, whichever of them
+// sent the request
+func (r *friendshipRepository) GetBetween(ctx context.Context, userID, otherID uuid.UUID) (*models.Friendship, error) {
 	query := `
 		SELECT id, requester_id, addressee_id, status, created_at, updated_at
-		FROM friendships 
-		WHERE (requester_id = $1 OR addressee_id = $1) AND status = 'accepted'
-		ORDER BY created_at DESC`
+		FROM friendships
+		WHERE (requester_id = $1 AND addressee_id = $2)
+		   OR (requester_id = $2 AND addressee_id = $1)`
+
+	friendship, err := scanFriendship(r.db.QueryRowContext(ctx, query, userID, otherID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return friendship, nil
+}
+
+// GetUserFriends retrieves a user's accepted friendships, newest first
+func (r *friendshipRepository) GetUserFriends(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error) {
+	return r.list(ctx, `(f.requester_id = $1 OR f.addressee_id = $1) AND f.status = 'accepted'`, userID)
+}
+
+// GetIncoming retrieves the pending requests sent to a user, newest first
+func (r *friendshipRepository) GetIncoming(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error) {
+	return r.list(ctx, `f.addressee_id = $1 AND f.status = 'pending'`, userID)
+}
+
+// GetOutgoing retrieves the pending requests a user has sent, newest first
+func (r *friendshipRepository) GetOutgoing(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error) {
+	return r.list(ctx, `f.requester_id = $1 AND f.status = 'pending'`, userID)
+}
+
+// GetSuggestions ranks users who share groups or friends with a user.
+// Shared groups weigh most, then mutual friends, then group-mates who
+// completed a quiz since activeSince. Users with any friendship row with
+// the user (pending, declined or accepted), a block either way, guests,
+// managed students and users who opted out of being discoverable are left
+// out.
+func (r *friendshipRepository) GetSuggestions(ctx context.Context, userID uuid.UUID, activeSince time.Time, limit int) ([]*models.FriendSuggestion, error) {
+	query := `
+		WITH my_friends AS (
+			SELECT CASE WHEN requester_id = $1 THEN addressee_id ELSE requester_id END AS user_id
+			FROM friendships
+			WHERE (requester_id = $1 OR addressee_id = $1) AND status = 'accepted'
+		),
+		group_mates AS (
+			SELECT gm.user_id, COUNT(*) AS shared_groups
+			FROM group_memberships mine
+			JOIN groups g ON g.id = mine.group_id AND g.is_active
+			JOIN group_memberships gm ON gm.group_id = mine.group_id AND gm.user_id <> $1
+			WHERE mine.user_id = $1
+			GROUP BY gm.user_id
+		),
+		friends_of_friends AS (
+			SELECT user_id, COUNT(*) AS mutual_friends
+			FROM (
+				SELECT f.addressee_id AS user_id
+				FROM friendships f JOIN my_friends mf ON f.requester_id = mf.user_id
+				WHERE f.status = 'accepted'
+				UNION ALL
+				SELECT f.requester_id
+				FROM friendships f JOIN my_friends mf ON f.addressee_id = mf.user_id
+				WHERE f.status = 'accepted'
+			) fof
+			WHERE user_id <> $1
+			GROUP BY user_id
+		),
+		candidates AS (
+			SELECT COALESCE(gm.user_id, fof.user_id) AS user_id,
+			       COALESCE(gm.shared_groups, 0) AS shared_groups,
+			       COALESCE(fof.mutual_friends, 0) AS mutual_friends,
+			       gm.user_id IS NOT NULL AND EXISTS (
+			           SELECT 1 FROM quiz_sessions qs
+			           WHERE qs.user_id = gm.user_id AND qs.status = 'completed' AND qs.completed_at >= $2
+			       ) AS recently_active
+			FROM group_mates gm
+			FULL JOIN friends_of_friends fof ON fof.user_id = gm.user_id
+		)
+		SELECT u.id, u.username, u.display_name, u.avatar_url,
+		       c.shared_groups, c.mutual_friends, c.recently_active
+		FROM candidates c
+		JOIN users u ON u.id = c.user_id
+		LEFT JOIN user_preferences p ON p.user_id = u.id
+		WHERE u.is_active AND NOT u.is_guest AND u.managed_by_group_id IS NULL AND u.deleted_at IS NULL
+		  AND COALESCE(p.discoverable, true)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM friendships x
+		      WHERE (x.requester_id = $1 AND x.addressee_id = u.id)
+		         OR (x.requester_id = u.id AND x.addressee_id = $1)
+		  )
+		  AND NOT EXISTS (
+		      SELECT 1 FROM user_blocks b
+		      WHERE (b.blocker_id = $1 AND b.blocked_id = u.id)
+		         OR (b.blocker_id = u.id AND b.blocked_id = $1)
+		  )
+		ORDER BY 3 * c.shared_groups + 2 * c.mutual_friends + CASE WHEN c.recently_active THEN 1 ELSE 0 END DESC,
+		         u.username
+		LIMIT $3`
+
+	rows, err := r.db.QueryContext(database.ReadOnly(ctx), query, userID, activeSince, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []*models.FriendSuggestion
+	for rows.Next() {
+		suggestion := &models.FriendSuggestion{}
+		err := rows.Scan(
+			&suggestion.ID, &suggestion.Username, &suggestion.DisplayName, &suggestion.AvatarURL,
+			&suggestion.SharedGroups, &suggestion.MutualFriends, &suggestion.RecentlyActive,
+		)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// list retrieves the friendships of user $1 matching where, each with a
+// summary of the other user
+func (r *friendshipRepository) list(ctx context.Context, where string, userID uuid.UUID) ([]*models.Friendship, error) {
+	query := `
+		SELECT f.id, f.requester_id, f.addressee_id, f.status, f.created_at, f.updated_at,
+		       u.id, u.username, u.display_name, u.avatar_url
+		FROM friendships f
+		JOIN users u ON u.id = CASE WHEN f.requester_id = $1 THEN f.addressee_id ELSE f.requester_id END
+		WHERE ` + where + `
+		ORDER BY f.updated_at DESC`
 
 	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
@@ -188,10 +517,12 @@ func (r *friendshipRepository) GetUserFriends(ctx context.Context, userID uuid.U
 
 	var friendships []*models.Friendship
 	for rows.Next() {
-		friendship := &models.Friendship{}
+		friendship := &models.Friendship{User: &models.UserSummary{}}
 		err := rows.Scan(
 			&friendship.ID, &friendship.RequesterID, &friendship.AddresseeID,
 			&friendship.Status, &friendship.CreatedAt, &friendship.UpdatedAt,
+			&friendship.User.ID, &friendship.User.Username,
+			&friendship.User.DisplayName, &friendship.User.AvatarURL,
 		)
 		if err != nil {
 			return nil, err
@@ -202,6 +533,18 @@ func (r *friendshipRepository) GetUserFriends(ctx context.Context, userID uuid.U
 	return friendships, rows.Err()
 }
 
+// Update saves a friendship's users and status, for a request sent again
+func (r *friendshipRepository) Update(ctx context.Context, friendship *models.Friendship) error {
+	query := `
+		UPDATE friendships
+		SET requester_id = $2, addressee_id = $3, status = $4, updated_at = $5
+		WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query,
+		friendship.ID, friendship.RequesterID, friendship.AddresseeID,
+		friendship.Status, friendship.UpdatedAt)
+	return err
+}
+
 // UpdateStatus updates the status of a friendship
 func (r *friendshipRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
 	query := `UPDATE friendships SET status = $2, updated_at = NOW() WHERE id = $1`
@@ -215,3 +558,16 @@ func (r *friendshipRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
+
+// scanFriendship scans a friendships row
+func scanFriendship(row rowScanner) (*models.Friendship, error) {
+	friendship := &models.Friendship{}
+	err := row.Scan(
+		&friendship.ID, &friendship.RequesterID, &friendship.AddresseeID,
+		&friendship.Status, &friendship.CreatedAt, &friendship.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return friendship, nil
+}
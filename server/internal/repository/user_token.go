@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/database"
+	"github.com/google/uuid"
+)
+
+// userTokenRepository implements the UserTokenRepository interface
+type userTokenRepository struct {
+	db *database.DB
+}
+
+// NewUserTokenRepository creates a new user token repository instance
+func NewUserTokenRepository(db *database.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+// Create stores a new token
+func (r *userTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		token.ID, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+
+	return err
+}
+
+// GetByHash retrieves a token by purpose and hash, whether or not it is
+// still usable
+func (r *userTokenRepository) GetByHash(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	query := `
+		SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at
+		FROM user_tokens
+		WHERE purpose = $1 AND token_hash = $2`
+
+	token, err := scanUserToken(r.db.QueryRowContext(ctx, query, purpose, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// GetLatest retrieves the most recently issued token of a purpose for a user
+func (r *userTokenRepository) GetLatest(ctx context.Context, userID uuid.UUID, purpose string) (*models.UserToken, error) {
+	query := `
+		SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at
+		FROM user_tokens
+		WHERE user_id = $1 AND purpose = $2
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	token, err := scanUserToken(r.db.QueryRowContext(ctx, query, userID, purpose))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MarkUsed consumes an unused, unexpired token. It returns sql.ErrNoRows if
+// the token was already used or has expired, so concurrent redemptions of
+// the same token cannot both succeed.
+func (r *userTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE id = $1 AND used_at IS NULL AND expires_at > NOW()`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// InvalidateAll marks every outstanding token of a purpose for a user as used
+func (r *userTokenRepository) InvalidateAll(ctx context.Context, userID uuid.UUID, purpose string) error {
+	query := `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, userID, purpose)
+	return err
+}
+
+// scanUserToken scans a user_tokens row selected in column order
+func scanUserToken(row rowScanner) (*models.UserToken, error) {
+	token := &models.UserToken{}
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.Purpose, &token.TokenHash,
+		&token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,483 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/config"
+	"github.com/andy-dam/iq-theory/server/internal/events"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/mailer"
+	"github.com/andy-dam/iq-theory/server/pkg/storage"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// purgeBatchSize bounds how many accounts or exports one purge run handles
+const purgeBatchSize = 100
+
+// accountService implements the AccountService interface
+type accountService struct {
+	tx              repository.Transactor
+	events          events.Publisher
+	mailer          mailer.Mailer
+	store           storage.Storage
+	exports         storage.Storage
+	account         config.AccountConfig
+	userRepo        repository.UserRepository
+	exportRepo      repository.DataExportRepository
+	accountDataRepo repository.AccountDataRepository
+	leaderboardRepo repository.LeaderboardRepository
+}
+
+// NewAccountService creates a new account service instance
+func NewAccountService(repos *repository.Repositories, mail mailer.Mailer, store, exports storage.Storage, account config.AccountConfig) AccountService {
+	return &accountService{
+		tx:              repos.Tx,
+		events:          events.NewPublisher(repos.Outbox),
+		mailer:          mail,
+		store:           store,
+		exports:         exports,
+		account:         account,
+		userRepo:        repos.User,
+		exportRepo:      repos.DataExport,
+		accountDataRepo: repos.AccountData,
+		leaderboardRepo: repos.Leaderboard,
+	}
+}
+
+// RequestExport queues an export of the user's personal data. A pending
+// export is returned as is rather than queueing another.
+func (s *accountService) RequestExport(ctx context.Context, userID uuid.UUID) (*models.DataExport, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.RequestExport")
+	defer span.End()
+
+	exports, err := s.exportRepo.GetUserExports(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exports: %w", err)
+	}
+	if len(exports) > 0 {
+		latest := exports[0]
+		if latest.Status == models.DataExportPending {
+			return latest, nil
+		}
+		if latest.Status == models.DataExportReady {
+			if wait := time.Until(latest.CreatedAt.Add(s.account.ExportInterval)); wait > 0 {
+				return nil, fmt.Errorf("an export was created recently; try again in %s: %w",
+					formatDuration(wait.Round(time.Minute)), ErrTooManyRequests)
+			}
+		}
+	}
+
+	export := &models.DataExport{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Status:    models.DataExportPending,
+		CreatedAt: time.Now(),
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.exportRepo.Create(ctx, export); err != nil {
+			return fmt.Errorf("failed to create export: %w", err)
+		}
+		return s.events.Publish(ctx, events.ExportRequested{ExportID: export.ID, UserID: userID})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// ListExports lists a user's exports, newest first
+func (s *accountService) ListExports(ctx context.Context, userID uuid.UUID) ([]*models.DataExport, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ListExports")
+	defer span.End()
+
+	exports, err := s.exportRepo.GetUserExports(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exports: %w", err)
+	}
+	return exports, nil
+}
+
+// OpenExport reads a finished export's archive for download
+func (s *accountService) OpenExport(ctx context.Context, userID, exportID uuid.UUID) (*models.DataExport, []byte, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.OpenExport")
+	defer span.End()
+
+	export, err := s.exportRepo.GetByID(ctx, exportID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get export: %w", err)
+	}
+	if export == nil || export.UserID != userID {
+		return nil, nil, fmt.Errorf("export %w", ErrNotFound)
+	}
+	if export.Status != models.DataExportReady {
+		return nil, nil, fmt.Errorf("export is %s: %w", export.Status, ErrConflict)
+	}
+	if export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt) {
+		return nil, nil, fmt.Errorf("export %w", ErrNotFound)
+	}
+
+	archive, err := s.exports.Get(ctx, exportKey(export.ID))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, fmt.Errorf("export %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read export: %w", err)
+	}
+
+	return export, archive, nil
+}
+
+// BuildExport writes the archive for a requested export. It runs from the
+// event dispatcher; returning an error schedules a retry.
+func (s *accountService) BuildExport(ctx context.Context, exportID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "AccountService.BuildExport")
+	defer span.End()
+
+	export, err := s.exportRepo.GetByID(ctx, exportID)
+	if err != nil {
+		return fmt.Errorf("failed to get export: %w", err)
+	}
+	// Deleted with its account, or finished by an earlier delivery
+	if export == nil || export.Status == models.DataExportReady {
+		return nil
+	}
+
+	size, err := s.writeExport(ctx, export)
+	if err != nil {
+		if markErr := s.exportRepo.MarkFailed(ctx, export.ID, err.Error()); markErr != nil {
+			log.Printf("Failed to mark export %s failed: %v", export.ID, markErr)
+		}
+		return err
+	}
+
+	expiresAt := time.Now().Add(s.account.ExportTTL)
+	if err := s.exportRepo.MarkReady(ctx, export.ID, size, expiresAt); err != nil {
+		return fmt.Errorf("failed to mark export ready: %w", err)
+	}
+
+	if err := s.sendExportReadyEmail(ctx, export.UserID, expiresAt); err != nil {
+		log.Printf("Failed to send export email to user %s: %v", export.UserID, err)
+	}
+	return nil
+}
+
+// ScheduleDeletion schedules the user's account to be purged after the
+// grace period. Accounts with a password must confirm it.
+func (s *accountService) ScheduleDeletion(ctx context.Context, userID uuid.UUID, password string) (time.Time, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ScheduleDeletion")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return time.Time{}, fmt.Errorf("user %w", ErrNotFound)
+	}
+	if user.PasswordHash != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			return time.Time{}, ErrInvalidCredentials
+		}
+	}
+	if user.DeletionScheduledAt != nil {
+		return *user.DeletionScheduledAt, nil
+	}
+
+	scheduledAt := time.Now().Add(s.account.DeletionGracePeriod)
+	user.DeletionScheduledAt = &scheduledAt
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return time.Time{}, fmt.Errorf("failed to schedule deletion: %w", err)
+	}
+
+	if err := s.sendDeletionScheduledEmail(ctx, user); err != nil {
+		log.Printf("Failed to send deletion email to user %s: %v", user.ID, err)
+	}
+	return scheduledAt, nil
+}
+
+// CancelDeletion keeps an account that was scheduled for deletion
+func (s *accountService) CancelDeletion(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "AccountService.CancelDeletion")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+	if user.DeletionScheduledAt == nil {
+		return nil
+	}
+
+	user.DeletionScheduledAt = nil
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to cancel deletion: %w", err)
+	}
+	return nil
+}
+
+// PurgeDeletedAccounts purges accounts whose deletion grace period has
+// passed and returns how many were purged
+func (s *accountService) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.PurgeDeletedAccounts")
+	defer span.End()
+
+	userIDs, err := s.userRepo.GetDueForDeletion(ctx, purgeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get accounts due for deletion: %w", err)
+	}
+
+	purged := 0
+	for _, userID := range userIDs {
+		if err := s.purgeAccount(ctx, userID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	// Leaderboards show names and skip deleted users, so recompute them to
+	// drop the purged users' entries
+	if purged > 0 {
+		if err := s.leaderboardRepo.RefreshLeaderboard(ctx); err != nil {
+			log.Printf("Failed to refresh leaderboards after purge: %v", err)
+		}
+	}
+	return purged, nil
+}
+
+// PurgeExpiredExports removes expired export archives and returns how many
+// were removed
+func (s *accountService) PurgeExpiredExports(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.PurgeExpiredExports")
+	defer span.End()
+
+	exports, err := s.exportRepo.GetExpired(ctx, purgeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get expired exports: %w", err)
+	}
+
+	for i, export := range exports {
+		if err := s.removeExportFile(ctx, export.ID); err != nil {
+			return i, err
+		}
+		if err := s.exportRepo.Delete(ctx, export.ID); err != nil {
+			return i, fmt.Errorf("failed to delete export: %w", err)
+		}
+	}
+	return len(exports), nil
+}
+
+func (s *accountService) purgeAccount(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	exports, err := s.exportRepo.GetUserExports(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get exports: %w", err)
+	}
+	for _, export := range exports {
+		if err := s.removeExportFile(ctx, export.ID); err != nil {
+			return err
+		}
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.accountDataRepo.Purge(ctx, userID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge user %s: %w", userID, err)
+	}
+
+	// The row no longer points at the avatar, so a failed delete only
+	// leaves an orphaned file behind
+	if user != nil && user.AvatarKey != nil {
+		for _, key := range avatarKeys(*user.AvatarKey) {
+			if err := s.store.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete avatar %s of purged user %s: %v", key, userID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeExport builds the export archive in memory and stores it, so a partly
+// written archive is never served
+func (s *accountService) writeExport(ctx context.Context, export *models.DataExport) (int64, error) {
+	data, err := s.accountDataRepo.Export(ctx, export.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read account data: %w", err)
+	}
+	if data == nil {
+		return 0, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	var archive bytes.Buffer
+	if err := writeExportArchive(&archive, data); err != nil {
+		return 0, fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := s.exports.Put(ctx, exportKey(export.ID), "application/zip", archive.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to store export: %w", err)
+	}
+
+	return int64(archive.Len()), nil
+}
+
+func (s *accountService) removeExportFile(ctx context.Context, exportID uuid.UUID) error {
+	if err := s.exports.Delete(ctx, exportKey(exportID)); err != nil {
+		return fmt.Errorf("failed to remove export file: %w", err)
+	}
+	return nil
+}
+
+func exportKey(exportID uuid.UUID) string {
+	return "exports/" + exportID.String() + ".zip"
+}
+
+func (s *accountService) sendExportReadyEmail(ctx context.Context, userID uuid.UUID, expiresAt time.Time) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Your IQ Theory data export is ready",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The copy of your IQ Theory data you asked for is ready. Download it from your account settings:\n\n%s\n\n"+
+			"The download is available until %s.\n",
+			user.DisplayName, s.account.AppURL+"/settings/account", expiresAt.UTC().Format("2 January 2006 15:04 MST")),
+	})
+}
+
+func (s *accountService) sendDeletionScheduledEmail(ctx context.Context, user *models.User) error {
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Your IQ Theory account will be deleted",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Your IQ Theory account is scheduled to be deleted on %s. Until then you can keep it by "+
+			"signing in and cancelling the deletion in your account settings:\n\n%s\n\n"+
+			"If you did not ask for this, sign in and cancel it, then change your password.\n",
+			user.DisplayName, user.DeletionScheduledAt.UTC().Format("2 January 2006"), s.account.AppURL+"/settings/account"),
+	})
+}
+
+// writeExportArchive writes the account data as a ZIP of JSON and CSV files
+func writeExportArchive(w io.Writer, data *models.AccountData) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"profile.json", jsonFile(data.User)},
+		{"friendships.json", jsonFile(orEmpty(data.Friendships))},
+		{"blocks.json", jsonFile(orEmpty(data.Blocks))},
+		{"group_memberships.json", jsonFile(orEmpty(data.GroupMemberships))},
+		{"preferences.json", jsonFile(data.Preferences)},
+		{"quiz_sessions.csv", func(w io.Writer) error { return writeQuizSessionsCSV(w, data.QuizSessions) }},
+		{"quiz_answers.csv", func(w io.Writer) error { return writeQuizAnswersCSV(w, data.QuizAnswers) }},
+	}
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if err := file.write(f); err != nil {
+			return fmt.Errorf("%s: %w", file.name, err)
+		}
+	}
+
+	return archive.Close()
+}
+
+func jsonFile(v any) func(io.Writer) error {
+	return func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+}
+
+// orEmpty makes nil slices encode as [] rather than null
+func orEmpty[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func writeQuizSessionsCSV(w io.Writer, sessions []*models.QuizSession) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"id", "clef", "duration_seconds", "max_ledger_lines", "score", "total_questions",
+		"correct_answers", "accuracy_percentage", "time_taken_seconds", "started_at",
+		"completed_at", "status",
+	})
+	for _, session := range sessions {
+		out.Write([]string{
+			session.ID.String(), session.Clef, strconv.Itoa(session.DurationSeconds),
+			strconv.Itoa(session.MaxLedgerLines), strconv.Itoa(session.Score),
+			strconv.Itoa(session.TotalQuestions), strconv.Itoa(session.CorrectAnswers),
+			strconv.FormatFloat(session.AccuracyPercentage, 'f', 2, 64),
+			optionalInt(session.TimeTakenSeconds), session.StartedAt.UTC().Format(time.RFC3339),
+			optionalTime(session.CompletedAt), session.Status,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func writeQuizAnswersCSV(w io.Writer, answers []*models.QuizAnswer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"id", "quiz_session_id", "question_number", "correct_note", "user_answer",
+		"is_correct", "time_taken_ms", "answered_at",
+	})
+	for _, answer := range answers {
+		userAnswer := ""
+		if answer.UserAnswer != nil {
+			userAnswer = *answer.UserAnswer
+		}
+		out.Write([]string{
+			answer.ID.String(), answer.QuizSessionID.String(), strconv.Itoa(answer.QuestionNumber),
+			answer.CorrectNote, userAnswer, strconv.FormatBool(answer.IsCorrect),
+			strconv.Itoa(answer.TimeTakenMs), answer.AnsweredAt.UTC().Format(time.RFC3339),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func optionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func optionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
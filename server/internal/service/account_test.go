@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/storage"
+	"github.com/google/uuid"
+)
+
+func TestOpenExport(t *testing.T) {
+	ctx := context.Background()
+	owner, other := uuid.New(), uuid.New()
+	expired := time.Now().Add(-time.Hour)
+	later := time.Now().Add(time.Hour)
+
+	ready := &models.DataExport{ID: uuid.New(), UserID: owner, Status: models.DataExportReady, ExpiresAt: &later}
+	pending := &models.DataExport{ID: uuid.New(), UserID: owner, Status: models.DataExportPending}
+	stale := &models.DataExport{ID: uuid.New(), UserID: owner, Status: models.DataExportReady, ExpiresAt: &expired}
+	missing := &models.DataExport{ID: uuid.New(), UserID: owner, Status: models.DataExportReady, ExpiresAt: &later}
+
+	exports, err := storage.NewLocal(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	for _, export := range []*models.DataExport{ready, pending, stale} {
+		if err := exports.Put(ctx, exportKey(export.ID), "application/zip", []byte("zip")); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	s := &accountService{
+		exports: exports,
+		exportRepo: &fakeDataExportRepo{exports: map[uuid.UUID]*models.DataExport{
+			ready.ID: ready, pending.ID: pending, stale.ID: stale, missing.ID: missing,
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		exportID uuid.UUID
+		wantErr  error
+	}{
+		{"owner downloads a ready export", owner, ready.ID, nil},
+		{"someone else's export", other, ready.ID, ErrNotFound},
+		{"unknown export", owner, uuid.New(), ErrNotFound},
+		{"still being built", owner, pending.ID, ErrConflict},
+		{"expired", owner, stale.ID, ErrNotFound},
+		{"archive already removed", owner, missing.ID, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export, archive, err := s.OpenExport(ctx, tt.userID, tt.exportID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("OpenExport() error = %v, want %v", err, tt.wantErr)
+				}
+				if archive != nil {
+					t.Error("OpenExport() returned an archive with an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenExport() error = %v", err)
+			}
+			if export.ID != tt.exportID || string(archive) != "zip" {
+				t.Errorf("OpenExport() = %s, %q", export.ID, archive)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/events"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultFeedLimit and maxFeedLimit bound a page of the activity feed
+	defaultFeedLimit = 20
+	maxFeedLimit     = 50
+)
+
+// streakMilestones are the daily streak lengths worth telling friends about
+var streakMilestones = []int{3, 7, 14, 30, 50, 100, 200, 365}
+
+// activityService implements the ActivityService interface
+type activityService struct {
+	activityRepo    repository.ActivityRepository
+	userRepo        repository.UserRepository
+	groupRepo       repository.GroupRepository
+	quizSessionRepo repository.QuizSessionRepository
+}
+
+// NewActivityService creates a new activity service instance
+func NewActivityService(repos *repository.Repositories) ActivityService {
+	return &activityService{
+		activityRepo:    repos.Activity,
+		userRepo:        repos.User,
+		groupRepo:       repos.Group,
+		quizSessionRepo: repos.QuizSession,
+	}
+}
+
+// RecordQuizCompleted records a personal best if the quiz beat the user's
+// earlier scores for its configuration, and a streak milestone if it
+// brought their daily streak to one. Both are judged as of the quiz's
+// completion, so late or repeated delivery records the same activities.
+func (s *activityService) RecordQuizCompleted(ctx context.Context, event events.QuizCompleted) error {
+	ctx, span := tracing.Start(ctx, "ActivityService.RecordQuizCompleted")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, event.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.IsGuest {
+		return nil
+	}
+
+	previous, err := s.quizSessionRepo.GetBestScoreBefore(ctx, event.UserID, event.Clef,
+		event.DurationSeconds, event.MaxLedgerLines, event.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to get best score: %w", err)
+	}
+	// A first attempt is not a personal best worth sharing
+	if previous != nil && event.Score > *previous {
+		err := s.record(ctx, event.UserID, models.ActivityPersonalBest, event.SessionID.String(), nil,
+			event.CompletedAt, models.PersonalBestActivity{
+				Clef:            event.Clef,
+				DurationSeconds: event.DurationSeconds,
+				MaxLedgerLines:  event.MaxLedgerLines,
+				Score:           event.Score,
+				PreviousBest:    *previous,
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	days, err := s.quizSessionRepo.GetActiveDays(ctx, event.UserID, maxStreakDays)
+	if err != nil {
+		return fmt.Errorf("failed to get active days: %w", err)
+	}
+	streak, start := streakEndingOn(days, event.CompletedAt)
+	if !slices.Contains(streakMilestones, streak) {
+		return nil
+	}
+	// Every quiz on the milestone day sees the same streak; the key keeps
+	// one activity per streak and length
+	key := fmt.Sprintf("%s:%d", start.Format(time.DateOnly), streak)
+	return s.record(ctx, event.UserID, models.ActivityStreakMilestone, key, nil,
+		event.CompletedAt, models.StreakActivity{Days: streak})
+}
+
+// RecordGroupJoined records a user joining a group
+func (s *activityService) RecordGroupJoined(ctx context.Context, event events.MemberJoinedGroup) error {
+	ctx, span := tracing.Start(ctx, "ActivityService.RecordGroupJoined")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, event.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.IsGuest {
+		return nil
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, event.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group: %w", err)
+	}
+	if group == nil {
+		// Deleted before the event was handled
+		return nil
+	}
+
+	return s.record(ctx, event.UserID, models.ActivityJoinedGroup, event.MembershipID.String(), &group.ID,
+		event.JoinedAt, models.JoinedGroupActivity{GroupID: group.ID, GroupName: group.Name})
+}
+
+// GetFeed returns a page of the activity of the user's friends, newest
+// first. cursor is empty for the first page, then the previous page's
+// NextCursor.
+func (s *activityService) GetFeed(ctx context.Context, userID uuid.UUID, cursor string, limit int) (*models.ActivityFeed, error) {
+	ctx, span := tracing.Start(ctx, "ActivityService.GetFeed")
+	defer span.End()
+
+	if limit <= 0 {
+		limit = defaultFeedLimit
+	}
+	limit = min(limit, maxFeedLimit)
+
+	var after *models.ActivityCursor
+	if cursor != "" {
+		var err error
+		if after, err = decodeActivityCursor(cursor); err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", ErrInvalidInput)
+		}
+	}
+
+	// Fetch one extra to tell whether there is another page
+	activities, err := s.activityRepo.GetFeed(ctx, userID, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get activity feed: %w", err)
+	}
+
+	feed := &models.ActivityFeed{Activities: activities}
+	if len(activities) > limit {
+		feed.Activities = activities[:limit]
+		last := feed.Activities[limit-1]
+		feed.NextCursor = encodeActivityCursor(&models.ActivityCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	if feed.Activities == nil {
+		feed.Activities = []*models.Activity{}
+	}
+	return feed, nil
+}
+
+// record stores an activity with data encoded as JSON
+func (s *activityService) record(ctx context.Context, userID uuid.UUID, kind, dedupeKey string, groupID *uuid.UUID, at time.Time, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode activity: %w", err)
+	}
+
+	err = s.activityRepo.Create(ctx, &models.Activity{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Kind:      kind,
+		DedupeKey: dedupeKey,
+		GroupID:   groupID,
+		Data:      encoded,
+		CreatedAt: at,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	return nil
+}
+
+// streakEndingOn counts the consecutive active days (UTC dates, newest
+// first) ending on the date of at, which counts as active, and returns the
+// count with the streak's first day. Days after at are ignored.
+func streakEndingOn(days []time.Time, at time.Time) (int, time.Time) {
+	start := at.UTC().Truncate(24 * time.Hour)
+	streak := 1
+	for _, day := range days {
+		if !day.Before(start) {
+			continue
+		}
+		if !sameDate(day, start.AddDate(0, 0, -1)) {
+			break
+		}
+		streak++
+		start = start.AddDate(0, 0, -1)
+	}
+	return streak, start
+}
+
+// encodeActivityCursor makes an opaque feed cursor
+func encodeActivityCursor(cursor *models.ActivityCursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "_" + cursor.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeActivityCursor reads a cursor made by encodeActivityCursor
+func decodeActivityCursor(cursor string) (*models.ActivityCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+
+	decoded := &models.ActivityCursor{}
+	if decoded.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, err
+	}
+	if decoded.ID, err = uuid.Parse(id); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestStreakEndingOn(t *testing.T) {
+	at := time.Date(2026, time.October, 18, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		days      []time.Time
+		at        time.Time
+		want      int
+		wantStart time.Time
+	}{
+		{"first active day", nil, at, 1, day(18)},
+		{"already active today", []time.Time{day(18)}, at, 1, day(18)},
+		{"continues yesterday", []time.Time{day(18), day(17), day(16)}, at, 3, day(16)},
+		{"gap before yesterday", []time.Time{day(17), day(15)}, at, 2, day(17)},
+		{"last active two days ago", []time.Time{day(16), day(15)}, at, 1, day(18)},
+		{"later days ignored", []time.Time{day(20), day(19), day(17)}, at, 2, day(17)},
+		{"across a month boundary", []time.Time{day(1), time.Date(2026, time.September, 30, 0, 0, 0, 0, time.UTC)},
+			time.Date(2026, time.October, 2, 9, 0, 0, 0, time.UTC), 3, time.Date(2026, time.September, 30, 0, 0, 0, 0, time.UTC)},
+		{"at in another time zone", []time.Time{day(17)},
+			time.Date(2026, time.October, 19, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), 2, day(17)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, start := streakEndingOn(tt.days, tt.at)
+			if got != tt.want || !start.Equal(tt.wantStart) {
+				t.Errorf("streakEndingOn() = %d, %s; want %d, %s", got, start.Format(time.DateOnly), tt.want, tt.wantStart.Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestActivityCursor(t *testing.T) {
+	cursor := &models.ActivityCursor{
+		CreatedAt: time.Date(2026, time.October, 18, 15, 30, 0, 123456789, time.UTC),
+		ID:        uuid.New(),
+	}
+	decoded, err := decodeActivityCursor(encodeActivityCursor(cursor))
+	if err != nil {
+		t.Fatalf("decodeActivityCursor: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Errorf("decoded cursor = %+v, want %+v", decoded, cursor)
+	}
+
+	for _, bad := range []string{"", "!!!", "bm8tc2VwYXJhdG9y", "bm90LWEtdGltZV8x"} {
+		if _, err := decodeActivityCursor(bad); err == nil {
+			t.Errorf("decodeActivityCursor(%q) succeeded, want an error", bad)
+		}
+	}
+}
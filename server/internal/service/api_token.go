@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxAPITokens bounds how many tokens a user can hold
+	maxAPITokens = 25
+	// maxAPITokenNameLength matches the api_tokens.name column
+	maxAPITokenNameLength = 100
+	// maxAPITokenDays bounds the lifetime of an expiring token
+	maxAPITokenDays = 365
+	// apiTokenDisplayLength is how much of a token is kept to identify it
+	apiTokenDisplayLength = len(models.APITokenPrefix) + 8
+)
+
+// apiTokenService implements the APITokenService interface
+type apiTokenService struct {
+	tokenRepo repository.APITokenRepository
+	// touchInterval throttles last_used_at writes to one per interval
+	touchInterval time.Duration
+}
+
+// NewAPITokenService creates a new API token service instance
+func NewAPITokenService(repos *repository.Repositories, touchInterval time.Duration) APITokenService {
+	return &apiTokenService{
+		tokenRepo:     repos.APIToken,
+		touchInterval: touchInterval,
+	}
+}
+
+// CreateToken issues a personal access token with the given scopes. The
+// token is returned once and only its hash is stored.
+func (s *apiTokenService) CreateToken(ctx context.Context, userID uuid.UUID, req *models.CreateAPITokenRequest) (*models.CreateAPITokenResponse, error) {
+	ctx, span := tracing.Start(ctx, "APITokenService.CreateToken")
+	defer span.End()
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxAPITokenNameLength {
+		return nil, fmt.Errorf("name must be 1-%d characters: %w", maxAPITokenNameLength, ErrInvalidInput)
+	}
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required: %w", ErrInvalidInput)
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.APIScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q: %w", scope, ErrInvalidInput)
+		}
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPITokenDays {
+		return nil, fmt.Errorf("expires_in_days must be between 1 and %d: %w", maxAPITokenDays, ErrInvalidInput)
+	}
+
+	count, err := s.tokenRepo.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	if count >= maxAPITokens {
+		return nil, fmt.Errorf("you can have at most %d API tokens; revoke one first: %w", maxAPITokens, ErrConflict)
+	}
+
+	raw, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	raw = models.APITokenPrefix + raw
+
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	now := time.Now()
+	token := &models.APIToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    raw[:apiTokenDisplayLength],
+		TokenHash: hashToken(raw),
+		Scopes:    slices.Compact(scopes),
+		CreatedAt: now,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.tokenRepo.Create(ctx, token); err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	return &models.CreateAPITokenResponse{APIToken: token, Token: raw}, nil
+}
+
+// ListTokens returns a user's tokens, newest first
+func (s *apiTokenService) ListTokens(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
+	ctx, span := tracing.Start(ctx, "APITokenService.ListTokens")
+	defer span.End()
+
+	tokens, err := s.tokenRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	if tokens == nil {
+		tokens = []*models.APIToken{}
+	}
+	return tokens, nil
+}
+
+// RevokeToken deletes one of the user's tokens, which stops working at once
+func (s *apiTokenService) RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "APITokenService.RevokeToken")
+	defer span.End()
+
+	err := s.tokenRepo.Delete(ctx, tokenID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("token %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// ValidateToken returns the stored token for a raw one, or ErrInvalidToken
+// if it is unknown or has expired. It also bumps last_used_at, at most once
+// per touch interval.
+func (s *apiTokenService) ValidateToken(ctx context.Context, raw string) (*models.APIToken, error) {
+	ctx, span := tracing.Start(ctx, "APITokenService.ValidateToken")
+	defer span.End()
+
+	token, err := s.tokenRepo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	if token == nil || (token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt)) {
+		return nil, ErrInvalidToken
+	}
+
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) >= s.touchInterval {
+		if err := s.tokenRepo.Touch(ctx, token.ID); err != nil {
+			return nil, fmt.Errorf("failed to touch token: %w", err)
+		}
+	}
+
+	return token, nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+
+	"github.com/andy-dam/iq-theory/server/internal/config"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/imaging"
+	"github.com/andy-dam/iq-theory/server/pkg/storage"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+// avatarSizes are the square renditions stored for each avatar, largest
+// first. AvatarURL points at the largest; the others sit beside it, named
+// by their size.
+var avatarSizes = []int{256, 128, 64}
+
+// avatarService implements the AvatarService interface
+type avatarService struct {
+	store    storage.Storage
+	avatar   config.AvatarConfig
+	userRepo repository.UserRepository
+}
+
+// NewAvatarService creates a new avatar service instance
+func NewAvatarService(repos *repository.Repositories, store storage.Storage, avatar config.AvatarConfig) AvatarService {
+	return &avatarService{
+		store:    store,
+		avatar:   avatar,
+		userRepo: repos.User,
+	}
+}
+
+// UploadAvatar replaces the user's avatar with an uploaded PNG or JPEG. The
+// image is turned upright, centre-cropped to a square and stored at each of
+// avatarSizes in its original format, with all metadata stripped.
+func (s *avatarService) UploadAvatar(ctx context.Context, userID uuid.UUID, data []byte) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "AvatarService.UploadAvatar")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	img, format, err := imaging.Decode(data, s.avatar.MaxPixels)
+	if err != nil {
+		if errors.Is(err, imaging.ErrUnsupportedFormat) || errors.Is(err, imaging.ErrTooLarge) {
+			return nil, fmt.Errorf("%w: %w", err, ErrInvalidInput)
+		}
+		return nil, err
+	}
+
+	// A fresh prefix per upload keeps cached copies of the old avatar from
+	// being served under the new URL
+	prefix := "avatars/" + userID.String() + "/" + uuid.NewString()
+	ext := map[string]string{imaging.FormatPNG: ".png", imaging.FormatJPEG: ".jpg"}[format]
+
+	var stored []string
+	rendition := img
+	for _, size := range avatarSizes {
+		square := imaging.Square(rendition, size)
+		rendition = square
+
+		encoded, err := imaging.Encode(square, format)
+		if err != nil {
+			s.deleteObjects(ctx, stored)
+			return nil, err
+		}
+		key := prefix + "/" + strconv.Itoa(size) + ext
+		if err := s.store.Put(ctx, key, imaging.ContentType(format), encoded); err != nil {
+			s.deleteObjects(ctx, stored)
+			return nil, fmt.Errorf("failed to store avatar: %w", err)
+		}
+		stored = append(stored, key)
+	}
+
+	avatarKey := stored[0]
+	avatarURL := s.store.URL(avatarKey)
+	if err := s.userRepo.SetAvatar(ctx, userID, &avatarURL, &avatarKey); err != nil {
+		s.deleteObjects(ctx, stored)
+		return nil, fmt.Errorf("failed to set avatar: %w", err)
+	}
+
+	if user.AvatarKey != nil {
+		s.deleteObjects(ctx, avatarKeys(*user.AvatarKey))
+	}
+	user.AvatarURL = &avatarURL
+	user.AvatarKey = &avatarKey
+	return user, nil
+}
+
+// DeleteAvatar removes the user's avatar, deleting the stored files if it
+// was uploaded
+func (s *avatarService) DeleteAvatar(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "AvatarService.DeleteAvatar")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	if err := s.userRepo.SetAvatar(ctx, userID, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to remove avatar: %w", err)
+	}
+
+	if user.AvatarKey != nil {
+		s.deleteObjects(ctx, avatarKeys(*user.AvatarKey))
+	}
+	user.AvatarURL = nil
+	user.AvatarKey = nil
+	return user, nil
+}
+
+// deleteObjects removes stored files on a best-effort basis. A file left
+// behind is only wasted space, so failures are logged rather than returned.
+func (s *avatarService) deleteObjects(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete stored file %s: %v", key, err)
+		}
+	}
+}
+
+// avatarKeys returns the keys of every rendition of the avatar whose
+// largest rendition is stored under key
+func avatarKeys(key string) []string {
+	dir, ext := path.Dir(key), path.Ext(key)
+	keys := make([]string, len(avatarSizes))
+	for i, size := range avatarSizes {
+		keys[i] = dir + "/" + strconv.Itoa(size) + ext
+	}
+	return keys
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+// trendWindow is how many of the latest attempts on a configuration are
+// compared with the same number before them to give its trend
+const trendWindow = 5
+
+// errNotComparable is returned when comparing users who are neither friends
+// nor group-mates
+var errNotComparable = fmt.Errorf("you can only compare yourself with friends and group-mates: %w", ErrForbidden)
+
+// configKey identifies a quiz configuration
+type configKey struct {
+	clef           string
+	duration       int
+	maxLedgerLines int
+}
+
+// noteKey identifies a note on a clef
+type noteKey struct {
+	clef string
+	note string
+}
+
+// CompareUsers compares userID's quiz results with otherID's, per quiz
+// configuration and per note. Users can only compare themselves with
+// friends and members of a group they are in.
+func (s *userService) CompareUsers(ctx context.Context, userID, otherID uuid.UUID) (*models.HeadToHead, error) {
+	ctx, span := tracing.Start(ctx, "UserService.CompareUsers")
+	defer span.End()
+
+	if userID == otherID {
+		return nil, fmt.Errorf("cannot compare yourself with yourself: %w", ErrInvalidInput)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	other, err := s.userRepo.GetByID(ctx, otherID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || other == nil || other.IsGuest || other.DeletedAt != nil {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	if err := s.checkComparable(ctx, userID, otherID); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.quizSessionRepo.GetConfigStats(ctx, userID, otherID, trendWindow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz stats: %w", err)
+	}
+	notes, err := s.quizAnswerRepo.GetNoteAccuracy(ctx, userID, otherID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note accuracy: %w", err)
+	}
+
+	result := &models.HeadToHead{
+		User:           userSummary(user),
+		Other:          userSummary(other),
+		Configurations: []*models.ConfigComparison{},
+		Notes:          []*models.NoteComparison{},
+	}
+
+	// Rows come sorted by configuration and note; the comparisons keep that
+	// order
+	configs := make(map[configKey]*models.ConfigComparison)
+	for _, stat := range stats {
+		key := configKey{stat.Clef, stat.DurationSeconds, stat.MaxLedgerLines}
+		comparison, ok := configs[key]
+		if !ok {
+			comparison = &models.ConfigComparison{
+				Clef:            stat.Clef,
+				DurationSeconds: stat.DurationSeconds,
+				MaxLedgerLines:  stat.MaxLedgerLines,
+			}
+			configs[key] = comparison
+			result.Configurations = append(result.Configurations, comparison)
+		}
+		if stat.UserID == userID {
+			comparison.User = stat
+		} else {
+			comparison.Other = stat
+		}
+	}
+
+	byNote := make(map[noteKey]*models.NoteComparison)
+	for _, note := range notes {
+		key := noteKey{note.Clef, note.Note}
+		comparison, ok := byNote[key]
+		if !ok {
+			comparison = &models.NoteComparison{Clef: note.Clef, Note: note.Note}
+			byNote[key] = comparison
+			result.Notes = append(result.Notes, comparison)
+		}
+		if note.UserID == userID {
+			comparison.User = note
+		} else {
+			comparison.Other = note
+		}
+	}
+
+	return result, nil
+}
+
+// checkComparable returns ErrForbidden unless the two users are friends or
+// share a group. A block between them rules out both.
+func (s *userService) checkComparable(ctx context.Context, userID, otherID uuid.UUID) error {
+	blocks, err := s.blockRepo.GetBetween(ctx, userID, otherID)
+	if err != nil {
+		return fmt.Errorf("failed to get blocks: %w", err)
+	}
+	if len(blocks) > 0 {
+		return errNotComparable
+	}
+
+	friendship, err := s.friendshipRepo.GetBetween(ctx, userID, otherID)
+	if err != nil {
+		return fmt.Errorf("failed to get friendship: %w", err)
+	}
+	if friendship != nil && friendship.Status == models.FriendshipAccepted {
+		return nil
+	}
+
+	shares, err := s.membershipRepo.SharesGroup(ctx, userID, otherID)
+	if err != nil {
+		return fmt.Errorf("failed to check groups: %w", err)
+	}
+	if !shares {
+		return errNotComparable
+	}
+	return nil
+}
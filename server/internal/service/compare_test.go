@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestCompareUsers(t *testing.T) {
+	deletedAt := time.Now()
+	me := &models.User{ID: uuid.New()}
+	friend := &models.User{ID: uuid.New()}
+	groupMate := &models.User{ID: uuid.New()}
+	blockedFriend := &models.User{ID: uuid.New()}
+	stranger := &models.User{ID: uuid.New()}
+	deleted := &models.User{ID: uuid.New(), DeletedAt: &deletedAt}
+	requested := &models.User{ID: uuid.New()}
+	groupID := uuid.New()
+
+	s := &userService{
+		userRepo: newFakeUserRepo(me, friend, groupMate, blockedFriend, stranger, deleted, requested),
+		friendshipRepo: newFakeFriendshipRepo(
+			&models.Friendship{ID: uuid.New(), RequesterID: me.ID, AddresseeID: friend.ID, Status: models.FriendshipAccepted},
+			&models.Friendship{ID: uuid.New(), RequesterID: blockedFriend.ID, AddresseeID: me.ID, Status: models.FriendshipAccepted},
+			&models.Friendship{ID: uuid.New(), RequesterID: me.ID, AddresseeID: requested.ID, Status: models.FriendshipPending},
+			&models.Friendship{ID: uuid.New(), RequesterID: me.ID, AddresseeID: deleted.ID, Status: models.FriendshipAccepted},
+		),
+		blockRepo: &fakeBlockRepo{blocks: []*models.UserBlock{{BlockerID: blockedFriend.ID, BlockedID: me.ID}}},
+		membershipRepo: &fakeMembershipRepo{memberships: []*models.GroupMembership{
+			{UserID: me.ID, GroupID: groupID},
+			{UserID: groupMate.ID, GroupID: groupID},
+			{UserID: stranger.ID, GroupID: uuid.New()},
+		}},
+		quizSessionRepo: &fakeQuizSessionRepo{},
+		quizAnswerRepo:  fakeQuizAnswerRepo{},
+	}
+
+	tests := []struct {
+		name    string
+		otherID uuid.UUID
+		wantErr error
+	}{
+		{"friend", friend.ID, nil},
+		{"group-mate", groupMate.ID, nil},
+		{"stranger", stranger.ID, ErrForbidden},
+		{"pending request", requested.ID, ErrForbidden},
+		{"friend who blocked me", blockedFriend.ID, ErrForbidden},
+		{"deleted friend", deleted.ID, ErrNotFound},
+		{"unknown user", uuid.New(), ErrNotFound},
+		{"myself", me.ID, ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.CompareUsers(context.Background(), me.ID, tt.otherID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("CompareUsers() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompareUsers() error = %v", err)
+			}
+			if result.Configurations == nil || result.Notes == nil {
+				t.Error("CompareUsers() returned nil lists, want empty ones")
+			}
+		})
+	}
+}
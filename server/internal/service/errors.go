@@ -0,0 +1,16 @@
+package service
+
+import "errors"
+
+// Sentinel errors that handlers map to HTTP status codes. Wrap them to add
+// the subject, e.g. fmt.Errorf("user %w", ErrNotFound) reads "user not found".
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("already exists")
+	ErrInvalidInput       = errors.New("invalid input")
+	ErrForbidden          = errors.New("forbidden")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrEmailNotVerified   = errors.New("email address not verified")
+	ErrTooManyRequests    = errors.New("too many requests")
+)
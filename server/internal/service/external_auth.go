@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 50
+)
+
+// AuthenticateFirebaseUser maps a verified Firebase identity to a user. An
+// unknown Firebase UID is linked to the existing account with the same email
+// when Firebase has verified that email, and otherwise gets a new account.
+func (s *userService) AuthenticateFirebaseUser(ctx context.Context, identity *models.ExternalIdentity) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.AuthenticateFirebaseUser")
+	defer span.End()
+
+	user, err := s.userRepo.GetByFirebaseUID(ctx, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by Firebase UID: %w", err)
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	user, err = s.linkOrProvision(ctx, identity, func(ctx context.Context, user *models.User) error {
+		return s.userRepo.SetFirebaseUID(ctx, user.ID, identity.Subject)
+	})
+	if err != nil {
+		// A concurrent request for the same new Firebase user may have won
+		if existing, lookupErr := s.userRepo.GetByFirebaseUID(ctx, identity.Subject); lookupErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// linkOrProvision finds the account an external identity belongs to by
+// verified email, or creates one, and calls link to record the identity on
+// it. Linking is refused unless both sides have verified the email: anyone
+// can claim an address at a provider that does not check it, and anyone can
+// register an account with someone else's address to take over their first
+// provider sign-in. Managed students are never linked, since their teachers
+// can reset them.
+func (s *userService) linkOrProvision(ctx context.Context, identity *models.ExternalIdentity, link func(ctx context.Context, user *models.User) error) (*models.User, error) {
+	email := strings.TrimSpace(identity.Email)
+	if email == "" {
+		return nil, fmt.Errorf("%s account has no email address: %w", identity.Provider, ErrInvalidInput)
+	}
+
+	var user *models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		existing, err := s.userRepo.GetByEmail(ctx, email)
+		if err != nil {
+			return fmt.Errorf("failed to get user by email: %w", err)
+		}
+
+		if existing != nil {
+			if !identity.EmailVerified {
+				return fmt.Errorf("an account with this email %w; sign in with your password or verify the email with %s first",
+					ErrConflict, identity.Provider)
+			}
+			if !existing.EmailVerified {
+				return fmt.Errorf("an account with this email %w; sign in with your password and verify the email first",
+					ErrConflict)
+			}
+			if existing.ManagedByGroupID != nil {
+				return fmt.Errorf("an account with this email %w and is managed by a group; sign in with your username",
+					ErrConflict)
+			}
+			user = existing
+			return link(ctx, user)
+		}
+
+		username, err := s.uniqueUsername(ctx, email)
+		if err != nil {
+			return err
+		}
+
+		displayName := strings.TrimSpace(identity.DisplayName)
+		if displayName == "" {
+			displayName = username
+		}
+		var avatarURL *string
+		if identity.AvatarURL != "" {
+			avatarURL = &identity.AvatarURL
+		}
+
+		// No password: the user signs in through the provider, or sets one
+		// with a password reset
+		now := time.Now()
+		user = &models.User{
+			ID:            uuid.New(),
+			Email:         email,
+			Username:      username,
+			DisplayName:   displayName,
+			AvatarURL:     avatarURL,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			IsActive:      true,
+			EmailVerified: identity.EmailVerified,
+		}
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		return link(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// uniqueUsername derives an unused username from the local part of an email
+func (s *userService) uniqueUsername(ctx context.Context, email string) (string, error) {
+	local, _, _ := strings.Cut(email, "@")
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			return unicode.ToLower(r)
+		case r == '.' || r == '-' || r == '_':
+			return '_'
+		default:
+			return -1
+		}
+	}, local)
+	for len(base) < minUsernameLength {
+		base += "_"
+	}
+	// Leave room for the random suffix
+	if len(base) > maxUsernameLength-5 {
+		base = base[:maxUsernameLength-5]
+	}
+
+	candidate := base
+	for range 5 {
+		existing, err := s.userRepo.GetByUsername(ctx, candidate)
+		if err != nil {
+			return "", fmt.Errorf("failed to check existing username: %w", err)
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+
+		suffix, err := generateRandomString(4)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate username: %w", err)
+		}
+		candidate = base + "_" + suffix
+	}
+
+	return "", fmt.Errorf("failed to find a free username for %q", base)
+}
+
+// AuthenticateExternalUser maps a verified OIDC identity to a user through
+// user_identities, linking or provisioning the account on first sign-in
+func (s *userService) AuthenticateExternalUser(ctx context.Context, identity *models.ExternalIdentity) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.AuthenticateExternalUser")
+	defer span.End()
+
+	linked, err := s.identityRepo.GetByProviderSubject(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	if linked != nil {
+		user, err := s.userRepo.GetByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil {
+			return nil, ErrInvalidCredentials
+		}
+		if err := s.identityRepo.Touch(ctx, linked.ID); err != nil {
+			return nil, fmt.Errorf("failed to touch identity: %w", err)
+		}
+		return user, nil
+	}
+
+	user, err := s.linkOrProvision(ctx, identity, func(ctx context.Context, user *models.User) error {
+		return s.createIdentity(ctx, user.ID, identity)
+	})
+	if err != nil {
+		// A concurrent callback for the same new identity may have won
+		if existing, lookupErr := s.identityRepo.GetByProviderSubject(ctx, identity.Provider, identity.Subject); lookupErr == nil && existing != nil {
+			return s.GetUserByID(ctx, existing.UserID)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// LinkExternalIdentity links a verified OIDC identity to a signed-in user
+func (s *userService) LinkExternalIdentity(ctx context.Context, userID uuid.UUID, identity *models.ExternalIdentity) error {
+	ctx, span := tracing.Start(ctx, "UserService.LinkExternalIdentity")
+	defer span.End()
+
+	linked, err := s.identityRepo.GetByProviderSubject(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to get identity: %w", err)
+	}
+	if linked != nil {
+		if linked.UserID != userID {
+			return fmt.Errorf("this %s account is linked to another user: %w", identity.Provider, ErrConflict)
+		}
+		return s.identityRepo.Touch(ctx, linked.ID)
+	}
+
+	return s.createIdentity(ctx, userID, identity)
+}
+
+func (s *userService) createIdentity(ctx context.Context, userID uuid.UUID, identity *models.ExternalIdentity) error {
+	now := time.Now()
+	err := s.identityRepo.Create(ctx, &models.UserIdentity{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Provider:   identity.Provider,
+		Subject:    identity.Subject,
+		Email:      identity.Email,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+// The fakes below keep rows in memory and implement only what the tests
+// call; the embedded interfaces panic on anything else.
+
+// fakeTx runs the function without a transaction
+type fakeTx struct{}
+
+func (fakeTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[uuid.UUID]*models.User
+}
+
+func newFakeUserRepo(users ...*models.User) *fakeUserRepo {
+	repo := &fakeUserRepo{users: make(map[uuid.UUID]*models.User)}
+	for _, user := range users {
+		repo.users[user.ID] = user
+	}
+	return repo
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) Update(ctx context.Context, user *models.User) error {
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+type fakeSessionRepo struct {
+	repository.SessionRepository
+	sessions map[uuid.UUID]*models.Session
+}
+
+func newFakeSessionRepo(sessions ...*models.Session) *fakeSessionRepo {
+	repo := &fakeSessionRepo{sessions: make(map[uuid.UUID]*models.Session)}
+	for _, session := range sessions {
+		repo.sessions[session.ID] = session
+	}
+	return repo
+}
+
+func (r *fakeSessionRepo) Create(ctx context.Context, session *models.Session) error {
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *fakeSessionRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	return r.sessions[id], nil
+}
+
+func (r *fakeSessionRepo) RevokeAll(ctx context.Context, userID uuid.UUID) error {
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeThrottleRepo struct {
+	repository.LoginThrottleRepository
+	throttles map[[2]string]*models.LoginThrottle
+}
+
+func newFakeThrottleRepo() *fakeThrottleRepo {
+	return &fakeThrottleRepo{throttles: make(map[[2]string]*models.LoginThrottle)}
+}
+
+func (r *fakeThrottleRepo) Get(ctx context.Context, scope, subject string) (*models.LoginThrottle, error) {
+	throttle, ok := r.throttles[[2]string{scope, subject}]
+	if !ok {
+		return nil, nil
+	}
+	copied := *throttle
+	return &copied, nil
+}
+
+func (r *fakeThrottleRepo) RecordFailure(ctx context.Context, scope, subject string, forgetBefore time.Time) (*models.LoginThrottle, error) {
+	key := [2]string{scope, subject}
+	throttle, ok := r.throttles[key]
+	if !ok {
+		throttle = &models.LoginThrottle{Scope: scope, Subject: subject}
+		r.throttles[key] = throttle
+	}
+	if throttle.LastFailureAt.Before(forgetBefore) {
+		throttle.Failures, throttle.Lockouts = 0, 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = time.Now()
+	copied := *throttle
+	return &copied, nil
+}
+
+func (r *fakeThrottleRepo) Lock(ctx context.Context, scope, subject string, until time.Time) error {
+	if throttle, ok := r.throttles[[2]string{scope, subject}]; ok {
+		throttle.LockedUntil = &until
+		throttle.Lockouts++
+		throttle.Failures = 0
+	}
+	return nil
+}
+
+func (r *fakeThrottleRepo) Clear(ctx context.Context, scope, subject string) error {
+	delete(r.throttles, [2]string{scope, subject})
+	return nil
+}
+
+type fakeTOTPRepo struct {
+	repository.UserTOTPRepository
+	enrolments map[uuid.UUID]*models.UserTOTP
+}
+
+func (r *fakeTOTPRepo) Get(ctx context.Context, userID uuid.UUID) (*models.UserTOTP, error) {
+	return r.enrolments[userID], nil
+}
+
+type fakeRecoveryCodeRepo struct {
+	repository.RecoveryCodeRepository
+	// hashes holds each user's unused code hashes
+	hashes map[uuid.UUID]map[string]bool
+}
+
+func (r *fakeRecoveryCodeRepo) ReplaceAll(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	r.hashes[userID] = make(map[string]bool)
+	for _, hash := range codeHashes {
+		r.hashes[userID][hash] = true
+	}
+	return nil
+}
+
+func (r *fakeRecoveryCodeRepo) Use(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	if !r.hashes[userID][codeHash] {
+		return sql.ErrNoRows
+	}
+	delete(r.hashes[userID], codeHash)
+	return nil
+}
+
+type fakeQuizSessionRepo struct {
+	repository.QuizSessionRepository
+	sessions map[uuid.UUID]*models.QuizSession
+}
+
+func (r *fakeQuizSessionRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.QuizSession, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *fakeQuizSessionRepo) Complete(ctx context.Context, id uuid.UUID, score int, timeTaken int) error {
+	session, ok := r.sessions[id]
+	if !ok || session.Status != "in_progress" {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	session.Status = "completed"
+	session.Score = score
+	session.TimeTakenSeconds = &timeTaken
+	session.CompletedAt = &now
+	return nil
+}
+
+type fakeLeaderboardRepo struct {
+	repository.LeaderboardRepository
+	refreshes int
+}
+
+func (r *fakeLeaderboardRepo) RefreshLeaderboard(ctx context.Context) error {
+	r.refreshes++
+	return nil
+}
+
+type fakeOutboxRepo struct {
+	repository.OutboxRepository
+	events []*models.OutboxEvent
+}
+
+func (r *fakeOutboxRepo) Create(ctx context.Context, event *models.OutboxEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *fakeOutboxRepo) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	var pending []*models.OutboxEvent
+	for _, event := range r.events {
+		if event.Status == "pending" && len(pending) < limit {
+			event.Attempts++
+			pending = append(pending, event)
+		}
+	}
+	return pending, nil
+}
+
+func (r *fakeOutboxRepo) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	for _, event := range r.events {
+		if event.ID == id {
+			event.Status = "delivered"
+		}
+	}
+	return nil
+}
+
+func (r *fakeOutboxRepo) MarkFailed(ctx context.Context, id uuid.UUID, lastError string, retryAt *time.Time) error {
+	for _, event := range r.events {
+		if event.ID == id {
+			event.Status = "failed"
+			event.LastError = &lastError
+		}
+	}
+	return nil
+}
+
+type fakeFriendshipRepo struct {
+	repository.FriendshipRepository
+	friendships map[uuid.UUID]*models.Friendship
+}
+
+func newFakeFriendshipRepo(friendships ...*models.Friendship) *fakeFriendshipRepo {
+	repo := &fakeFriendshipRepo{friendships: make(map[uuid.UUID]*models.Friendship)}
+	for _, friendship := range friendships {
+		repo.friendships[friendship.ID] = friendship
+	}
+	return repo
+}
+
+func (r *fakeFriendshipRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Friendship, error) {
+	friendship, ok := r.friendships[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *friendship
+	return &copied, nil
+}
+
+func (r *fakeFriendshipRepo) GetBetween(ctx context.Context, userID, otherID uuid.UUID) (*models.Friendship, error) {
+	for _, friendship := range r.friendships {
+		if (friendship.RequesterID == userID && friendship.AddresseeID == otherID) ||
+			(friendship.RequesterID == otherID && friendship.AddresseeID == userID) {
+			copied := *friendship
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeFriendshipRepo) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+	friendship, ok := r.friendships[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	friendship.Status = status
+	return nil
+}
+
+func (r *fakeQuizSessionRepo) GetConfigStats(ctx context.Context, userID, otherID uuid.UUID, trendWindow int) ([]*models.ConfigStats, error) {
+	return nil, nil
+}
+
+type fakeQuizAnswerRepo struct {
+	repository.QuizAnswerRepository
+}
+
+func (fakeQuizAnswerRepo) GetNoteAccuracy(ctx context.Context, userID, otherID uuid.UUID) ([]*models.NoteAccuracy, error) {
+	return nil, nil
+}
+
+type fakeBlockRepo struct {
+	repository.BlockRepository
+	blocks []*models.UserBlock
+}
+
+func (r *fakeBlockRepo) GetBetween(ctx context.Context, userID, otherID uuid.UUID) ([]*models.UserBlock, error) {
+	var between []*models.UserBlock
+	for _, block := range r.blocks {
+		if (block.BlockerID == userID && block.BlockedID == otherID) ||
+			(block.BlockerID == otherID && block.BlockedID == userID) {
+			between = append(between, block)
+		}
+	}
+	return between, nil
+}
+
+type fakeMembershipRepo struct {
+	repository.GroupMembershipRepository
+	memberships []*models.GroupMembership
+}
+
+func (r *fakeMembershipRepo) GetUserMemberships(ctx context.Context, userID uuid.UUID) ([]*models.GroupMembership, error) {
+	var memberships []*models.GroupMembership
+	for _, membership := range r.memberships {
+		if membership.UserID == userID {
+			memberships = append(memberships, membership)
+		}
+	}
+	return memberships, nil
+}
+
+func (r *fakeMembershipRepo) SharesGroup(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	for _, mine := range r.memberships {
+		for _, theirs := range r.memberships {
+			if mine.UserID == userID && theirs.UserID == otherID && mine.GroupID == theirs.GroupID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeUserRepo) SetSiteRole(ctx context.Context, id uuid.UUID, role string) error {
+	user, ok := r.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	user.SiteRole = role
+	return nil
+}
+
+func (r *fakeUserRepo) DeleteGuest(ctx context.Context, id uuid.UUID) error {
+	if user, ok := r.users[id]; ok && user.IsGuest {
+		delete(r.users, id)
+	}
+	return nil
+}
+
+func (r *fakeQuizSessionRepo) Reassign(ctx context.Context, fromUserID, toUserID uuid.UUID) (int, error) {
+	n := 0
+	for _, session := range r.sessions {
+		if session.UserID == fromUserID {
+			session.UserID = toUserID
+			n++
+		}
+	}
+	return n, nil
+}
+
+type fakeDataExportRepo struct {
+	repository.DataExportRepository
+	exports map[uuid.UUID]*models.DataExport
+}
+
+func (r *fakeDataExportRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error) {
+	export, ok := r.exports[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *export
+	return &copied, nil
+}
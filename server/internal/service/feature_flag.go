@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+var flagKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,99}$`)
+
+// FlagSubject is who a feature flag is evaluated for
+type FlagSubject struct {
+	UserID   uuid.UUID
+	GroupIDs []uuid.UUID
+	Role     string
+}
+
+// featureFlagService implements the FeatureFlagService interface
+type featureFlagService struct {
+	flagRepo            repository.FeatureFlagRepository
+	groupMembershipRepo repository.GroupMembershipRepository
+	roles               *siteRoles
+	cacheTTL            time.Duration
+
+	mu       sync.RWMutex
+	cache    map[string]*models.FeatureFlag
+	loadedAt time.Time
+}
+
+// NewFeatureFlagService creates a new feature flag service instance
+func NewFeatureFlagService(repos *repository.Repositories, adminIDs []uuid.UUID, cacheTTL time.Duration) FeatureFlagService {
+	return &featureFlagService{
+		flagRepo:            repos.FeatureFlag,
+		groupMembershipRepo: repos.GroupMembership,
+		roles:               &siteRoles{userRepo: repos.User, adminIDs: adminIDs},
+		cacheTTL:            cacheTTL,
+	}
+}
+
+// ListFlags retrieves all feature flags
+func (s *featureFlagService) ListFlags(ctx context.Context) ([]*models.FeatureFlag, error) {
+	ctx, span := tracing.Start(ctx, "FeatureFlagService.ListFlags")
+	defer span.End()
+
+	flags, err := s.flagRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feature flags: %w", err)
+	}
+	return flags, nil
+}
+
+// CreateFlag creates a new feature flag
+func (s *featureFlagService) CreateFlag(ctx context.Context, req *models.FeatureFlagRequest) (*models.FeatureFlag, error) {
+	ctx, span := tracing.Start(ctx, "FeatureFlagService.CreateFlag")
+	defer span.End()
+
+	if err := validateFlagRequest(req); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.flagRepo.GetByKey(ctx, req.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing feature flag: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("feature flag %q %w", req.Key, ErrConflict)
+	}
+
+	now := time.Now()
+	flag := &models.FeatureFlag{CreatedAt: now}
+	applyFlagRequest(flag, req, now)
+
+	if err := s.flagRepo.Create(ctx, flag); err != nil {
+		return nil, fmt.Errorf("failed to create feature flag: %w", err)
+	}
+
+	s.invalidate()
+	return flag, nil
+}
+
+// UpdateFlag replaces the rules of an existing feature flag
+func (s *featureFlagService) UpdateFlag(ctx context.Context, req *models.FeatureFlagRequest) (*models.FeatureFlag, error) {
+	ctx, span := tracing.Start(ctx, "FeatureFlagService.UpdateFlag")
+	defer span.End()
+
+	if err := validateFlagRequest(req); err != nil {
+		return nil, err
+	}
+
+	flag, err := s.flagRepo.GetByKey(ctx, req.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feature flag: %w", err)
+	}
+	if flag == nil {
+		return nil, fmt.Errorf("feature flag %q %w", req.Key, ErrNotFound)
+	}
+
+	applyFlagRequest(flag, req, time.Now())
+
+	if err := s.flagRepo.Update(ctx, flag); err != nil {
+		return nil, fmt.Errorf("failed to update feature flag: %w", err)
+	}
+
+	s.invalidate()
+	return flag, nil
+}
+
+// DeleteFlag removes a feature flag
+func (s *featureFlagService) DeleteFlag(ctx context.Context, key string) error {
+	ctx, span := tracing.Start(ctx, "FeatureFlagService.DeleteFlag")
+	defer span.End()
+
+	if err := s.flagRepo.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete feature flag: %w", err)
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// IsEnabled evaluates a single flag for a user. Unknown flags are off.
+func (s *featureFlagService) IsEnabled(ctx context.Context, key string, userID uuid.UUID) (bool, error) {
+	ctx, span := tracing.Start(ctx, "FeatureFlagService.IsEnabled")
+	defer span.End()
+
+	flags, err := s.flags(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	flag, ok := flags[key]
+	if !ok {
+		return false, nil
+	}
+
+	subject, err := s.subject(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return evaluateFlag(flag, subject), nil
+}
+
+// EvaluateAll evaluates every flag for a user
+func (s *featureFlagService) EvaluateAll(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	ctx, span := tracing.Start(ctx, "FeatureFlagService.EvaluateAll")
+	defer span.End()
+
+	flags, err := s.flags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := s.subject(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]bool, len(flags))
+	for key, flag := range flags {
+		result[key] = evaluateFlag(flag, subject)
+	}
+	return result, nil
+}
+
+// subject gathers the targeting attributes of a user
+func (s *featureFlagService) subject(ctx context.Context, userID uuid.UUID) (*FlagSubject, error) {
+	memberships, err := s.groupMembershipRepo.GetUserMemberships(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group memberships: %w", err)
+	}
+
+	subject := &FlagSubject{UserID: userID}
+	for _, membership := range memberships {
+		subject.GroupIDs = append(subject.GroupIDs, membership.GroupID)
+	}
+	role, err := s.roles.roleOf(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	subject.Role = role
+
+	return subject, nil
+}
+
+// flags returns the cached flags, reloading them once the cache expires
+func (s *featureFlagService) flags(ctx context.Context) (map[string]*models.FeatureFlag, error) {
+	s.mu.RLock()
+	if s.cache != nil && time.Since(s.loadedAt) < s.cacheTTL {
+		defer s.mu.RUnlock()
+		return s.cache, nil
+	}
+	s.mu.RUnlock()
+
+	list, err := s.flagRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feature flags: %w", err)
+	}
+
+	flags := make(map[string]*models.FeatureFlag, len(list))
+	for _, flag := range list {
+		flags[flag.Key] = flag
+	}
+
+	s.mu.Lock()
+	s.cache = flags
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return flags, nil
+}
+
+// invalidate drops the cache so this instance sees its own writes at once
+func (s *featureFlagService) invalidate() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
+
+// evaluateFlag decides whether a flag is on for a subject. Disabled flags
+// are off for everyone; explicit user, group or role targets are always on;
+// everyone else falls into the percentage rollout.
+func evaluateFlag(flag *models.FeatureFlag, subject *FlagSubject) bool {
+	if !flag.Enabled {
+		return false
+	}
+
+	if slices.Contains(flag.TargetUserIDs, subject.UserID) {
+		return true
+	}
+	for _, groupID := range subject.GroupIDs {
+		if slices.Contains(flag.TargetGroupIDs, groupID) {
+			return true
+		}
+	}
+	if subject.Role != "" && slices.Contains(flag.TargetRoles, subject.Role) {
+		return true
+	}
+
+	return rolloutBucket(flag.Key, subject.UserID) < flag.RolloutPercentage
+}
+
+// rolloutBucket assigns a user a stable bucket in [0, 100) per flag, so
+// raising the percentage only ever adds users
+func rolloutBucket(key string, userID uuid.UUID) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	h.Write([]byte{':'})
+	h.Write(userID[:])
+	return int(h.Sum32() % 100)
+}
+
+func validateFlagRequest(req *models.FeatureFlagRequest) error {
+	if !flagKeyPattern.MatchString(req.Key) {
+		return fmt.Errorf("%w: flag key must be lowercase letters, digits, '.', '_' or '-'", ErrInvalidInput)
+	}
+	if req.RolloutPercentage < 0 || req.RolloutPercentage > 100 {
+		return fmt.Errorf("%w: rollout percentage must be between 0 and 100", ErrInvalidInput)
+	}
+	return nil
+}
+
+// applyFlagRequest copies a request onto a flag. Omitted targets become
+// empty lists, since the target columns are NOT NULL.
+func applyFlagRequest(flag *models.FeatureFlag, req *models.FeatureFlagRequest, now time.Time) {
+	flag.Key = req.Key
+	flag.Description = req.Description
+	flag.Enabled = req.Enabled
+	flag.RolloutPercentage = req.RolloutPercentage
+	flag.TargetUserIDs = req.TargetUserIDs
+	if flag.TargetUserIDs == nil {
+		flag.TargetUserIDs = []uuid.UUID{}
+	}
+	flag.TargetGroupIDs = req.TargetGroupIDs
+	if flag.TargetGroupIDs == nil {
+		flag.TargetGroupIDs = []uuid.UUID{}
+	}
+	flag.TargetRoles = req.TargetRoles
+	if flag.TargetRoles == nil {
+		flag.TargetRoles = []string{}
+	}
+	flag.UpdatedAt = now
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestAnswerFriendRequest(t *testing.T) {
+	requester, addressee, stranger := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name       string
+		userID     uuid.UUID
+		status     string
+		decline    bool
+		wantErr    error
+		wantStatus string
+	}{
+		{name: "addressee accepts", userID: addressee, status: models.FriendshipPending, wantStatus: models.FriendshipAccepted},
+		{name: "addressee declines", userID: addressee, status: models.FriendshipPending, decline: true, wantStatus: models.FriendshipDeclined},
+		{name: "requester cannot accept", userID: requester, status: models.FriendshipPending, wantErr: ErrForbidden},
+		{name: "requester cannot decline", userID: requester, status: models.FriendshipPending, decline: true, wantErr: ErrForbidden},
+		{name: "hidden from anyone else", userID: stranger, status: models.FriendshipPending, wantErr: ErrNotFound},
+		{name: "already answered", userID: addressee, status: models.FriendshipDeclined, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			friendship := &models.Friendship{ID: uuid.New(), RequesterID: requester, AddresseeID: addressee, Status: tt.status}
+			s := &friendshipService{tx: fakeTx{}, friendshipRepo: newFakeFriendshipRepo(friendship)}
+
+			answer := s.AcceptFriendRequest
+			if tt.decline {
+				answer = s.DeclineFriendRequest
+			}
+			err := answer(context.Background(), tt.userID, friendship.ID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if friendship.Status != tt.status {
+					t.Errorf("status changed to %s", friendship.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if friendship.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", friendship.Status, tt.wantStatus)
+			}
+		})
+	}
+}
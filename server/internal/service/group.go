@@ -2,32 +2,133 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/andy-dam/iq-theory/server/internal/events"
 	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
 	"github.com/google/uuid"
 )
 
+const (
+	// maxGroupNameLength matches the groups.name column
+	maxGroupNameLength = 100
+	// defaultGroupSize and maxGroupSize bound max_members
+	defaultGroupSize = 100
+	maxGroupSize     = 1000
+	// joinCodeLength is the number of hex characters in a join code
+	joinCodeLength = 8
+)
+
 // groupService implements the GroupServiceInterface
 type groupService struct {
+	tx                  repository.Transactor
+	events              events.Publisher
 	groupRepo           repository.GroupRepository
 	groupMembershipRepo repository.GroupMembershipRepository
 	userRepo            repository.UserRepository
+	totpRepo            repository.UserTOTPRepository
+	permissions         PermissionService
+	// sessionRepo, throttleRepo and loginCodeRepo back the credentials of
+	// managed students
+	sessionRepo   repository.SessionRepository
+	throttleRepo  repository.LoginThrottleRepository
+	loginCodeRepo repository.StudentLoginCodeRepository
 }
 
 // NewGroupService creates a new group service instance
-func NewGroupService(repos *repository.Repositories) GroupService {
+func NewGroupService(repos *repository.Repositories, permissions PermissionService) GroupService {
 	return &groupService{
+		tx:                  repos.Tx,
+		permissions:         permissions,
+		events:              events.NewPublisher(repos.Outbox),
 		groupRepo:           repos.Group,
 		groupMembershipRepo: repos.GroupMembership,
 		userRepo:            repos.User,
+		totpRepo:            repos.UserTOTP,
+		sessionRepo:         repos.Session,
+		throttleRepo:        repos.LoginThrottle,
+		loginCodeRepo:       repos.StudentLoginCode,
 	}
 }
 
-// CreateGroup creates a new group
+// CreateGroup creates a new group with its creator as the first admin.
+// Only teachers and site admins can create groups.
 func (s *groupService) CreateGroup(ctx context.Context, creatorID uuid.UUID, name, description string, maxMembers int) (*models.Group, error) {
-	// TODO: Implement group creation logic
-	return nil, nil
+	ctx, span := tracing.Start(ctx, "GroupService.CreateGroup")
+	defer span.End()
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxGroupNameLength {
+		return nil, fmt.Errorf("name must be 1-%d characters: %w", maxGroupNameLength, ErrInvalidInput)
+	}
+	if maxMembers == 0 {
+		maxMembers = defaultGroupSize
+	}
+	if maxMembers < 1 || maxMembers > maxGroupSize {
+		return nil, fmt.Errorf("max_members must be between 1 and %d: %w", maxGroupSize, ErrInvalidInput)
+	}
+
+	if err := s.permissions.RequirePermission(ctx, creatorID, models.PermissionCreateGroup); err != nil {
+		return nil, err
+	}
+	if err := s.requireVerifiedEmail(ctx, creatorID); err != nil {
+		return nil, err
+	}
+
+	joinCode, err := s.newJoinCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	group := &models.Group{
+		ID:         uuid.New(),
+		Name:       name,
+		JoinCode:   joinCode,
+		CreatedBy:  creatorID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		IsActive:   true,
+		MaxMembers: maxMembers,
+	}
+	if description = strings.TrimSpace(description); description != "" {
+		group.Description = &description
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.groupRepo.Create(ctx, group); err != nil {
+			return fmt.Errorf("failed to create group: %w", err)
+		}
+		return s.addMember(ctx, creatorID, group.ID, "admin")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// newJoinCode returns a join code no active group uses
+func (s *groupService) newJoinCode(ctx context.Context) (string, error) {
+	for range 5 {
+		code, err := generateRandomString(joinCodeLength)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate join code: %w", err)
+		}
+		code = strings.ToUpper(code)
+
+		existing, err := s.groupRepo.GetByJoinCode(ctx, code)
+		if err != nil {
+			return "", fmt.Errorf("failed to check join code: %w", err)
+		}
+		if existing == nil {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate a unique join code")
 }
 
 // GetGroupByID retrieves a group by ID
@@ -62,34 +163,238 @@ func (s *groupService) DeleteGroup(ctx context.Context, groupID uuid.UUID) error
 
 // JoinGroup joins a group using join code
 func (s *groupService) JoinGroup(ctx context.Context, userID uuid.UUID, joinCode string) error {
-	// TODO: Implement join group logic
-	return nil
+	ctx, span := tracing.Start(ctx, "GroupService.JoinGroup")
+	defer span.End()
+
+	if err := s.requireVerifiedEmail(ctx, userID); err != nil {
+		return err
+	}
+
+	group, err := s.groupRepo.GetByJoinCode(ctx, joinCode)
+	if err != nil {
+		return fmt.Errorf("failed to get group: %w", err)
+	}
+	if group == nil {
+		return fmt.Errorf("group %w", ErrNotFound)
+	}
+
+	return s.addMember(ctx, userID, group.ID, "member")
 }
 
 // JoinGroupByID joins a group by ID
 func (s *groupService) JoinGroupByID(ctx context.Context, userID, groupID uuid.UUID) error {
-	// TODO: Implement
+	ctx, span := tracing.Start(ctx, "GroupService.JoinGroupByID")
+	defer span.End()
+
+	if err := s.requireVerifiedEmail(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.addMember(ctx, userID, groupID, "member")
+}
+
+// requireVerifiedEmail returns ErrEmailNotVerified unless the user has
+// confirmed their email address
+func (s *groupService) requireVerifiedEmail(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+	if !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
 	return nil
 }
 
+// addMember adds a user to a group with the given role and publishes
+// MemberJoinedGroup in the same transaction
+func (s *groupService) addMember(ctx context.Context, userID, groupID uuid.UUID, role string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		group, err := s.groupRepo.LockByID(ctx, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to get group: %w", err)
+		}
+		if group == nil {
+			return fmt.Errorf("group %w", ErrNotFound)
+		}
+
+		existing, err := s.groupMembershipRepo.GetByUserAndGroup(ctx, userID, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to check membership: %w", err)
+		}
+		if existing != nil {
+			return fmt.Errorf("already a member of this group")
+		}
+
+		members, err := s.groupMembershipRepo.GetGroupMembers(ctx, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to get group members: %w", err)
+		}
+		if len(members) >= group.MaxMembers {
+			return fmt.Errorf("group is full")
+		}
+
+		membership := &models.GroupMembership{
+			ID:       uuid.New(),
+			UserID:   userID,
+			GroupID:  groupID,
+			Role:     role,
+			JoinedAt: time.Now(),
+		}
+		if err := s.groupMembershipRepo.Create(ctx, membership); err != nil {
+			return fmt.Errorf("failed to join group: %w", err)
+		}
+
+		return s.events.Publish(ctx, events.MemberJoinedGroup{
+			MembershipID: membership.ID,
+			GroupID:      groupID,
+			UserID:       userID,
+			Role:         role,
+			JoinedAt:     membership.JoinedAt,
+		})
+	})
+}
+
 // LeaveGroup removes a user from a group
 func (s *groupService) LeaveGroup(ctx context.Context, userID, groupID uuid.UUID) error {
 	// TODO: Implement
 	return nil
 }
 
-// RemoveMember removes a member from a group (admin action)
+// RemoveMember removes a member from a group. Group admins and site
+// moderators can remove members.
 func (s *groupService) RemoveMember(ctx context.Context, adminID, memberID, groupID uuid.UUID) error {
-	// TODO: Implement permission checking and member removal
+	ctx, span := tracing.Start(ctx, "GroupService.RemoveMember")
+	defer span.End()
+
+	moderator, err := s.permissions.HasPermission(ctx, adminID, models.PermissionModerate)
+	if err != nil {
+		return err
+	}
+	if !moderator {
+		if _, err := s.requireGroupAdmin(ctx, adminID, groupID); err != nil {
+			return err
+		}
+	}
+
+	membership, err := s.groupMembershipRepo.GetByUserAndGroup(ctx, memberID, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to get membership: %w", err)
+	}
+	if membership == nil {
+		return fmt.Errorf("member %w", ErrNotFound)
+	}
+
+	if err := s.groupMembershipRepo.Delete(ctx, membership.ID); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
 	return nil
 }
 
-// UpdateMemberRole updates a member's role in a group
+// UpdateMemberRole updates a member's role in a group. In a group that
+// requires 2FA for admins, only members with 2FA can be promoted.
 func (s *groupService) UpdateMemberRole(ctx context.Context, adminID, memberID, groupID uuid.UUID, role string) error {
-	// TODO: Implement permission checking and role update
+	ctx, span := tracing.Start(ctx, "GroupService.UpdateMemberRole")
+	defer span.End()
+
+	if role != "admin" && role != "member" {
+		return fmt.Errorf("role must be admin or member: %w", ErrInvalidInput)
+	}
+
+	group, err := s.requireGroupAdmin(ctx, adminID, groupID)
+	if err != nil {
+		return err
+	}
+
+	membership, err := s.groupMembershipRepo.GetByUserAndGroup(ctx, memberID, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to get membership: %w", err)
+	}
+	if membership == nil {
+		return fmt.Errorf("member %w", ErrNotFound)
+	}
+
+	if role == "admin" && group.RequireAdmin2FA {
+		enabled, err := mfaEnabled(ctx, s.totpRepo, memberID)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return fmt.Errorf("group requires admins to use two-factor authentication: %w", ErrForbidden)
+		}
+	}
+
+	if err := s.groupMembershipRepo.UpdateRole(ctx, membership.ID, role); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
+// SetAdminMFARequired sets whether the group's admins must use two-factor
+// authentication. An admin can only turn it on once they use 2FA
+// themselves.
+func (s *groupService) SetAdminMFARequired(ctx context.Context, adminID, groupID uuid.UUID, required bool) error {
+	ctx, span := tracing.Start(ctx, "GroupService.SetAdminMFARequired")
+	defer span.End()
+
+	group, err := s.requireGroupAdmin(ctx, adminID, groupID)
+	if err != nil {
+		return err
+	}
+
+	if required {
+		enabled, err := mfaEnabled(ctx, s.totpRepo, adminID)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return fmt.Errorf("enable two-factor authentication first: %w", ErrForbidden)
+		}
+	}
+
+	group.RequireAdmin2FA = required
+	group.UpdatedAt = time.Now()
+	if err := s.groupRepo.Update(ctx, group); err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
 	return nil
 }
 
+// requireGroupAdmin returns the group if the user is one of its admins and,
+// when the group requires it, uses two-factor authentication
+func (s *groupService) requireGroupAdmin(ctx context.Context, userID, groupID uuid.UUID) (*models.Group, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	if group == nil {
+		return nil, fmt.Errorf("group %w", ErrNotFound)
+	}
+
+	membership, err := s.groupMembershipRepo.GetByUserAndGroup(ctx, userID, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check membership: %w", err)
+	}
+	if membership == nil || membership.Role != "admin" {
+		return nil, fmt.Errorf("group admin role required: %w", ErrForbidden)
+	}
+
+	if group.RequireAdmin2FA {
+		enabled, err := mfaEnabled(ctx, s.totpRepo, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return nil, fmt.Errorf("group requires admins to use two-factor authentication: %w", ErrForbidden)
+		}
+	}
+
+	return group, nil
+}
+
 // GetGroupMembers retrieves all members of a group
 func (s *groupService) GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]*models.GroupMembership, error) {
 	// TODO: Implement
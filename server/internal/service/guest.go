@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/events"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+// guestPurgeBatch is how many stale guests are deleted per query
+const guestPurgeBatch = 500
+
+// CreateGuest issues an anonymous account that can play quizzes. It has a
+// placeholder email and username and no password, so the only way back in
+// is the token issued for it. Each client IP can create GuestsPerIP guests
+// per GuestWindow.
+func (s *userService) CreateGuest(ctx context.Context, ipAddress string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.CreateGuest")
+	defer span.End()
+
+	if err := s.countGuest(ctx, ipAddress); err != nil {
+		return nil, err
+	}
+
+	suffix, err := generateRandomString(12)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate guest username: %w", err)
+	}
+
+	now := time.Now()
+	id := uuid.New()
+	user := &models.User{
+		ID:          id,
+		Email:       "guest-" + id.String() + "@guest.invalid",
+		Username:    "guest_" + suffix,
+		DisplayName: "Guest",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		IsActive:    true,
+		IsGuest:     true,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to create guest: %w", err)
+	}
+
+	return user, nil
+}
+
+// countGuest counts a guest created from a client IP in login_throttles,
+// returning ErrTooManyRequests once the IP has used up its guests for the
+// window
+func (s *userService) countGuest(ctx context.Context, ipAddress string) error {
+	if ipAddress == "" || s.account.GuestsPerIP <= 0 {
+		return nil
+	}
+
+	throttle, err := s.throttleRepo.Get(ctx, models.LoginThrottleGuestIP, ipAddress)
+	if err != nil {
+		return fmt.Errorf("failed to get guest throttle: %w", err)
+	}
+	if throttle != nil && throttle.LockedUntil != nil && time.Now().Before(*throttle.LockedUntil) {
+		wait := time.Until(*throttle.LockedUntil).Truncate(time.Minute) + time.Minute
+		return fmt.Errorf("too many guest accounts from this network, sign up or try again in %s: %w",
+			formatDuration(wait), ErrTooManyRequests)
+	}
+
+	now := time.Now()
+	throttle, err = s.throttleRepo.RecordFailure(ctx, models.LoginThrottleGuestIP, ipAddress, now.Add(-s.account.GuestWindow))
+	if err != nil {
+		return fmt.Errorf("failed to count guest: %w", err)
+	}
+	if throttle.Failures >= s.account.GuestsPerIP {
+		if err := s.throttleRepo.Lock(ctx, models.LoginThrottleGuestIP, ipAddress, now.Add(s.account.GuestWindow)); err != nil {
+			return fmt.Errorf("failed to lock guest creation: %w", err)
+		}
+	}
+	return nil
+}
+
+// UpgradeGuest turns a guest into a registered account with the given
+// credentials. The user ID stays the same, so the guest's quiz history and
+// signed-in sessions carry over.
+func (s *userService) UpgradeGuest(ctx context.Context, guestID uuid.UUID, req *models.CreateUserRequest) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpgradeGuest")
+	defer span.End()
+
+	var user *models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		guest, err := s.getGuest(ctx, guestID)
+		if err != nil {
+			return err
+		}
+
+		hashedPassword, err := s.checkRegistration(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		guest.Email = req.Email
+		guest.Username = req.Username
+		guest.DisplayName = req.DisplayName
+		guest.PasswordHash = hashedPassword
+		guest.IsGuest = false
+		guest.UpdatedAt = time.Now()
+		err = s.userRepo.ConvertGuest(ctx, guest)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("guest %w", ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to upgrade guest: %w", err)
+		}
+		user = guest
+
+		// Guests that never finished a quiz change no leaderboard
+		completed, err := s.quizSessionRepo.CountCompleted(ctx, guestID)
+		if err != nil {
+			return fmt.Errorf("failed to count completed quizzes: %w", err)
+		}
+		if completed == 0 {
+			return nil
+		}
+		return s.events.Publish(ctx, events.GuestUpgraded{GuestID: guestID, UserID: guestID})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// As with registration, a mail failure must not undo the sign-up
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+
+	return user, nil
+}
+
+// MergeGuest moves a guest's quiz history into a registered account and
+// deletes the guest, which also signs it out everywhere. It returns how many
+// quiz sessions were moved.
+func (s *userService) MergeGuest(ctx context.Context, userID, guestID uuid.UUID) (int, error) {
+	ctx, span := tracing.Start(ctx, "UserService.MergeGuest")
+	defer span.End()
+
+	var merged int
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil {
+			return fmt.Errorf("user %w", ErrNotFound)
+		}
+		if user.IsGuest {
+			return fmt.Errorf("guests cannot merge other guests; sign up first: %w", ErrInvalidInput)
+		}
+
+		if _, err := s.getGuest(ctx, guestID); err != nil {
+			return err
+		}
+
+		merged, err = s.quizSessionRepo.Reassign(ctx, guestID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to move quiz sessions: %w", err)
+		}
+		if err := s.userRepo.DeleteGuest(ctx, guestID); err != nil {
+			return fmt.Errorf("failed to delete guest: %w", err)
+		}
+
+		if merged == 0 {
+			return nil
+		}
+		return s.events.Publish(ctx, events.GuestUpgraded{GuestID: guestID, UserID: userID, MergedSessions: merged})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return merged, nil
+}
+
+// PurgeStaleGuests deletes guests that have not been used for the guest
+// retention period, with their quiz history, and returns how many it deleted
+func (s *userService) PurgeStaleGuests(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "UserService.PurgeStaleGuests")
+	defer span.End()
+
+	cutoff := time.Now().Add(-s.account.GuestRetention)
+	total := 0
+	for {
+		n, err := s.userRepo.DeleteStaleGuests(ctx, cutoff, guestPurgeBatch)
+		if err != nil {
+			return total, fmt.Errorf("failed to delete stale guests: %w", err)
+		}
+		total += n
+		if n < guestPurgeBatch {
+			return total, nil
+		}
+	}
+}
+
+// getGuest loads a guest, returning ErrNotFound for unknown users and
+// ErrInvalidInput for registered ones
+func (s *userService) getGuest(ctx context.Context, guestID uuid.UUID) (*models.User, error) {
+	guest, err := s.userRepo.GetByID(ctx, guestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest: %w", err)
+	}
+	if guest == nil {
+		return nil, fmt.Errorf("guest %w", ErrNotFound)
+	}
+	if !guest.IsGuest {
+		return nil, fmt.Errorf("account is already registered: %w", ErrInvalidInput)
+	}
+	return guest, nil
+}
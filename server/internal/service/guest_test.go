@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/andy-dam/iq-theory/server/internal/events"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestMergeGuest(t *testing.T) {
+	ctx := context.Background()
+	user := &models.User{ID: uuid.New()}
+	otherUser := &models.User{ID: uuid.New()}
+	guest := &models.User{ID: uuid.New(), IsGuest: true}
+	emptyGuest := &models.User{ID: uuid.New(), IsGuest: true}
+	guestSessions := []*models.QuizSession{{ID: uuid.New(), UserID: guest.ID}, {ID: uuid.New(), UserID: guest.ID}}
+	userSession := &models.QuizSession{ID: uuid.New(), UserID: user.ID}
+
+	users := newFakeUserRepo(user, otherUser, guest, emptyGuest)
+	quizSessions := &fakeQuizSessionRepo{sessions: make(map[uuid.UUID]*models.QuizSession)}
+	for _, session := range append(guestSessions, userSession) {
+		quizSessions.sessions[session.ID] = session
+	}
+	outbox := &fakeOutboxRepo{}
+	s := &userService{tx: fakeTx{}, events: events.NewPublisher(outbox), userRepo: users, quizSessionRepo: quizSessions}
+
+	t.Run("refused", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			userID  uuid.UUID
+			guestID uuid.UUID
+			want    error
+		}{
+			{"into a guest", emptyGuest.ID, guest.ID, ErrInvalidInput},
+			{"a registered account", user.ID, otherUser.ID, ErrInvalidInput},
+			{"an unknown guest", user.ID, uuid.New(), ErrNotFound},
+		}
+		for _, tt := range tests {
+			if _, err := s.MergeGuest(ctx, tt.userID, tt.guestID); !errors.Is(err, tt.want) {
+				t.Errorf("MergeGuest(%s) error = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+		if len(users.users) != 4 || len(outbox.events) != 0 {
+			t.Fatal("a refused merge changed users or published an event")
+		}
+	})
+
+	merged, err := s.MergeGuest(ctx, user.ID, guest.ID)
+	if err != nil {
+		t.Fatalf("MergeGuest: %v", err)
+	}
+	if merged != len(guestSessions) {
+		t.Errorf("merged %d sessions, want %d", merged, len(guestSessions))
+	}
+	for _, session := range guestSessions {
+		if session.UserID != user.ID {
+			t.Errorf("session %s still belongs to %s", session.ID, session.UserID)
+		}
+	}
+	if _, ok := users.users[guest.ID]; ok {
+		t.Error("guest was not deleted")
+	}
+	if len(outbox.events) != 1 || outbox.events[0].EventType != events.TypeGuestUpgraded {
+		t.Fatalf("published %d events, want one %s", len(outbox.events), events.TypeGuestUpgraded)
+	}
+	var event events.GuestUpgraded
+	if err := json.Unmarshal(outbox.events[0].Payload, &event); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if event.GuestID != guest.ID || event.UserID != user.ID || event.MergedSessions != merged {
+		t.Errorf("event = %+v", event)
+	}
+
+	// A guest without quiz history is deleted without an event
+	if merged, err := s.MergeGuest(ctx, user.ID, emptyGuest.ID); err != nil || merged != 0 {
+		t.Errorf("MergeGuest(empty guest) = %d, %v; want 0, nil", merged, err)
+	}
+	if _, ok := users.users[emptyGuest.ID]; ok || len(outbox.events) != 1 {
+		t.Error("merging an empty guest left it in place or published an event")
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/oidc"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+// identityService implements the IdentityService interface
+type identityService struct {
+	users        UserService
+	userRepo     repository.UserRepository
+	identityRepo repository.UserIdentityRepository
+	stateRepo    repository.OIDCLoginStateRepository
+	providers    map[string]*oidc.Provider
+	// stateTTL is how long a user has to finish signing in at the provider
+	stateTTL time.Duration
+}
+
+// NewIdentityService creates a new identity service instance
+func NewIdentityService(repos *repository.Repositories, users UserService, providers []*oidc.Provider, stateTTL time.Duration) IdentityService {
+	byName := make(map[string]*oidc.Provider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return &identityService{
+		users:        users,
+		userRepo:     repos.User,
+		identityRepo: repos.UserIdentity,
+		stateRepo:    repos.OIDCLoginState,
+		providers:    byName,
+		stateTTL:     stateTTL,
+	}
+}
+
+// Providers lists the names of the configured providers
+func (s *identityService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartLogin begins an authorization code flow and returns the provider URL
+// to send the user to. With linkUserID set, the callback links the identity
+// to that user instead of signing in.
+func (s *identityService) StartLogin(ctx context.Context, provider string, linkUserID *uuid.UUID) (string, error) {
+	ctx, span := tracing.Start(ctx, "IdentityService.StartLogin")
+	defer span.End()
+
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", fmt.Errorf("provider %w", ErrNotFound)
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", fmt.Errorf("failed to build authorization URL: %w", err)
+	}
+
+	now := time.Now()
+	err = s.stateRepo.Create(ctx, &models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       linkUserID,
+		ExpiresAt:    now.Add(s.stateTTL),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store login state: %w", err)
+	}
+
+	return authURL, nil
+}
+
+// CompleteLogin handles the provider's callback: it redeems the code, checks
+// the ID token, and signs the user in or links the identity
+func (s *identityService) CompleteLogin(ctx context.Context, provider, state, code string) (*models.OIDCLoginResult, error) {
+	ctx, span := tracing.Start(ctx, "IdentityService.CompleteLogin")
+	defer span.End()
+
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("provider %w", ErrNotFound)
+	}
+
+	stored, err := s.stateRepo.Consume(ctx, hashToken(state))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login state: %w", err)
+	}
+	if stored == nil || stored.Provider != provider || time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	claims, err := p.Exchange(ctx, code, stored.CodeVerifier, stored.Nonce)
+	if errors.Is(err, oidc.ErrInvalidIDToken) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete %s login: %w", provider, err)
+	}
+
+	identity := &models.ExternalIdentity{
+		Provider:      provider,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		DisplayName:   claims.Name,
+		AvatarURL:     claims.Picture,
+	}
+
+	if stored.UserID != nil {
+		if err := s.users.LinkExternalIdentity(ctx, *stored.UserID, identity); err != nil {
+			return nil, err
+		}
+		user, err := s.users.GetUserByID(ctx, *stored.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return &models.OIDCLoginResult{User: user, Linked: true}, nil
+	}
+
+	user, err := s.users.AuthenticateExternalUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OIDCLoginResult{User: user}, nil
+}
+
+// ListIdentities lists the identities linked to a user
+func (s *identityService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]*models.UserIdentity, error) {
+	ctx, span := tracing.Start(ctx, "IdentityService.ListIdentities")
+	defer span.End()
+
+	identities, err := s.identityRepo.GetUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identities: %w", err)
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity removes a linked identity, refusing to remove the user's
+// last way to sign in
+func (s *identityService) UnlinkIdentity(ctx context.Context, userID, identityID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "IdentityService.UnlinkIdentity")
+	defer span.End()
+
+	identity, err := s.identityRepo.GetByID(ctx, identityID)
+	if err != nil {
+		return fmt.Errorf("failed to get identity: %w", err)
+	}
+	if identity == nil || identity.UserID != userID {
+		return fmt.Errorf("identity %w", ErrNotFound)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+	identities, err := s.identityRepo.GetUserIdentities(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get identities: %w", err)
+	}
+	if user.PasswordHash == "" && user.FirebaseUID == nil && len(identities) <= 1 {
+		return fmt.Errorf("cannot unlink your only sign-in method; set a password first: %w", ErrInvalidInput)
+	}
+
+	if err := s.identityRepo.Delete(ctx, identityID); err != nil {
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+	return nil
+}
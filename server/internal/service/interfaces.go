@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"io"
+	"time"
 
+	"github.com/andy-dam/iq-theory/server/internal/events"
 	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/google/uuid"
 )
@@ -18,22 +21,138 @@ type UserService interface {
 	DeleteUser(ctx context.Context, userID uuid.UUID) error
 
 	// Authentication
-	AuthenticateUser(ctx context.Context, email, password string) (*models.User, error)
+	AuthenticateUser(ctx context.Context, email, password, ipAddress string) (*models.User, error)
+	AuthenticateStudent(ctx context.Context, username, password, ipAddress string) (*models.User, error)
+	AuthenticateLoginCode(ctx context.Context, code, ipAddress string) (*models.User, error)
 	ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error
+	AuthenticateFirebaseUser(ctx context.Context, identity *models.ExternalIdentity) (*models.User, error)
+	AuthenticateExternalUser(ctx context.Context, identity *models.ExternalIdentity) (*models.User, error)
+	LinkExternalIdentity(ctx context.Context, userID uuid.UUID, identity *models.ExternalIdentity) error
+
+	// Search and public profiles
+	SearchUsers(ctx context.Context, query string, limit, offset int) (*models.UserSearchResponse, error)
+	GetPublicProfile(ctx context.Context, userID uuid.UUID) (*models.PublicProfile, error)
+	CompareUsers(ctx context.Context, userID, otherID uuid.UUID) (*models.HeadToHead, error)
 
 	// Profile management
-	UpdateProfile(ctx context.Context, userID uuid.UUID, displayName string, avatarURL *string) error
+	UpdateProfile(ctx context.Context, userID uuid.UUID, displayName string) error
 	VerifyEmail(ctx context.Context, userID uuid.UUID) error
+
+	// Email verification
+	SendVerificationEmail(ctx context.Context, userID uuid.UUID) error
+	ConfirmEmail(ctx context.Context, token string) error
+
+	// Password reset
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+
+	// Guest accounts
+	CreateGuest(ctx context.Context, ipAddress string) (*models.User, error)
+	UpgradeGuest(ctx context.Context, guestID uuid.UUID, req *models.CreateUserRequest) (*models.User, error)
+	MergeGuest(ctx context.Context, userID, guestID uuid.UUID) (int, error)
+	PurgeStaleGuests(ctx context.Context) (int, error)
+
+	// Sign-in lockout
+	UnlockAccount(ctx context.Context, token string) error
+	ClearLockout(ctx context.Context, userID uuid.UUID) error
+	PurgeLoginThrottles(ctx context.Context) (int, error)
+}
+
+// SessionService defines methods for login session and device management
+type SessionService interface {
+	StartSession(ctx context.Context, userID uuid.UUID, userAgent, ipAddress string) (*models.Session, error)
+	ValidateSession(ctx context.Context, sessionID, userID uuid.UUID) error
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+}
+
+// APITokenService defines methods for personal access tokens
+type APITokenService interface {
+	CreateToken(ctx context.Context, userID uuid.UUID, req *models.CreateAPITokenRequest) (*models.CreateAPITokenResponse, error)
+	ListTokens(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error)
+	RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error
+	ValidateToken(ctx context.Context, token string) (*models.APIToken, error)
+}
+
+// MFAService defines methods for two-factor authentication
+type MFAService interface {
+	Status(ctx context.Context, userID uuid.UUID) (*models.MFAStatus, error)
+	BeginEnrollment(ctx context.Context, userID uuid.UUID) (*models.TOTPEnrollment, error)
+	ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	Disable(ctx context.Context, userID uuid.UUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+
+	// Login second step
+	BeginLogin(ctx context.Context, userID uuid.UUID) (*models.MFAChallengeResponse, error)
+	CompleteLogin(ctx context.Context, token, code string) (*models.User, error)
+}
+
+// PermissionService defines methods for site roles and the permissions
+// they grant
+type PermissionService interface {
+	GetPermissions(ctx context.Context, userID uuid.UUID) (*models.UserPermissions, error)
+	HasPermission(ctx context.Context, userID uuid.UUID, permission models.Permission) (bool, error)
+	RequirePermission(ctx context.Context, userID uuid.UUID, permission models.Permission) error
+
+	// Administration
+	SetSiteRole(ctx context.Context, actorID, userID uuid.UUID, role string) (*models.User, error)
+	ListUsersWithRole(ctx context.Context, role string) ([]*models.User, error)
+}
+
+// AvatarService defines methods for uploading and removing profile pictures
+type AvatarService interface {
+	UploadAvatar(ctx context.Context, userID uuid.UUID, data []byte) (*models.User, error)
+	DeleteAvatar(ctx context.Context, userID uuid.UUID) (*models.User, error)
+}
+
+// PreferencesService defines methods for reading and saving user preferences
+type PreferencesService interface {
+	GetPreferences(ctx context.Context, userID uuid.UUID) (*models.UserPreferences, error)
+	UpdatePreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserPreferences) (*models.UserPreferences, error)
+}
+
+// AccountService defines methods for personal data export and account deletion
+type AccountService interface {
+	RequestExport(ctx context.Context, userID uuid.UUID) (*models.DataExport, error)
+	ListExports(ctx context.Context, userID uuid.UUID) ([]*models.DataExport, error)
+	OpenExport(ctx context.Context, userID, exportID uuid.UUID) (*models.DataExport, []byte, error)
+	BuildExport(ctx context.Context, exportID uuid.UUID) error
+	ScheduleDeletion(ctx context.Context, userID uuid.UUID, password string) (time.Time, error)
+	CancelDeletion(ctx context.Context, userID uuid.UUID) error
+	PurgeDeletedAccounts(ctx context.Context) (int, error)
+	PurgeExpiredExports(ctx context.Context) (int, error)
+}
+
+// IdentityService defines methods for OIDC sign-in and linked identities
+type IdentityService interface {
+	Providers() []string
+	StartLogin(ctx context.Context, provider string, linkUserID *uuid.UUID) (string, error)
+	CompleteLogin(ctx context.Context, provider, state, code string) (*models.OIDCLoginResult, error)
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]*models.UserIdentity, error)
+	UnlinkIdentity(ctx context.Context, userID, identityID uuid.UUID) error
 }
 
 // FriendshipService defines methods for friendship-related business logic
 type FriendshipService interface {
-	SendFriendRequest(ctx context.Context, requesterID, addresseeID uuid.UUID) error
-	AcceptFriendRequest(ctx context.Context, friendshipID uuid.UUID) error
-	DeclineFriendRequest(ctx context.Context, friendshipID uuid.UUID) error
-	RemoveFriend(ctx context.Context, friendshipID uuid.UUID) error
+	SendFriendRequest(ctx context.Context, requesterID, addresseeID uuid.UUID) (*models.Friendship, error)
+	AcceptFriendRequest(ctx context.Context, userID, friendshipID uuid.UUID) error
+	DeclineFriendRequest(ctx context.Context, userID, friendshipID uuid.UUID) error
+	RemoveFriend(ctx context.Context, userID, friendshipID uuid.UUID) error
 	GetUserFriends(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error)
-	GetPendingRequests(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error)
+	GetIncomingRequests(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error)
+	GetOutgoingRequests(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error)
+	BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	GetBlockedUsers(ctx context.Context, userID uuid.UUID) ([]*models.UserBlock, error)
+	GetSuggestions(ctx context.Context, userID uuid.UUID, limit int) ([]*models.FriendSuggestion, error)
+}
+
+// ActivityService defines methods for the friend activity feed
+type ActivityService interface {
+	RecordQuizCompleted(ctx context.Context, event events.QuizCompleted) error
+	RecordGroupJoined(ctx context.Context, event events.MemberJoinedGroup) error
+	GetFeed(ctx context.Context, userID uuid.UUID, cursor string, limit int) (*models.ActivityFeed, error)
 }
 
 // GroupService defines methods for group-related business logic
@@ -51,7 +170,12 @@ type GroupService interface {
 	LeaveGroup(ctx context.Context, userID, groupID uuid.UUID) error
 	RemoveMember(ctx context.Context, adminID, memberID, groupID uuid.UUID) error
 	UpdateMemberRole(ctx context.Context, adminID, memberID, groupID uuid.UUID, role string) error
+	SetAdminMFARequired(ctx context.Context, adminID, groupID uuid.UUID, required bool) error
 	GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]*models.GroupMembership, error)
+
+	// Managed student accounts
+	ImportRoster(ctx context.Context, adminID, groupID uuid.UUID, roster io.Reader, credential string) ([]*models.StudentCredential, error)
+	ResetStudentCredential(ctx context.Context, adminID, groupID, studentID uuid.UUID, credential string) (*models.StudentCredential, error)
 }
 
 // QuizService defines methods for quiz-related business logic
@@ -63,11 +187,11 @@ type QuizService interface {
 	GetLedgerLineOptions(ctx context.Context) ([]*models.LedgerLineOption, error)
 
 	// Quiz session management
-	CreateQuizSession(ctx context.Context, userID uuid.UUID, clef string, duration int, maxLedgerLines int) (*models.QuizSession, error)
+	CreateQuizSession(ctx context.Context, userID uuid.UUID, req *models.StartQuizRequest) (*models.QuizSession, error)
 	GetQuizSession(ctx context.Context, sessionID uuid.UUID) (*models.QuizSession, error)
 	GetUserQuizSessions(ctx context.Context, userID uuid.UUID, limit int) ([]*models.QuizSession, error)
 	StartQuizSession(ctx context.Context, sessionID uuid.UUID) error
-	CompleteQuizSession(ctx context.Context, sessionID uuid.UUID) (*models.QuizSession, error)
+	CompleteQuizSession(ctx context.Context, userID, sessionID uuid.UUID) (*models.QuizSession, error)
 	AbandonQuizSession(ctx context.Context, sessionID uuid.UUID) error
 
 	// Question and answer management
@@ -85,5 +209,23 @@ type LeaderboardService interface {
 	GetGlobalLeaderboard(ctx context.Context, clef string, duration int, maxLedgerLines int, limit int) ([]*models.LeaderboardEntry, error)
 	GetUserRanking(ctx context.Context, userID uuid.UUID, clef string, duration int, maxLedgerLines int) (*models.LeaderboardEntry, error)
 	GetGroupLeaderboard(ctx context.Context, groupID uuid.UUID, clef string, duration int, maxLedgerLines int, limit int) ([]*models.LeaderboardEntry, error)
+	GetFriendsLeaderboard(ctx context.Context, userID uuid.UUID, clef string, duration int, maxLedgerLines int, limit, offset int) ([]*models.LeaderboardEntry, error)
 	RefreshLeaderboards(ctx context.Context) error
+	// MarkStale and RefreshIfStale coalesce the refreshes quiz results
+	// trigger into one per refresh interval
+	MarkStale()
+	RefreshIfStale(ctx context.Context) (bool, error)
+}
+
+// FeatureFlagService defines methods for feature flag management and evaluation
+type FeatureFlagService interface {
+	// Administration
+	ListFlags(ctx context.Context) ([]*models.FeatureFlag, error)
+	CreateFlag(ctx context.Context, req *models.FeatureFlagRequest) (*models.FeatureFlag, error)
+	UpdateFlag(ctx context.Context, req *models.FeatureFlagRequest) (*models.FeatureFlag, error)
+	DeleteFlag(ctx context.Context, key string) error
+
+	// Evaluation
+	IsEnabled(ctx context.Context, key string, userID uuid.UUID) (bool, error)
+	EvaluateAll(ctx context.Context, userID uuid.UUID) (map[string]bool, error)
 }
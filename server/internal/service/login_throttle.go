@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/mailer"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	// unlockTokenTTL is how long an emailed unlock link stays valid. It
+	// outlives the longest lockout so the link is useful for as long as it
+	// matters.
+	unlockTokenTTL = 24 * time.Hour
+	// unlockEmailTimeout bounds the background work of an unlock email
+	unlockEmailTimeout = 30 * time.Second
+)
+
+// UnlockAccount redeems an emailed unlock token and lifts the lockout on
+// the owner's email address
+func (s *userService) UnlockAccount(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "UserService.UnlockAccount")
+	defer span.End()
+
+	stored, err := s.userTokenRepo.GetByHash(ctx, models.TokenPurposeAccountUnlock, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to get unlock token: %w", err)
+	}
+	if stored == nil || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return ErrInvalidToken
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userTokenRepo.MarkUsed(ctx, stored.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("failed to use unlock token: %w", err)
+		}
+		if err := s.userTokenRepo.InvalidateAll(ctx, stored.UserID, models.TokenPurposeAccountUnlock); err != nil {
+			return fmt.Errorf("failed to invalidate unlock tokens: %w", err)
+		}
+		return s.clearAccountLockout(ctx, stored.UserID)
+	})
+}
+
+// ClearLockout lifts the sign-in lockout on a user's email address. It is
+// meant for site admins helping a locked-out user.
+func (s *userService) ClearLockout(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "UserService.ClearLockout")
+	defer span.End()
+
+	return s.clearAccountLockout(ctx, userID)
+}
+
+// PurgeLoginThrottles deletes failed sign-in counters that have been quiet
+// for the reset period
+func (s *userService) PurgeLoginThrottles(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "UserService.PurgeLoginThrottles")
+	defer span.End()
+
+	n, err := s.throttleRepo.DeleteStale(ctx, time.Now().Add(-s.lockout.ResetAfter))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete login throttles: %w", err)
+	}
+	return n, nil
+}
+
+// checkLockout returns ErrTooManyRequests if the email address or the
+// client IP is locked out
+func (s *userService) checkLockout(ctx context.Context, email, ipAddress string) error {
+	subjects := []struct{ scope, subject string }{
+		{models.LoginThrottleAccount, loginSubject(email)},
+		{models.LoginThrottleIP, ipAddress},
+	}
+	for _, subject := range subjects {
+		if subject.subject == "" {
+			continue
+		}
+		throttle, err := s.throttleRepo.Get(ctx, subject.scope, subject.subject)
+		if err != nil {
+			return fmt.Errorf("failed to get login throttle: %w", err)
+		}
+		if throttle != nil && throttle.LockedUntil != nil && time.Now().Before(*throttle.LockedUntil) {
+			wait := time.Until(*throttle.LockedUntil).Truncate(time.Minute) + time.Minute
+			return fmt.Errorf("too many failed sign-in attempts, try again in %s: %w", formatDuration(wait), ErrTooManyRequests)
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed sign-in against the email address and
+// the client IP, locking either once it reaches its threshold. The owner of
+// a newly locked account is emailed an unlock link in the background.
+// Unknown addresses are counted too, so neither a lockout nor the response
+// time reveals whether an account exists.
+func (s *userService) recordLoginFailure(ctx context.Context, email, ipAddress string, user *models.User) error {
+	locked, err := s.countFailure(ctx, models.LoginThrottleAccount, loginSubject(email), s.lockout.AccountThreshold)
+	if err != nil {
+		return err
+	}
+	if ipAddress != "" {
+		if _, err := s.countFailure(ctx, models.LoginThrottleIP, ipAddress, s.lockout.IPThreshold); err != nil {
+			return err
+		}
+	}
+
+	if locked != nil && user != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), unlockEmailTimeout)
+			defer cancel()
+
+			if err := s.sendUnlockEmail(ctx, user, *locked); err != nil {
+				log.Printf("Failed to send unlock email to user %s: %v", user.ID, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// countFailure records a failure for one subject and locks it when the
+// threshold is reached, returning the end of the new lockout if there is one
+func (s *userService) countFailure(ctx context.Context, scope, subject string, threshold int) (*time.Time, error) {
+	throttle, err := s.throttleRepo.RecordFailure(ctx, scope, subject, time.Now().Add(-s.lockout.ResetAfter))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	if threshold <= 0 || throttle.Failures < threshold {
+		return nil, nil
+	}
+
+	until := time.Now().Add(s.lockoutDuration(throttle.Lockouts))
+	if err := s.throttleRepo.Lock(ctx, scope, subject, until); err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", scope, err)
+	}
+	return &until, nil
+}
+
+// lockoutDuration doubles the base lockout for each earlier lockout, up to
+// the maximum
+func (s *userService) lockoutDuration(previous int) time.Duration {
+	d := s.lockout.Duration
+	for i := 0; i < previous && d < s.lockout.MaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, s.lockout.MaxDuration)
+}
+
+// clearAccountLockout forgets the failed sign-ins against a user's email
+func (s *userService) clearAccountLockout(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	if err := s.throttleRepo.Clear(ctx, models.LoginThrottleAccount, loginSubject(user.Email)); err != nil {
+		return fmt.Errorf("failed to clear lockout: %w", err)
+	}
+	return nil
+}
+
+// sendUnlockEmail tells the user their account was locked and mails a link
+// that unlocks it straight away
+func (s *userService) sendUnlockEmail(ctx context.Context, user *models.User, until time.Time) error {
+	token, err := s.issueToken(ctx, user.ID, models.TokenPurposeAccountUnlock, unlockTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.account.AppURL + "/unlock-account?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Your IQ Theory account was locked",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"There were several failed attempts to sign in to your IQ Theory account, so it has been locked until %s.\n\n"+
+			"If that was you, you can unlock it now:\n\n%s\n\n"+
+			"If it was not you, consider resetting your password.\n",
+			user.DisplayName, until.UTC().Format("15:04 MST on 2 January 2006"), link),
+	})
+}
+
+// loginSubject is the key account failures are counted under
+func loginSubject(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,514 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/config"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/qrcode"
+	"github.com/andy-dam/iq-theory/server/pkg/totp"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet leaves out characters that are easily confused
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	// totpSkew accepts codes from one step either side of now to allow for
+	// clock drift
+	totpSkew = 1
+	// qrScale is the number of pixels per QR module in enrolment images
+	qrScale = 6
+)
+
+// mfaService implements the MFAService interface
+type mfaService struct {
+	tx                  repository.Transactor
+	userRepo            repository.UserRepository
+	totpRepo            repository.UserTOTPRepository
+	recoveryCodeRepo    repository.RecoveryCodeRepository
+	challengeRepo       repository.MFAChallengeRepository
+	throttleRepo        repository.LoginThrottleRepository
+	groupRepo           repository.GroupRepository
+	groupMembershipRepo repository.GroupMembershipRepository
+	cfg                 config.MFAConfig
+	// aead encrypts TOTP secrets at rest
+	aead cipher.AEAD
+}
+
+// NewMFAService creates a new MFA service instance
+func NewMFAService(repos *repository.Repositories, cfg config.MFAConfig) MFAService {
+	key := sha256.Sum256([]byte(cfg.EncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		// A 32 byte key is always valid for AES-256
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	return &mfaService{
+		tx:                  repos.Tx,
+		userRepo:            repos.User,
+		totpRepo:            repos.UserTOTP,
+		recoveryCodeRepo:    repos.RecoveryCode,
+		challengeRepo:       repos.MFAChallenge,
+		throttleRepo:        repos.LoginThrottle,
+		groupRepo:           repos.Group,
+		groupMembershipRepo: repos.GroupMembership,
+		cfg:                 cfg,
+		aead:                aead,
+	}
+}
+
+// Status describes the user's two-factor authentication setup
+func (s *mfaService) Status(ctx context.Context, userID uuid.UUID) (*models.MFAStatus, error) {
+	ctx, span := tracing.Start(ctx, "MFAService.Status")
+	defer span.End()
+
+	enrolment, err := s.totpRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TOTP enrolment: %w", err)
+	}
+	if enrolment == nil || enrolment.ConfirmedAt == nil {
+		return &models.MFAStatus{}, nil
+	}
+
+	remaining, err := s.recoveryCodeRepo.CountUnused(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return &models.MFAStatus{
+		Enabled:                true,
+		EnabledAt:              enrolment.ConfirmedAt,
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+// BeginEnrollment generates a new TOTP secret for the user. It stays
+// pending, and login is unaffected, until ConfirmEnrollment.
+func (s *mfaService) BeginEnrollment(ctx context.Context, userID uuid.UUID) (*models.TOTPEnrollment, error) {
+	ctx, span := tracing.Start(ctx, "MFAService.BeginEnrollment")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	encrypted, err := s.encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.totpRepo.SavePending(ctx, userID, encrypted); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("two-factor authentication already enabled: %w", ErrConflict)
+		}
+		return nil, fmt.Errorf("failed to save TOTP secret: %w", err)
+	}
+
+	uri := totp.URI(s.cfg.Issuer, user.Email, secret)
+	code, err := qrcode.Encode([]byte(uri), qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	png, err := code.PNG(qrScale)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
+	}
+
+	return &models.TOTPEnrollment{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// ConfirmEnrollment enables two-factor authentication once the user proves
+// their authenticator works, and returns their first recovery codes
+func (s *mfaService) ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "MFAService.ConfirmEnrollment")
+	defer span.End()
+
+	enrolment, err := s.totpRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TOTP enrolment: %w", err)
+	}
+	if enrolment == nil {
+		return nil, fmt.Errorf("TOTP enrolment %w", ErrNotFound)
+	}
+	if enrolment.ConfirmedAt != nil {
+		return nil, fmt.Errorf("two-factor authentication already enabled: %w", ErrConflict)
+	}
+
+	secret, err := s.decrypt(enrolment.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, normalizeCode(code), time.Now(), totpSkew)
+	if !ok {
+		return nil, fmt.Errorf("incorrect code: %w", ErrInvalidInput)
+	}
+
+	var codes []string
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.totpRepo.Confirm(ctx, userID, step); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("two-factor authentication already enabled: %w", ErrConflict)
+			}
+			return fmt.Errorf("failed to confirm TOTP enrolment: %w", err)
+		}
+		codes, err = s.replaceRecoveryCodes(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable turns off two-factor authentication after checking a current code.
+// Admins of groups that require 2FA must step down first.
+func (s *mfaService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	ctx, span := tracing.Start(ctx, "MFAService.Disable")
+	defer span.End()
+
+	if err := s.verifyThrottled(ctx, userID, code); err != nil {
+		return err
+	}
+
+	required, err := s.adminOfRequiringGroup(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return fmt.Errorf("a group you administer requires two-factor authentication: %w", ErrConflict)
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.totpRepo.Delete(ctx, userID); err != nil {
+			return fmt.Errorf("failed to delete TOTP enrolment: %w", err)
+		}
+		if err := s.recoveryCodeRepo.DeleteAll(ctx, userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a current code
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "MFAService.RegenerateRecoveryCodes")
+	defer span.End()
+
+	if err := s.verifyThrottled(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		codes, err = s.replaceRecoveryCodes(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// BeginLogin issues a challenge for the second login step. It returns nil
+// when the user has not enabled two-factor authentication.
+func (s *mfaService) BeginLogin(ctx context.Context, userID uuid.UUID) (*models.MFAChallengeResponse, error) {
+	ctx, span := tracing.Start(ctx, "MFAService.BeginLogin")
+	defer span.End()
+
+	enabled, err := mfaEnabled(ctx, s.totpRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	challenge := &models.MFAChallenge{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: now.Add(s.cfg.ChallengeTTL),
+		CreatedAt: now,
+	}
+	if err := s.challengeRepo.Create(ctx, challenge); err != nil {
+		return nil, fmt.Errorf("failed to create MFA challenge: %w", err)
+	}
+
+	return &models.MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresAt:   challenge.ExpiresAt,
+	}, nil
+}
+
+// CompleteLogin redeems a login challenge with a TOTP or recovery code and
+// returns the signed-in user. A challenge is discarded after too many wrong
+// codes.
+func (s *mfaService) CompleteLogin(ctx context.Context, token, code string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "MFAService.CompleteLogin")
+	defer span.End()
+
+	tokenHash := hashToken(token)
+	challenge, err := s.challengeRepo.GetByHash(ctx, tokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MFA challenge: %w", err)
+	}
+	if challenge == nil || time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= s.cfg.MaxAttempts {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.verifyCode(ctx, challenge.UserID, code); err != nil {
+		if !errors.Is(err, ErrInvalidCredentials) {
+			return nil, err
+		}
+		attempts, incErr := s.challengeRepo.IncrementAttempts(ctx, tokenHash)
+		if incErr != nil {
+			return nil, fmt.Errorf("failed to record MFA attempt: %w", incErr)
+		}
+		if attempts >= s.cfg.MaxAttempts {
+			if err := s.challengeRepo.Delete(ctx, tokenHash); err != nil {
+				return nil, fmt.Errorf("failed to delete MFA challenge: %w", err)
+			}
+		}
+		return nil, err
+	}
+
+	if err := s.challengeRepo.Delete(ctx, tokenHash); err != nil {
+		return nil, fmt.Errorf("failed to delete MFA challenge: %w", err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+
+	return user, nil
+}
+
+// verifyThrottled verifies a code for a signed-in user. Wrong codes count
+// against the user's mfa throttle, which returns ErrTooManyRequests for the
+// lockout duration once MaxAttempts wrong codes follow each other.
+func (s *mfaService) verifyThrottled(ctx context.Context, userID uuid.UUID, code string) error {
+	subject := userID.String()
+	throttle, err := s.throttleRepo.Get(ctx, models.LoginThrottleMFA, subject)
+	if err != nil {
+		return fmt.Errorf("failed to get MFA throttle: %w", err)
+	}
+	if throttle != nil && throttle.LockedUntil != nil && time.Now().Before(*throttle.LockedUntil) {
+		wait := time.Until(*throttle.LockedUntil).Truncate(time.Minute) + time.Minute
+		return fmt.Errorf("too many incorrect codes, try again in %s: %w", formatDuration(wait), ErrTooManyRequests)
+	}
+
+	err = s.verifyCode(ctx, userID, code)
+	if errors.Is(err, ErrInvalidCredentials) {
+		now := time.Now()
+		throttle, recErr := s.throttleRepo.RecordFailure(ctx, models.LoginThrottleMFA, subject, now.Add(-s.cfg.LockoutDuration))
+		if recErr != nil {
+			return fmt.Errorf("failed to record MFA failure: %w", recErr)
+		}
+		if s.cfg.MaxAttempts > 0 && throttle.Failures >= s.cfg.MaxAttempts {
+			if err := s.throttleRepo.Lock(ctx, models.LoginThrottleMFA, subject, now.Add(s.cfg.LockoutDuration)); err != nil {
+				return fmt.Errorf("failed to lock MFA: %w", err)
+			}
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if throttle != nil {
+		if err := s.throttleRepo.Clear(ctx, models.LoginThrottleMFA, subject); err != nil {
+			return fmt.Errorf("failed to clear MFA throttle: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifyCode checks a six digit TOTP code, or otherwise a recovery code,
+// and consumes it. It returns ErrInvalidCredentials for a wrong or reused
+// code.
+func (s *mfaService) verifyCode(ctx context.Context, userID uuid.UUID, code string) error {
+	enrolment, err := s.totpRepo.Get(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get TOTP enrolment: %w", err)
+	}
+	if enrolment == nil || enrolment.ConfirmedAt == nil {
+		return fmt.Errorf("two-factor authentication not enabled: %w", ErrInvalidInput)
+	}
+
+	code = normalizeCode(code)
+	if len(code) == totp.Digits && isDigits(code) {
+		secret, err := s.decrypt(enrolment.Secret)
+		if err != nil {
+			return err
+		}
+		step, ok := totp.Validate(secret, code, time.Now(), totpSkew)
+		if !ok {
+			return ErrInvalidCredentials
+		}
+		if err := s.totpRepo.UseStep(ctx, userID, step); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidCredentials
+			}
+			return fmt.Errorf("failed to record TOTP use: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.recoveryCodeRepo.Use(ctx, userID, hashToken(code)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCredentials
+		}
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return nil
+}
+
+// replaceRecoveryCodes generates and stores a new set of recovery codes and
+// returns them formatted for display
+func (s *mfaService) replaceRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashToken(code)
+	}
+
+	if err := s.recoveryCodeRepo.ReplaceAll(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// adminOfRequiringGroup reports whether the user is an admin of a group that
+// requires two-factor authentication for its admins
+func (s *mfaService) adminOfRequiringGroup(ctx context.Context, userID uuid.UUID) (bool, error) {
+	memberships, err := s.groupMembershipRepo.GetUserMemberships(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get group memberships: %w", err)
+	}
+	for _, membership := range memberships {
+		if membership.Role != "admin" {
+			continue
+		}
+		group, err := s.groupRepo.GetByID(ctx, membership.GroupID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get group: %w", err)
+		}
+		if group != nil && group.RequireAdmin2FA {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// encrypt seals a TOTP secret for storage
+func (s *mfaService) encrypt(secret string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a stored TOTP secret
+func (s *mfaService) decrypt(stored string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(stored)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return "", fmt.Errorf("failed to decode TOTP secret")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	secret, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// mfaEnabled reports whether the user has confirmed a TOTP enrolment
+func mfaEnabled(ctx context.Context, totpRepo repository.UserTOTPRepository, userID uuid.UUID) (bool, error) {
+	enrolment, err := totpRepo.Get(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get TOTP enrolment: %w", err)
+	}
+	return enrolment != nil && enrolment.ConfirmedAt != nil, nil
+}
+
+// newRecoveryCode returns eight random characters from recoveryCodeAlphabet
+func newRecoveryCode() (string, error) {
+	code := make([]byte, 8)
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = recoveryCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// normalizeCode strips the spaces and dashes users type or paste, so
+// "123 456" and "ABCD-EFGH" are accepted
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+// isDigits reports whether s consists only of ASCII digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
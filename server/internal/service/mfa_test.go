@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/config"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestRegenerateRecoveryCodesLockout(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	confirmed := time.Now()
+
+	throttles := newFakeThrottleRepo()
+	recoveryCodes := &fakeRecoveryCodeRepo{hashes: make(map[uuid.UUID]map[string]bool)}
+	s := &mfaService{
+		tx:               fakeTx{},
+		totpRepo:         &fakeTOTPRepo{enrolments: map[uuid.UUID]*models.UserTOTP{userID: {UserID: userID, ConfirmedAt: &confirmed}}},
+		recoveryCodeRepo: recoveryCodes,
+		throttleRepo:     throttles,
+		cfg:              config.MFAConfig{MaxAttempts: 3, LockoutDuration: 15 * time.Minute},
+	}
+	codes, err := s.replaceRecoveryCodes(ctx, userID)
+	if err != nil {
+		t.Fatalf("replaceRecoveryCodes: %v", err)
+	}
+
+	wrong := func() {
+		t.Helper()
+		if _, err := s.RegenerateRecoveryCodes(ctx, userID, "wrong-code"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("RegenerateRecoveryCodes(wrong code) error = %v, want ErrInvalidCredentials", err)
+		}
+	}
+
+	// A correct code resets the count of wrong ones
+	wrong()
+	wrong()
+	codes, err = s.RegenerateRecoveryCodes(ctx, userID, codes[0])
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes(correct code): %v", err)
+	}
+	wrong()
+	wrong()
+
+	// The third wrong code in a row locks out even a correct one
+	wrong()
+	if _, err := s.RegenerateRecoveryCodes(ctx, userID, codes[0]); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("RegenerateRecoveryCodes(while locked) error = %v, want ErrTooManyRequests", err)
+	}
+}
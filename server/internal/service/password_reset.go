@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/mailer"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+)
+
+// passwordResetTimeout bounds the background work of a reset request
+const passwordResetTimeout = 30 * time.Second
+
+// RequestPasswordReset emails a reset link if the address belongs to an
+// active account. It never reports whether it does: the lookup and the email
+// happen in the background so neither the result nor the response time
+// depends on it.
+func (s *userService) RequestPasswordReset(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "UserService.RequestPasswordReset")
+	defer span.End()
+
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return fmt.Errorf("email is required: %w", ErrInvalidInput)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), passwordResetTimeout)
+		defer cancel()
+
+		if err := s.sendPasswordReset(ctx, email); err != nil {
+			log.Printf("Failed to send password reset email: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// ResetPassword redeems a reset token and sets a new password. Outstanding
+// reset links are invalidated and every session is revoked, signing the user
+// out on all devices, and any sign-in lockout on the account is lifted.
+func (s *userService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ResetPassword")
+	defer span.End()
+
+	stored, err := s.userTokenRepo.GetByHash(ctx, models.TokenPurposePasswordReset, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to get reset token: %w", err)
+	}
+	if stored == nil || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrInvalidToken
+	}
+	if err := s.checkPasswordPolicy(newPassword, user.Username); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userTokenRepo.MarkUsed(ctx, stored.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("failed to use reset token: %w", err)
+		}
+		if err := s.userTokenRepo.InvalidateAll(ctx, stored.UserID, models.TokenPurposePasswordReset); err != nil {
+			return fmt.Errorf("failed to invalidate reset tokens: %w", err)
+		}
+
+		now := time.Now()
+
+		user.PasswordHash = hashedPassword
+		user.TokensValidAfter = &now
+		// Receiving the reset link proves the user owns the address
+		user.EmailVerified = true
+		user.UpdatedAt = now
+
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		if err := s.sessionRepo.RevokeAll(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		if err := s.throttleRepo.Clear(ctx, models.LoginThrottleAccount, loginSubject(user.Email)); err != nil {
+			return fmt.Errorf("failed to clear lockout: %w", err)
+		}
+		return nil
+	})
+}
+
+// sendPasswordReset issues a reset token and mails the link, unless the
+// address is unknown or a link was sent within the resend interval
+func (s *userService) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil
+	}
+
+	latest, err := s.userTokenRepo.GetLatest(ctx, user.ID, models.TokenPurposePasswordReset)
+	if err != nil {
+		return fmt.Errorf("failed to get latest reset token: %w", err)
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < s.account.PasswordResetResendInterval {
+		return nil
+	}
+
+	token, err := s.issueToken(ctx, user.ID, models.TokenPurposePasswordReset, s.account.PasswordResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.account.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your IQ Theory password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password for your IQ Theory account. To choose a new password, open the link below:\n\n%s\n\n"+
+			"The link expires in %s and can be used once. Resetting your password signs you out on every device.\n"+
+			"If you did not ask for this, you can ignore this email and your password will stay the same.\n",
+			user.DisplayName, link, formatDuration(s.account.PasswordResetTokenTTL)),
+	})
+}
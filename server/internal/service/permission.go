@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+// rolePermissions lists what each site role may do
+var rolePermissions = map[string][]models.Permission{
+	models.SiteRoleStudent: nil,
+	models.SiteRoleTeacher: {
+		models.PermissionCreateGroup,
+	},
+	models.SiteRoleAdmin: {
+		models.PermissionCreateGroup,
+		models.PermissionRefreshLeaderboards,
+		models.PermissionModerate,
+		models.PermissionManageUsers,
+		models.PermissionManageFeatureFlags,
+	},
+}
+
+// siteRoles resolves users' effective site roles. Users listed in the
+// configuration are always admins, so a new deployment has someone who can
+// grant roles.
+type siteRoles struct {
+	userRepo repository.UserRepository
+	adminIDs []uuid.UUID
+}
+
+// roleOf returns the user's effective site role
+func (r *siteRoles) roleOf(ctx context.Context, userID uuid.UUID) (string, error) {
+	if slices.Contains(r.adminIDs, userID) {
+		return models.SiteRoleAdmin, nil
+	}
+
+	user, err := r.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return "", fmt.Errorf("user %w", ErrNotFound)
+	}
+	return user.SiteRole, nil
+}
+
+// permissionService implements the PermissionService interface
+type permissionService struct {
+	roles    *siteRoles
+	userRepo repository.UserRepository
+}
+
+// NewPermissionService creates a new permission service instance. adminIDs
+// are users who are admins regardless of their stored role.
+func NewPermissionService(repos *repository.Repositories, adminIDs []uuid.UUID) PermissionService {
+	return &permissionService{
+		roles:    &siteRoles{userRepo: repos.User, adminIDs: adminIDs},
+		userRepo: repos.User,
+	}
+}
+
+// GetPermissions returns the user's site role and the permissions it grants
+func (s *permissionService) GetPermissions(ctx context.Context, userID uuid.UUID) (*models.UserPermissions, error) {
+	ctx, span := tracing.Start(ctx, "PermissionService.GetPermissions")
+	defer span.End()
+
+	role, err := s.roles.roleOf(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := rolePermissions[role]
+	if permissions == nil {
+		permissions = []models.Permission{}
+	}
+	return &models.UserPermissions{Role: role, Permissions: permissions}, nil
+}
+
+// HasPermission reports whether the user's site role grants a permission
+func (s *permissionService) HasPermission(ctx context.Context, userID uuid.UUID, permission models.Permission) (bool, error) {
+	ctx, span := tracing.Start(ctx, "PermissionService.HasPermission")
+	defer span.End()
+
+	role, err := s.roles.roleOf(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(rolePermissions[role], permission), nil
+}
+
+// RequirePermission returns ErrForbidden unless the user has the permission
+func (s *permissionService) RequirePermission(ctx context.Context, userID uuid.UUID, permission models.Permission) error {
+	allowed, err := s.HasPermission(ctx, userID, permission)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("%s permission required: %w", permission, ErrForbidden)
+	}
+	return nil
+}
+
+// SetSiteRole grants a user a site role. Admins cannot change their own
+// role, so the last admin cannot lock everyone out by accident.
+func (s *permissionService) SetSiteRole(ctx context.Context, actorID, userID uuid.UUID, role string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "PermissionService.SetSiteRole")
+	defer span.End()
+
+	if _, ok := rolePermissions[role]; !ok {
+		return nil, fmt.Errorf("role must be student, teacher or admin: %w", ErrInvalidInput)
+	}
+	if actorID == userID {
+		return nil, fmt.Errorf("you cannot change your own role: %w", ErrForbidden)
+	}
+	if err := s.RequirePermission(ctx, actorID, models.PermissionManageUsers); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.SetSiteRole(ctx, userID, role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to set role: %w", err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+	return user, nil
+}
+
+// ListUsersWithRole lists the users holding a site role. Students are not
+// listed, since that is nearly everyone.
+func (s *permissionService) ListUsersWithRole(ctx context.Context, role string) ([]*models.User, error) {
+	ctx, span := tracing.Start(ctx, "PermissionService.ListUsersWithRole")
+	defer span.End()
+
+	if role != models.SiteRoleTeacher && role != models.SiteRoleAdmin {
+		return nil, fmt.Errorf("role must be teacher or admin: %w", ErrInvalidInput)
+	}
+
+	users, err := s.userRepo.ListBySiteRole(ctx, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
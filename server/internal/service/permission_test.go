@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestSetSiteRole(t *testing.T) {
+	configuredAdmin := uuid.New()
+
+	tests := []struct {
+		name     string
+		actor    string
+		target   string
+		role     string
+		wantErr  error
+		wantRole string
+	}{
+		{name: "admin promotes a student", actor: "admin", target: "student", role: models.SiteRoleTeacher, wantRole: models.SiteRoleTeacher},
+		{name: "configured admin promotes a student", actor: "configured", target: "student", role: models.SiteRoleAdmin, wantRole: models.SiteRoleAdmin},
+		{name: "admin demotes another admin", actor: "admin", target: "other admin", role: models.SiteRoleStudent, wantRole: models.SiteRoleStudent},
+		{name: "admin cannot demote themselves", actor: "admin", target: "admin", role: models.SiteRoleStudent, wantErr: ErrForbidden, wantRole: models.SiteRoleAdmin},
+		{name: "admin cannot re-grant their own role", actor: "admin", target: "admin", role: models.SiteRoleAdmin, wantErr: ErrForbidden, wantRole: models.SiteRoleAdmin},
+		{name: "teacher cannot promote themselves", actor: "teacher", target: "teacher", role: models.SiteRoleAdmin, wantErr: ErrForbidden, wantRole: models.SiteRoleTeacher},
+		{name: "teacher cannot promote others", actor: "teacher", target: "student", role: models.SiteRoleTeacher, wantErr: ErrForbidden, wantRole: models.SiteRoleStudent},
+		{name: "unknown role", actor: "admin", target: "student", role: "owner", wantErr: ErrInvalidInput, wantRole: models.SiteRoleStudent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := map[string]*models.User{
+				"admin":       {ID: uuid.New(), SiteRole: models.SiteRoleAdmin},
+				"other admin": {ID: uuid.New(), SiteRole: models.SiteRoleAdmin},
+				"teacher":     {ID: uuid.New(), SiteRole: models.SiteRoleTeacher},
+				"student":     {ID: uuid.New(), SiteRole: models.SiteRoleStudent},
+				"configured":  {ID: configuredAdmin, SiteRole: models.SiteRoleStudent},
+			}
+			repo := newFakeUserRepo(users["admin"], users["other admin"], users["teacher"], users["student"], users["configured"])
+			s := &permissionService{
+				roles:    &siteRoles{userRepo: repo, adminIDs: []uuid.UUID{configuredAdmin}},
+				userRepo: repo,
+			}
+
+			target := users[tt.target].ID
+			_, err := s.SetSiteRole(context.Background(), users[tt.actor].ID, target, tt.role)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("SetSiteRole() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("SetSiteRole() error = %v", err)
+			}
+			if got := repo.users[target].SiteRole; got != tt.wantRole {
+				t.Errorf("stored role = %s, want %s", got, tt.wantRole)
+			}
+		})
+	}
+}
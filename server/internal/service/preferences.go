@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+	_ "time/tzdata" // validate time zones on hosts without zoneinfo
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+var (
+	noteNamings  = []string{models.NoteNamingEnglish, models.NoteNamingGerman, models.NoteNamingSolfege}
+	answerInputs = []string{models.AnswerInputButtons, models.AnswerInputPiano, models.AnswerInputKeyboard}
+)
+
+// preferencesService implements the PreferencesService interface
+type preferencesService struct {
+	prefsRepo repository.UserPreferencesRepository
+	quizRepo  repository.QuizRepository
+	userRepo  repository.UserRepository
+}
+
+// NewPreferencesService creates a new preferences service instance
+func NewPreferencesService(repos *repository.Repositories) PreferencesService {
+	return &preferencesService{
+		prefsRepo: repos.UserPreferences,
+		quizRepo:  repos.Quiz,
+		userRepo:  repos.User,
+	}
+}
+
+// GetPreferences returns the user's preferences, with defaults for anything
+// they never saved
+func (s *preferencesService) GetPreferences(ctx context.Context, userID uuid.UUID) (*models.UserPreferences, error) {
+	ctx, span := tracing.Start(ctx, "PreferencesService.GetPreferences")
+	defer span.End()
+
+	return loadPreferences(ctx, s.prefsRepo, userID)
+}
+
+// UpdatePreferences validates and saves the full set of preferences. Quiz
+// defaults must be active quiz options; nil clears them.
+func (s *preferencesService) UpdatePreferences(ctx context.Context, userID uuid.UUID, prefs *models.UserPreferences) (*models.UserPreferences, error) {
+	ctx, span := tracing.Start(ctx, "PreferencesService.UpdatePreferences")
+	defer span.End()
+
+	if !slices.Contains(noteNamings, prefs.NoteNaming) {
+		return nil, fmt.Errorf("note_naming must be english, german or solfege: %w", ErrInvalidInput)
+	}
+	if !slices.Contains(answerInputs, prefs.AnswerInput) {
+		return nil, fmt.Errorf("answer_input must be buttons, piano or keyboard: %w", ErrInvalidInput)
+	}
+	// LoadLocation accepts "" and "Local", which mean nothing to clients
+	if _, err := time.LoadLocation(prefs.Timezone); err != nil || prefs.Timezone == "" || prefs.Timezone == "Local" {
+		return nil, fmt.Errorf("timezone must be an IANA time zone such as Europe/London: %w", ErrInvalidInput)
+	}
+	if err := checkQuizSettings(ctx, s.quizRepo, prefs.DefaultClef, prefs.DefaultDurationSeconds, prefs.DefaultMaxLedgerLines); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	prefs.UserID = userID
+	prefs.UpdatedAt = time.Now()
+	if err := s.prefsRepo.Upsert(ctx, prefs); err != nil {
+		return nil, fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// loadPreferences returns the user's stored preferences or the defaults
+func loadPreferences(ctx context.Context, prefsRepo repository.UserPreferencesRepository, userID uuid.UUID) (*models.UserPreferences, error) {
+	prefs, err := prefsRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	if prefs == nil {
+		prefs = &models.UserPreferences{
+			UserID:        userID,
+			NoteNaming:    models.NoteNamingEnglish,
+			AnswerInput:   models.AnswerInputButtons,
+			SoundEnabled:  true,
+			Timezone:      "UTC",
+			ShareActivity: true,
+			Discoverable:  true,
+		}
+	}
+	return prefs, nil
+}
+
+// checkQuizSettings returns ErrInvalidInput if a given clef, duration or
+// ledger line limit is not an active quiz option. Nil values are skipped.
+func checkQuizSettings(ctx context.Context, quizRepo repository.QuizRepository, clef *string, duration, maxLedgerLines *int) error {
+	if clef != nil {
+		clefs, err := quizRepo.GetClefTypes(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get clefs: %w", err)
+		}
+		if !slices.ContainsFunc(clefs, func(c *models.ClefType) bool { return c.Name == *clef }) {
+			return fmt.Errorf("unknown clef %q: %w", *clef, ErrInvalidInput)
+		}
+	}
+	if duration != nil {
+		durations, err := quizRepo.GetDurationOptions(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get durations: %w", err)
+		}
+		if !slices.ContainsFunc(durations, func(d *models.DurationOption) bool { return d.DurationSeconds == *duration }) {
+			return fmt.Errorf("unsupported duration %d: %w", *duration, ErrInvalidInput)
+		}
+	}
+	if maxLedgerLines != nil {
+		ledgers, err := quizRepo.GetLedgerLineOptions(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get ledger line options: %w", err)
+		}
+		if !slices.ContainsFunc(ledgers, func(l *models.LedgerLineOption) bool { return l.MaxLines == *maxLedgerLines }) {
+			return fmt.Errorf("unsupported ledger line limit %d: %w", *maxLedgerLines, ErrInvalidInput)
+		}
+	}
+	return nil
+}
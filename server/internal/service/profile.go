@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	// minSearchLength is the shortest query searched; shorter ones match
+	// almost everyone
+	minSearchLength = 2
+	// defaultSearchLimit and maxSearchLimit bound a page of search results
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	// maxStreakDays bounds how far back a streak is counted
+	maxStreakDays = 3650
+)
+
+// SearchUsers finds users by username or display name, case-insensitively
+// and tolerating typos. Results never include email addresses.
+func (s *userService) SearchUsers(ctx context.Context, query string, limit, offset int) (*models.UserSearchResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserService.SearchUsers")
+	defer span.End()
+
+	query = strings.TrimSpace(query)
+	if len([]rune(query)) < minSearchLength {
+		return nil, fmt.Errorf("search query must be at least %d characters: %w", minSearchLength, ErrInvalidInput)
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+	offset = max(offset, 0)
+
+	// Fetch one extra row to tell whether there is another page
+	users, err := s.userRepo.Search(ctx, query, limit+1, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	response := &models.UserSearchResponse{
+		Users:  make([]*models.UserSummary, 0, len(users)),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if len(users) > limit {
+		users = users[:limit]
+		response.HasMore = true
+	}
+	for _, user := range users {
+		response.Users = append(response.Users, userSummary(user))
+	}
+
+	return response, nil
+}
+
+// GetPublicProfile returns what other users can see of an account: no email
+// address, plus aggregates of the user's completed quizzes. Guests have no
+// public profile.
+func (s *userService) GetPublicProfile(ctx context.Context, userID uuid.UUID) (*models.PublicProfile, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetPublicProfile")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.IsGuest {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	total, err := s.quizSessionRepo.CountCompleted(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count quizzes: %w", err)
+	}
+	bestScores, err := s.quizSessionRepo.GetBestScoresByClef(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get best scores: %w", err)
+	}
+	if bestScores == nil {
+		bestScores = []*models.ClefBestScore{}
+	}
+	days, err := s.quizSessionRepo.GetActiveDays(ctx, userID, maxStreakDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active days: %w", err)
+	}
+
+	return &models.PublicProfile{
+		UserSummary: *userSummary(user),
+		JoinedAt:    user.CreatedAt,
+		Stats: &models.ProfileStats{
+			TotalQuizzes:  total,
+			BestScores:    bestScores,
+			CurrentStreak: currentStreak(days, time.Now()),
+		},
+	}, nil
+}
+
+// userSummary returns the public fields of a user
+func userSummary(user *models.User) *models.UserSummary {
+	return &models.UserSummary{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+	}
+}
+
+// currentStreak counts consecutive days in days (UTC dates, newest first)
+// ending today or yesterday. A streak survives until a whole day is missed.
+func currentStreak(days []time.Time, now time.Time) int {
+	expected := now.UTC().Truncate(24 * time.Hour)
+	if len(days) > 0 && days[0].Before(expected) {
+		// Nothing yet today; the streak may still run up to yesterday
+		expected = expected.AddDate(0, 0, -1)
+	}
+
+	streak := 0
+	for _, day := range days {
+		if !sameDate(day, expected) {
+			break
+		}
+		streak++
+		expected = expected.AddDate(0, 0, -1)
+	}
+	return streak
+}
+
+func sameDate(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
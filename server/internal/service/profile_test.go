@@ -0,0 +1,43 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+// day returns midnight UTC on the given date in October 2026
+func day(d int) time.Time {
+	return time.Date(2026, time.October, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCurrentStreak(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		days []time.Time
+		now  time.Time
+		want int
+	}{
+		{"no activity", nil, now, 0},
+		{"today only", []time.Time{day(18)}, now, 1},
+		{"yesterday only", []time.Time{day(17)}, now, 1},
+		{"two days ago", []time.Time{day(16)}, now, 0},
+		{"ending today", []time.Time{day(18), day(17), day(16)}, now, 3},
+		{"ending yesterday", []time.Time{day(17), day(16), day(15)}, now, 3},
+		{"gap ends the streak", []time.Time{day(18), day(17), day(15), day(14)}, now, 2},
+		{"across a month boundary", []time.Time{day(2), day(1), time.Date(2026, time.September, 30, 0, 0, 0, 0, time.UTC)},
+			time.Date(2026, time.October, 2, 9, 0, 0, 0, time.UTC), 3},
+		{"now in another time zone", []time.Time{day(18), day(17)},
+			time.Date(2026, time.October, 19, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), 2},
+		{"just after midnight UTC", []time.Time{day(18), day(17)},
+			time.Date(2026, time.October, 19, 0, 0, 1, 0, time.UTC), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := currentStreak(tt.days, tt.now); got != tt.want {
+				t.Errorf("currentStreak() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
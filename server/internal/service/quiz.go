@@ -1,30 +1,44 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
+	"github.com/andy-dam/iq-theory/server/internal/events"
 	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
 	"github.com/google/uuid"
 )
 
 // quizService implements the QuizServiceInterface
 type quizService struct {
+	tx              repository.Transactor
+	events          events.Publisher
 	quizRepo        repository.QuizRepository
 	sessionRepo     repository.QuizSessionRepository
 	answerRepo      repository.QuizAnswerRepository
 	leaderboardRepo repository.LeaderboardRepository
 	userRepo        repository.UserRepository
+	prefsRepo       repository.UserPreferencesRepository
 }
 
 // NewQuizService creates a new quiz service instance
 func NewQuizService(repos *repository.Repositories) QuizService {
 	return &quizService{
+		tx:              repos.Tx,
+		events:          events.NewPublisher(repos.Outbox),
 		quizRepo:        repos.Quiz,
 		sessionRepo:     repos.QuizSession,
 		answerRepo:      repos.QuizAnswer,
 		leaderboardRepo: repos.Leaderboard,
 		userRepo:        repos.User,
+		prefsRepo:       repos.UserPreferences,
 	}
 }
 
@@ -52,16 +66,96 @@ func (s *quizService) GetLedgerLineOptions(ctx context.Context) ([]*models.Ledge
 	return nil, nil
 }
 
-// CreateQuizSession creates a new quiz session
-func (s *quizService) CreateQuizSession(ctx context.Context, userID uuid.UUID, clef string, duration int, maxLedgerLines int) (*models.QuizSession, error) {
-	// TODO: Implement quiz session creation logic
-	return nil, nil
+// CreateQuizSession creates a new quiz session. Parameters missing from the
+// request come from the user's preferences, then from the first active option.
+func (s *quizService) CreateQuizSession(ctx context.Context, userID uuid.UUID, req *models.StartQuizRequest) (*models.QuizSession, error) {
+	ctx, span := tracing.Start(ctx, "QuizService.CreateQuizSession")
+	defer span.End()
+
+	prefs, err := loadPreferences(ctx, s.prefsRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Requested values must be active options. Preferences may point at an
+	// option that has since been retired, so those fall back to the first
+	// active option instead.
+	if err := checkQuizSettings(ctx, s.quizRepo, req.Clef, req.DurationSeconds, req.MaxLedgerLines); err != nil {
+		return nil, err
+	}
+
+	clefs, err := s.quizRepo.GetClefTypes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clefs: %w", err)
+	}
+	clef, ok := pickOption(cmp.Or(req.Clef, prefs.DefaultClef), clefs, func(c *models.ClefType) string { return c.Name })
+	if !ok {
+		return nil, errors.New("no active clefs configured")
+	}
+
+	durations, err := s.quizRepo.GetDurationOptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get durations: %w", err)
+	}
+	duration, ok := pickOption(cmp.Or(req.DurationSeconds, prefs.DefaultDurationSeconds), durations, func(d *models.DurationOption) int { return d.DurationSeconds })
+	if !ok {
+		return nil, errors.New("no active durations configured")
+	}
+
+	ledgers, err := s.quizRepo.GetLedgerLineOptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger line options: %w", err)
+	}
+	maxLedgerLines, ok := pickOption(cmp.Or(req.MaxLedgerLines, prefs.DefaultMaxLedgerLines), ledgers, func(l *models.LedgerLineOption) int { return l.MaxLines })
+	if !ok {
+		return nil, errors.New("no active ledger line options configured")
+	}
+
+	session := &models.QuizSession{
+		ID:              uuid.New(),
+		UserID:          userID,
+		Clef:            clef,
+		DurationSeconds: duration,
+		MaxLedgerLines:  maxLedgerLines,
+		StartedAt:       time.Now(),
+		Status:          "in_progress",
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create quiz session: %w", err)
+	}
+	return session, nil
+}
+
+// pickOption returns want if it is one of the active options, otherwise the
+// first option. It reports false when there are no options.
+func pickOption[T any, V comparable](want *V, options []T, value func(T) V) (V, bool) {
+	if len(options) == 0 {
+		var zero V
+		return zero, false
+	}
+	if want != nil {
+		for _, option := range options {
+			if value(option) == *want {
+				return *want, true
+			}
+		}
+	}
+	return value(options[0]), true
 }
 
 // GetQuizSession retrieves a quiz session by ID
 func (s *quizService) GetQuizSession(ctx context.Context, sessionID uuid.UUID) (*models.QuizSession, error) {
-	// TODO: Implement
-	return nil, nil
+	ctx, span := tracing.Start(ctx, "QuizService.GetQuizSession")
+	defer span.End()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiz session: %w", err)
+	}
+	if session == nil {
+		return nil, fmt.Errorf("quiz session not found")
+	}
+	return session, nil
 }
 
 // GetUserQuizSessions retrieves quiz sessions for a user
@@ -76,10 +170,59 @@ func (s *quizService) StartQuizSession(ctx context.Context, sessionID uuid.UUID)
 	return nil
 }
 
-// CompleteQuizSession completes a quiz session
-func (s *quizService) CompleteQuizSession(ctx context.Context, sessionID uuid.UUID) (*models.QuizSession, error) {
-	// TODO: Implement session completion logic
-	return nil, nil
+// CompleteQuizSession completes one of the user's quiz sessions and
+// publishes QuizCompleted
+func (s *quizService) CompleteQuizSession(ctx context.Context, userID, sessionID uuid.UUID) (*models.QuizSession, error) {
+	ctx, span := tracing.Start(ctx, "QuizService.CompleteQuizSession")
+	defer span.End()
+
+	var completed *models.QuizSession
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		session, err := s.sessionRepo.GetByID(ctx, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to get quiz session: %w", err)
+		}
+		if session == nil || session.UserID != userID {
+			return fmt.Errorf("quiz session %w", ErrNotFound)
+		}
+		if session.Status != "in_progress" {
+			return fmt.Errorf("quiz session is not in progress: %w", ErrConflict)
+		}
+
+		// Sessions are timed, so never record more than the configured duration
+		timeTaken := min(int(time.Since(session.StartedAt).Seconds()), session.DurationSeconds)
+
+		if err := s.sessionRepo.Complete(ctx, sessionID, session.Score, timeTaken); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("quiz session is not in progress: %w", ErrConflict)
+			}
+			return fmt.Errorf("failed to complete quiz session: %w", err)
+		}
+
+		completed, err = s.sessionRepo.GetByID(ctx, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to reload quiz session: %w", err)
+		}
+
+		return s.events.Publish(ctx, events.QuizCompleted{
+			SessionID:          completed.ID,
+			UserID:             completed.UserID,
+			Clef:               completed.Clef,
+			DurationSeconds:    completed.DurationSeconds,
+			MaxLedgerLines:     completed.MaxLedgerLines,
+			Score:              completed.Score,
+			CorrectAnswers:     completed.CorrectAnswers,
+			TotalQuestions:     completed.TotalQuestions,
+			AccuracyPercentage: completed.AccuracyPercentage,
+			TimeTakenSeconds:   timeTaken,
+			CompletedAt:        *completed.CompletedAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return completed, nil
 }
 
 // AbandonQuizSession abandons a quiz session
@@ -119,18 +262,30 @@ func (s *quizService) GetSessionResults(ctx context.Context, sessionID uuid.UUID
 	return nil, nil
 }
 
+// defaultLeaderboardLimit and maxLeaderboardLimit bound a page of a
+// leaderboard
+const (
+	defaultLeaderboardLimit = 50
+	maxLeaderboardLimit     = 100
+)
+
 // leaderboardService implements the LeaderboardServiceInterface
 type leaderboardService struct {
 	leaderboardRepo repository.LeaderboardRepository
 	groupRepo       repository.GroupRepository
+	// stale is set when quiz results change and cleared by RefreshIfStale
+	stale atomic.Bool
 }
 
 // NewLeaderboardService creates a new leaderboard service instance
 func NewLeaderboardService(repos *repository.Repositories) LeaderboardService {
-	return &leaderboardService{
+	s := &leaderboardService{
 		leaderboardRepo: repos.Leaderboard,
 		groupRepo:       repos.Group,
 	}
+	// Results delivered just before a restart may not have been refreshed
+	s.stale.Store(true)
+	return s
 }
 
 // GetGlobalLeaderboard retrieves the global leaderboard
@@ -151,8 +306,56 @@ func (s *leaderboardService) GetGroupLeaderboard(ctx context.Context, groupID uu
 	return nil, nil
 }
 
+// GetFriendsLeaderboard retrieves the leaderboard for a user and their
+// accepted friends, ranked within that set
+func (s *leaderboardService) GetFriendsLeaderboard(ctx context.Context, userID uuid.UUID, clef string, duration int, maxLedgerLines int, limit, offset int) ([]*models.LeaderboardEntry, error) {
+	ctx, span := tracing.Start(ctx, "LeaderboardService.GetFriendsLeaderboard")
+	defer span.End()
+
+	if clef == "" || duration <= 0 || maxLedgerLines < 0 {
+		return nil, fmt.Errorf("clef, duration and max ledger lines are required: %w", ErrInvalidInput)
+	}
+	if limit <= 0 {
+		limit = defaultLeaderboardLimit
+	}
+	limit = min(limit, maxLeaderboardLimit)
+	offset = max(offset, 0)
+
+	entries, err := s.leaderboardRepo.GetFriendsLeaderboard(ctx, userID, clef, duration, maxLedgerLines, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get friends leaderboard: %w", err)
+	}
+	if entries == nil {
+		entries = []*models.LeaderboardEntry{}
+	}
+	return entries, nil
+}
+
 // RefreshLeaderboards refreshes the leaderboard materialized views
 func (s *leaderboardService) RefreshLeaderboards(ctx context.Context) error {
-	// TODO: Implement leaderboard refresh logic
+	ctx, span := tracing.Start(ctx, "LeaderboardService.RefreshLeaderboards")
+	defer span.End()
+
+	if err := s.leaderboardRepo.RefreshLeaderboard(ctx); err != nil {
+		return fmt.Errorf("failed to refresh leaderboards: %w", err)
+	}
 	return nil
 }
+
+// MarkStale records that quiz results changed since the last refresh
+func (s *leaderboardService) MarkStale() {
+	s.stale.Store(true)
+}
+
+// RefreshIfStale refreshes the leaderboards if they were marked stale since
+// the last call, reporting whether it did. A failed refresh stays pending.
+func (s *leaderboardService) RefreshIfStale(ctx context.Context) (bool, error) {
+	if !s.stale.Swap(false) {
+		return false, nil
+	}
+	if err := s.RefreshLeaderboards(ctx); err != nil {
+		s.stale.Store(true)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// maxRosterRows bounds how many students one import can create
+	maxRosterRows = 500
+	// maxRosterErrors is how many bad lines an import reports at once
+	maxRosterErrors = 10
+	// studentPasswordLength and studentLoginCodeLength are the lengths of
+	// generated credentials, before the login code's separator
+	studentPasswordLength  = 10
+	studentLoginCodeLength = 10
+	// Generated credentials leave out characters that are easily misread
+	// on a printout, such as 0/O and 1/l/I
+	studentPasswordAlphabet  = "abcdefghjkmnpqrstuvwxyz23456789"
+	studentLoginCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+)
+
+// studentUsernamePattern matches the usernames a roster can assign
+var studentUsernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{2,49}$`)
+
+// rosterEntry is one student read from a roster
+type rosterEntry struct {
+	line        int
+	displayName string
+	username    string
+	email       *string
+}
+
+// ImportRoster creates a managed student account for each row of a CSV
+// roster (name, username, optional email) and adds them all to the group.
+// Every account gets a generated password or login code, returned once for
+// printing. The whole roster is rejected if any row is invalid.
+func (s *groupService) ImportRoster(ctx context.Context, adminID, groupID uuid.UUID, roster io.Reader, credential string) ([]*models.StudentCredential, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.ImportRoster")
+	defer span.End()
+
+	if credential == "" {
+		credential = models.StudentCredentialPassword
+	}
+	if credential != models.StudentCredentialPassword && credential != models.StudentCredentialLoginCode {
+		return nil, fmt.Errorf("credential must be password or login_code: %w", ErrInvalidInput)
+	}
+
+	if _, err := s.requireGroupAdmin(ctx, adminID, groupID); err != nil {
+		return nil, err
+	}
+
+	entries, err := parseRoster(roster)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRosterAccounts(ctx, entries); err != nil {
+		return nil, err
+	}
+
+	var credentials []*models.StudentCredential
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// Locked so joins cannot fill the room counted below
+		group, err := s.groupRepo.LockByID(ctx, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to get group: %w", err)
+		}
+		if group == nil {
+			return fmt.Errorf("group %w", ErrNotFound)
+		}
+
+		members, err := s.groupMembershipRepo.GetGroupMembers(ctx, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to get group members: %w", err)
+		}
+		if room := group.MaxMembers - len(members); len(entries) > room {
+			return fmt.Errorf("group has room for %d more members, roster has %d: %w", max(room, 0), len(entries), ErrInvalidInput)
+		}
+
+		for _, entry := range entries {
+			cred, err := s.createStudent(ctx, groupID, entry, credential)
+			if err != nil {
+				return err
+			}
+			credentials = append(credentials, cred)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// ResetStudentCredential replaces the password or login code of a student
+// managed by the group, signing them out everywhere. Students without an
+// email address cannot reset their own password, so this is how a teacher
+// gets them back in.
+func (s *groupService) ResetStudentCredential(ctx context.Context, adminID, groupID, studentID uuid.UUID, credential string) (*models.StudentCredential, error) {
+	ctx, span := tracing.Start(ctx, "GroupService.ResetStudentCredential")
+	defer span.End()
+
+	if credential == "" {
+		credential = models.StudentCredentialPassword
+	}
+	if credential != models.StudentCredentialPassword && credential != models.StudentCredentialLoginCode {
+		return nil, fmt.Errorf("credential must be password or login_code: %w", ErrInvalidInput)
+	}
+
+	if _, err := s.requireGroupAdmin(ctx, adminID, groupID); err != nil {
+		return nil, err
+	}
+
+	student, err := s.userRepo.GetByID(ctx, studentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	// Only the managing group can reset a student; to anyone else the
+	// account does not exist
+	if student == nil || student.ManagedByGroupID == nil || *student.ManagedByGroupID != groupID {
+		return nil, fmt.Errorf("student %w", ErrNotFound)
+	}
+
+	var cred *models.StudentCredential
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		now := time.Now()
+		student.TokensValidAfter = &now
+		student.UpdatedAt = now
+
+		cred, err = issueStudentCredential(student, credential)
+		if err != nil {
+			return err
+		}
+
+		if err := s.userRepo.Update(ctx, student); err != nil {
+			return fmt.Errorf("failed to update student: %w", err)
+		}
+		if cred.LoginCode != "" {
+			if err := s.storeLoginCode(ctx, student.ID, cred.LoginCode); err != nil {
+				return err
+			}
+		} else if err := s.loginCodeRepo.Delete(ctx, student.ID); err != nil {
+			return fmt.Errorf("failed to delete login code: %w", err)
+		}
+		if err := s.sessionRepo.RevokeAll(ctx, student.ID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		for _, subject := range []string{student.Username, student.Email} {
+			if err := s.throttleRepo.Clear(ctx, models.LoginThrottleAccount, loginSubject(subject)); err != nil {
+				return fmt.Errorf("failed to clear lockout: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cred, nil
+}
+
+// createStudent creates one managed student, issues their credential and
+// adds them to the group
+func (s *groupService) createStudent(ctx context.Context, groupID uuid.UUID, entry *rosterEntry, credential string) (*models.StudentCredential, error) {
+	now := time.Now()
+	id := uuid.New()
+	student := &models.User{
+		ID:               id,
+		Email:            "student-" + id.String() + "@students.invalid",
+		Username:         entry.username,
+		DisplayName:      entry.displayName,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		IsActive:         true,
+		SiteRole:         models.SiteRoleStudent,
+		ManagedByGroupID: &groupID,
+	}
+	if entry.email != nil {
+		student.Email = *entry.email
+	}
+
+	cred, err := issueStudentCredential(student, credential)
+	if err != nil {
+		return nil, err
+	}
+	cred.Email = entry.email
+
+	if err := s.userRepo.Create(ctx, student); err != nil {
+		return nil, fmt.Errorf("failed to create student %s: %w", entry.username, err)
+	}
+	// The login code references the user, so it is stored after them
+	if cred.LoginCode != "" {
+		if err := s.storeLoginCode(ctx, student.ID, cred.LoginCode); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.addMember(ctx, student.ID, groupID, "member"); err != nil {
+		return nil, err
+	}
+
+	return cred, nil
+}
+
+// issueStudentCredential generates a new password or login code for a
+// student and sets their password hash to match; a student signing in with
+// a login code has no password. The caller saves the user and the code.
+func issueStudentCredential(student *models.User, credential string) (*models.StudentCredential, error) {
+	cred := &models.StudentCredential{
+		UserID:      student.ID,
+		Username:    student.Username,
+		DisplayName: student.DisplayName,
+	}
+
+	switch credential {
+	case models.StudentCredentialPassword:
+		password, err := randomFromAlphabet(studentPasswordAlphabet, studentPasswordLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate password: %w", err)
+		}
+		// Hashing hundreds of passwords at the configured cost would take
+		// minutes. These are replaced on first use anyway, and signing in
+		// rehashes them at the configured cost.
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+		student.PasswordHash = string(hashed)
+		student.PasswordChangeRequired = true
+		cred.Password = password
+	default:
+		code, err := randomFromAlphabet(studentLoginCodeAlphabet, studentLoginCodeLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate login code: %w", err)
+		}
+		student.PasswordHash = ""
+		student.PasswordChangeRequired = false
+		cred.LoginCode = code[:studentLoginCodeLength/2] + "-" + code[studentLoginCodeLength/2:]
+	}
+
+	return cred, nil
+}
+
+// storeLoginCode saves the hash of a student's login code, replacing any
+// code they had
+func (s *groupService) storeLoginCode(ctx context.Context, userID uuid.UUID, code string) error {
+	err := s.loginCodeRepo.Upsert(ctx, &models.StudentLoginCode{
+		UserID:    userID,
+		CodeHash:  hashToken(normalizeLoginCode(code)),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store login code: %w", err)
+	}
+	return nil
+}
+
+// checkRosterAccounts returns ErrConflict naming the roster lines whose
+// username or email address is already taken
+func (s *groupService) checkRosterAccounts(ctx context.Context, entries []*rosterEntry) error {
+	var problems []string
+	for _, entry := range entries {
+		existing, err := s.userRepo.GetByUsername(ctx, entry.username)
+		if err != nil {
+			return fmt.Errorf("failed to check existing username: %w", err)
+		}
+		if existing != nil {
+			problems = append(problems, fmt.Sprintf("line %d: username %s is taken", entry.line, entry.username))
+		}
+
+		if entry.email != nil {
+			existing, err := s.userRepo.GetByEmail(ctx, *entry.email)
+			if err != nil {
+				return fmt.Errorf("failed to check existing email: %w", err)
+			}
+			if existing != nil {
+				problems = append(problems, fmt.Sprintf("line %d: email %s is taken", entry.line, *entry.email))
+			}
+		}
+
+		if len(problems) >= maxRosterErrors {
+			break
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %w", strings.Join(problems, "; "), ErrConflict)
+	}
+	return nil
+}
+
+// parseRoster reads a CSV roster with the columns name, username and an
+// optional email. A first row starting with "name" is taken as a header.
+// It returns ErrInvalidInput listing the first few bad lines.
+func parseRoster(roster io.Reader) ([]*rosterEntry, error) {
+	reader := csv.NewReader(roster)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var (
+		entries   []*rosterEntry
+		problems  []string
+		usernames = make(map[string]int)
+		emails    = make(map[string]int)
+	)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("roster is not valid CSV: %w: %w", err, ErrInvalidInput)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "name") {
+			continue
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(entries) == maxRosterRows {
+			return nil, fmt.Errorf("roster has more than %d students: %w", maxRosterRows, ErrInvalidInput)
+		}
+
+		entry, problem := parseRosterRecord(line, record)
+		if problem == "" {
+			if first, ok := usernames[entry.username]; ok {
+				problem = fmt.Sprintf("username %s is also on line %d", entry.username, first)
+			} else if entry.email != nil {
+				if first, ok := emails[*entry.email]; ok {
+					problem = fmt.Sprintf("email %s is also on line %d", *entry.email, first)
+				}
+			}
+		}
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("line %d: %s", line, problem))
+			if len(problems) == maxRosterErrors {
+				break
+			}
+			continue
+		}
+
+		usernames[entry.username] = line
+		if entry.email != nil {
+			emails[*entry.email] = line
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s: %w", strings.Join(problems, "; "), ErrInvalidInput)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("roster has no students: %w", ErrInvalidInput)
+	}
+	return entries, nil
+}
+
+// parseRosterRecord validates one roster row, returning why it is invalid
+// if it is
+func parseRosterRecord(line int, record []string) (*rosterEntry, string) {
+	if len(record) < 2 || len(record) > 3 {
+		return nil, "expected name, username and optional email"
+	}
+
+	entry := &rosterEntry{
+		line:        line,
+		displayName: strings.TrimSpace(record[0]),
+		username:    strings.ToLower(strings.TrimSpace(record[1])),
+	}
+	if entry.displayName == "" || len(entry.displayName) > 100 {
+		return nil, "name must be 1-100 characters"
+	}
+	if !studentUsernamePattern.MatchString(entry.username) {
+		return nil, "username must be 3-50 letters, digits, dots, dashes or underscores"
+	}
+
+	if len(record) == 3 {
+		if email := strings.TrimSpace(record[2]); email != "" {
+			address, err := mail.ParseAddress(email)
+			if err != nil || address.Address != email {
+				return nil, "email is not a valid address"
+			}
+			email = strings.ToLower(email)
+			entry.email = &email
+		}
+	}
+
+	return entry, ""
+}
+
+// randomFromAlphabet returns n characters picked uniformly at random from
+// alphabet
+func randomFromAlphabet(alphabet string, n int) (string, error) {
+	limit := big.NewInt(int64(len(alphabet)))
+	b := make([]byte, n)
+	for i := range b {
+		j, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[j.Int64()]
+	}
+	return string(b), nil
+}
+
+// normalizeLoginCode strips the separators people type into login codes and
+// upper-cases them
+func normalizeLoginCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRoster(t *testing.T) {
+	var tooMany strings.Builder
+	for i := range maxRosterRows + 1 {
+		fmt.Fprintf(&tooMany, "Student %d,student%03d\n", i, i)
+	}
+
+	tests := []struct {
+		name          string
+		roster        string
+		wantUsernames []string
+		wantEmails    []string
+		wantErr       string
+	}{
+		{
+			name:          "header and emails",
+			roster:        "name,username,email\nAda Lovelace,ADA.l,Ada@Example.com\nAlan Turing,alan_t,\n",
+			wantUsernames: []string{"ada.l", "alan_t"},
+			wantEmails:    []string{"ada@example.com", ""},
+		},
+		{
+			name:          "no header, two columns and blank lines",
+			roster:        "Ada Lovelace, ada\n\nAlan Turing, alan\n",
+			wantUsernames: []string{"ada", "alan"},
+			wantEmails:    []string{"", ""},
+		},
+		{
+			name:          "quoted name with a comma",
+			roster:        `"Lovelace, Ada",ada` + "\n",
+			wantUsernames: []string{"ada"},
+			wantEmails:    []string{""},
+		},
+		{
+			name:    "too few columns",
+			roster:  "Ada Lovelace\n",
+			wantErr: "line 1: expected name, username and optional email",
+		},
+		{
+			name:    "too many columns",
+			roster:  "Ada,ada,ada@example.com,extra\n",
+			wantErr: "line 1: expected name, username and optional email",
+		},
+		{
+			name:    "missing name",
+			roster:  "name,username\n,ada\n",
+			wantErr: "line 2: name must be 1-100 characters",
+		},
+		{
+			name:    "username too short",
+			roster:  "Ada,ad\n",
+			wantErr: "line 1: username must be 3-50",
+		},
+		{
+			name:    "username with spaces",
+			roster:  "Ada,ada lovelace\n",
+			wantErr: "line 1: username must be 3-50",
+		},
+		{
+			name:    "email with a display name",
+			roster:  "Ada,ada,Ada <ada@example.com>\n",
+			wantErr: "line 1: email is not a valid address",
+		},
+		{
+			name:    "duplicate username in another case",
+			roster:  "Ada,ada\nAda Again,ADA\n",
+			wantErr: "line 2: username ada is also on line 1",
+		},
+		{
+			name:    "duplicate email",
+			roster:  "Ada,ada,ada@example.com\nAlan,alan,ADA@example.com\n",
+			wantErr: "line 2: email ada@example.com is also on line 1",
+		},
+		{
+			name:    "several bad lines",
+			roster:  "Ada,ad\nAlan,al\n",
+			wantErr: "line 1: username must be 3-50 letters, digits, dots, dashes or underscores; line 2:",
+		},
+		{
+			name:    "header only",
+			roster:  "name,username,email\n",
+			wantErr: "roster has no students",
+		},
+		{
+			name:    "empty",
+			roster:  "",
+			wantErr: "roster has no students",
+		},
+		{
+			name:    "unterminated quote",
+			roster:  "\"Ada,ada\n",
+			wantErr: "roster is not valid CSV",
+		},
+		{
+			name:    "too many rows",
+			roster:  tooMany.String(),
+			wantErr: fmt.Sprintf("roster has more than %d students", maxRosterRows),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := parseRoster(strings.NewReader(tt.roster))
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseRoster() error = %v, want ErrInvalidInput containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRoster() error = %v", err)
+			}
+
+			var usernames, emails []string
+			for _, entry := range entries {
+				usernames = append(usernames, entry.username)
+				email := ""
+				if entry.email != nil {
+					email = *entry.email
+				}
+				emails = append(emails, email)
+			}
+			if !slices.Equal(usernames, tt.wantUsernames) || !slices.Equal(emails, tt.wantEmails) {
+				t.Errorf("parseRoster() usernames %q, emails %q; want %q, %q", usernames, emails, tt.wantUsernames, tt.wantEmails)
+			}
+		})
+	}
+}
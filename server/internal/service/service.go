@@ -1,25 +1,64 @@
 package service
 
 import (
+	"github.com/andy-dam/iq-theory/server/internal/config"
 	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/mailer"
+	"github.com/andy-dam/iq-theory/server/pkg/oidc"
+	"github.com/andy-dam/iq-theory/server/pkg/storage"
 )
 
 // Services aggregates all service implementations
 type Services struct {
 	User        UserService
+	Permission  PermissionService
+	Session     SessionService
+	APIToken    APITokenService
+	MFA         MFAService
+	Identity    IdentityService
+	Avatar      AvatarService
+	Preferences PreferencesService
+	Account     AccountService
 	Friendship  FriendshipService
+	Activity    ActivityService
 	Group       GroupService
 	Quiz        QuizService
 	Leaderboard LeaderboardService
+	FeatureFlag FeatureFlagService
 }
 
 // NewServices creates a new instance of all services
-func NewServices(repos *repository.Repositories) *Services {
+func NewServices(repos *repository.Repositories, cfg *config.Config, mail mailer.Mailer, store, exports storage.Storage) *Services {
+	users := NewUserService(repos, mail, cfg.Account, cfg.Password, cfg.Lockout)
+	permissions := NewPermissionService(repos, cfg.Admin.UserIDs)
+
+	var providers []*oidc.Provider
+	for _, p := range cfg.OIDC.Providers {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  cfg.Server.PublicURL + "/api/auth/oidc/" + p.Name + "/callback",
+			Scopes:       p.Scopes,
+		}))
+	}
+
 	return &Services{
-		User:        NewUserService(repos),
+		User:        users,
+		Permission:  permissions,
+		Session:     NewSessionService(repos, cfg.JWT.TTL, cfg.Sessions.TouchInterval),
+		APIToken:    NewAPITokenService(repos, cfg.Sessions.TouchInterval),
+		MFA:         NewMFAService(repos, cfg.MFA),
+		Identity:    NewIdentityService(repos, users, providers, cfg.OIDC.StateTTL),
+		Avatar:      NewAvatarService(repos, store, cfg.Avatar),
+		Preferences: NewPreferencesService(repos),
+		Account:     NewAccountService(repos, mail, store, exports, cfg.Account),
 		Friendship:  NewFriendshipService(repos),
-		Group:       NewGroupService(repos),
+		Activity:    NewActivityService(repos),
+		Group:       NewGroupService(repos, permissions),
 		Quiz:        NewQuizService(repos),
 		Leaderboard: NewLeaderboardService(repos),
+		FeatureFlag: NewFeatureFlagService(repos, cfg.Admin.UserIDs, cfg.Features.CacheTTL),
 	}
 }
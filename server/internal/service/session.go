@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+// maxUserAgentLength caps the stored user agent
+const maxUserAgentLength = 512
+
+// sessionService implements the SessionService interface
+type sessionService struct {
+	tx          repository.Transactor
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	// ttl matches the access token lifetime
+	ttl time.Duration
+	// touchInterval throttles last_seen_at writes to one per interval
+	touchInterval time.Duration
+}
+
+// NewSessionService creates a new session service instance
+func NewSessionService(repos *repository.Repositories, ttl, touchInterval time.Duration) SessionService {
+	return &sessionService{
+		tx:            repos.Tx,
+		userRepo:      repos.User,
+		sessionRepo:   repos.Session,
+		ttl:           ttl,
+		touchInterval: touchInterval,
+	}
+}
+
+// StartSession records a new login from a device
+func (s *sessionService) StartSession(ctx context.Context, userID uuid.UUID, userAgent, ipAddress string) (*models.Session, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.StartSession")
+	defer span.End()
+
+	userAgent = truncateUserAgent(userAgent)
+
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session, nil
+}
+
+// truncateUserAgent replaces invalid UTF-8, which Postgres rejects, and cuts
+// the user agent to at most maxUserAgentLength bytes on a rune boundary
+func truncateUserAgent(userAgent string) string {
+	userAgent = strings.ToValidUTF8(userAgent, "\uFFFD")
+	if len(userAgent) <= maxUserAgentLength {
+		return userAgent
+	}
+	end := maxUserAgentLength
+	for end > 0 && !utf8.RuneStart(userAgent[end]) {
+		end--
+	}
+	return userAgent[:end]
+}
+
+// ValidateSession returns ErrInvalidToken unless the session belongs to the
+// user and is neither revoked nor expired. It also bumps last_seen_at, at
+// most once per touch interval.
+func (s *sessionService) ValidateSession(ctx context.Context, sessionID, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "SessionService.ValidateSession")
+	defer span.End()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil || session.UserID != userID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return ErrInvalidToken
+	}
+
+	if time.Since(session.LastSeenAt) >= s.touchInterval {
+		if err := s.sessionRepo.Touch(ctx, sessionID); err != nil {
+			return fmt.Errorf("failed to touch session: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListSessions lists a user's active sessions
+func (s *sessionService) ListSessions(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	ctx, span := tracing.Start(ctx, "SessionService.ListSessions")
+	defer span.End()
+
+	sessions, err := s.sessionRepo.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	if sessions == nil {
+		sessions = []*models.Session{}
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one of the user's devices out
+func (s *sessionService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "SessionService.RevokeSession")
+	defer span.End()
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil || session.UserID != userID {
+		return fmt.Errorf("session %w", ErrNotFound)
+	}
+
+	if err := s.sessionRepo.Revoke(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllSessions signs the user out on every device, including the one
+// making the request. Moving tokens_valid_after forward also retires API
+// tokens and Firebase ID tokens issued before now.
+func (s *sessionService) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "SessionService.RevokeAllSessions")
+	defer span.End()
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil {
+			return fmt.Errorf("user %w", ErrNotFound)
+		}
+
+		now := time.Now()
+		user.TokensValidAfter = &now
+		user.UpdatedAt = now
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return fmt.Errorf("failed to revoke tokens: %w", err)
+		}
+		if err := s.sessionRepo.RevokeAll(ctx, userID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestRevokeAllSessions(t *testing.T) {
+	ctx := context.Background()
+	user := &models.User{ID: uuid.New()}
+	other := uuid.New()
+	mine := &models.Session{ID: uuid.New(), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	theirs := &models.Session{ID: uuid.New(), UserID: other, ExpiresAt: time.Now().Add(time.Hour)}
+
+	users := newFakeUserRepo(user)
+	sessions := newFakeSessionRepo(mine, theirs)
+	s := &sessionService{tx: fakeTx{}, userRepo: users, sessionRepo: sessions, ttl: time.Hour}
+
+	before := time.Now()
+	if err := s.RevokeAllSessions(ctx, user.ID); err != nil {
+		t.Fatalf("RevokeAllSessions: %v", err)
+	}
+
+	validAfter := users.users[user.ID].TokensValidAfter
+	if validAfter == nil || validAfter.Before(before) {
+		t.Errorf("TokensValidAfter = %v, want at least %s", validAfter, before)
+	}
+	if err := s.ValidateSession(ctx, mine.ID, user.ID); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateSession(revoked) error = %v, want ErrInvalidToken", err)
+	}
+	if theirs.RevokedAt != nil {
+		t.Error("another user's session was revoked")
+	}
+
+	if err := s.RevokeAllSessions(ctx, other); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RevokeAllSessions(unknown user) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTruncateUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{"short", "Mozilla/5.0", "Mozilla/5.0"},
+		{"exactly the limit", strings.Repeat("a", maxUserAgentLength), strings.Repeat("a", maxUserAgentLength)},
+		{"cut at the limit", strings.Repeat("a", maxUserAgentLength+10), strings.Repeat("a", maxUserAgentLength)},
+		{"cut before a split rune", strings.Repeat("a", maxUserAgentLength-1) + "é", strings.Repeat("a", maxUserAgentLength-1)},
+		{"invalid UTF-8 replaced", "Mozilla\xff/5.0", "Mozilla�/5.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateUserAgent(tt.userAgent)
+			if got != tt.want {
+				t.Errorf("truncateUserAgent() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) || len(got) > maxUserAgentLength {
+				t.Errorf("truncateUserAgent() = %q is invalid or too long", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+)
+
+// AuthenticateStudent checks the username and password of a student managed
+// by a group, who may have no email address to sign in with. Failures lock
+// out the username and the client IP as for email sign-ins, but no unlock
+// link is mailed; the student's teacher resets the password instead.
+func (s *userService) AuthenticateStudent(ctx context.Context, username, password, ipAddress string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.AuthenticateStudent")
+	defer span.End()
+
+	username = strings.ToLower(strings.TrimSpace(username))
+	if err := s.checkLockout(ctx, username, ipAddress); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Only managed students sign in by username
+	if user != nil && user.ManagedByGroupID == nil {
+		user = nil
+	}
+	if !s.checkPassword(user, password) {
+		if err := s.recordLoginFailure(ctx, username, ipAddress, nil); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.throttleRepo.Clear(ctx, models.LoginThrottleAccount, loginSubject(username)); err != nil {
+		return nil, fmt.Errorf("failed to clear lockout: %w", err)
+	}
+
+	s.rehashPassword(ctx, user, password)
+	return user, nil
+}
+
+// AuthenticateLoginCode signs a managed student in with the login code their
+// teacher printed for them. A code names no account to lock out, so only
+// the client IP is throttled.
+func (s *userService) AuthenticateLoginCode(ctx context.Context, code, ipAddress string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.AuthenticateLoginCode")
+	defer span.End()
+
+	if err := s.checkLockout(ctx, "", ipAddress); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.loginCodeRepo.GetByHash(ctx, hashToken(normalizeLoginCode(code)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login code: %w", err)
+	}
+
+	var user *models.User
+	if stored != nil {
+		user, err = s.userRepo.GetByID(ctx, stored.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+	}
+	if user == nil || user.ManagedByGroupID == nil {
+		if ipAddress != "" {
+			if _, err := s.countFailure(ctx, models.LoginThrottleIP, ipAddress, s.lockout.IPThreshold); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.loginCodeRepo.Touch(ctx, user.ID); err != nil {
+		log.Printf("Failed to record login code use for user %s: %v", user.ID, err)
+	}
+	return user, nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+
+	"github.com/andy-dam/iq-theory/server/internal/events"
+)
+
+// RegisterSubscribers wires the services that react to domain events into
+// the dispatcher
+func (s *Services) RegisterSubscribers(dispatcher *events.Dispatcher) {
+	// Leaderboards used to be refreshed by a trigger inside the completing
+	// UPDATE. Marking them stale keeps that cost off the request path, and
+	// the refresh loop rebuilds them once for a burst of completions.
+	dispatcher.Subscribe(events.TypeQuizCompleted, "leaderboard.refresh", func(ctx context.Context, _ events.Envelope) error {
+		s.Leaderboard.MarkStale()
+		return nil
+	})
+
+	// A guest's results join the leaderboards once they belong to a
+	// registered account
+	dispatcher.Subscribe(events.TypeGuestUpgraded, "leaderboard.refresh", func(ctx context.Context, _ events.Envelope) error {
+		s.Leaderboard.MarkStale()
+		return nil
+	})
+
+	dispatcher.Subscribe(events.TypeQuizCompleted, "activity.record_quiz", func(ctx context.Context, envelope events.Envelope) error {
+		var event events.QuizCompleted
+		if err := envelope.Decode(&event); err != nil {
+			return err
+		}
+		return s.Activity.RecordQuizCompleted(ctx, event)
+	})
+
+	dispatcher.Subscribe(events.TypeMemberJoinedGroup, "activity.record_group_join", func(ctx context.Context, envelope events.Envelope) error {
+		var event events.MemberJoinedGroup
+		if err := envelope.Decode(&event); err != nil {
+			return err
+		}
+		return s.Activity.RecordGroupJoined(ctx, event)
+	})
+
+	dispatcher.Subscribe(events.TypeExportRequested, "account.build_export", func(ctx context.Context, envelope events.Envelope) error {
+		var event events.ExportRequested
+		if err := envelope.Decode(&event); err != nil {
+			return err
+		}
+		return s.Account.BuildExport(ctx, event.ExportID)
+	})
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/config"
+	"github.com/andy-dam/iq-theory/server/internal/events"
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/google/uuid"
+)
+
+type fakeActivityService struct {
+	ActivityService
+	completed []events.QuizCompleted
+}
+
+func (s *fakeActivityService) RecordQuizCompleted(ctx context.Context, event events.QuizCompleted) error {
+	s.completed = append(s.completed, event)
+	return nil
+}
+
+func TestQuizCompletedRefreshesLeaderboardsOnce(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	started := time.Now().Add(-time.Minute)
+	quizSessions := &fakeQuizSessionRepo{sessions: make(map[uuid.UUID]*models.QuizSession)}
+	var sessionIDs []uuid.UUID
+	for range 3 {
+		id := uuid.New()
+		quizSessions.sessions[id] = &models.QuizSession{ID: id, UserID: userID, Clef: "treble", DurationSeconds: 60, StartedAt: started, Status: "in_progress"}
+		sessionIDs = append(sessionIDs, id)
+	}
+
+	outbox := &fakeOutboxRepo{}
+	leaderboardRepo := &fakeLeaderboardRepo{}
+	leaderboards := &leaderboardService{leaderboardRepo: leaderboardRepo}
+	activity := &fakeActivityService{}
+	quiz := &quizService{tx: fakeTx{}, events: events.NewPublisher(outbox), sessionRepo: quizSessions}
+
+	if _, err := quiz.CompleteQuizSession(ctx, uuid.New(), sessionIDs[0]); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("CompleteQuizSession(another user's session) error = %v, want ErrNotFound", err)
+	}
+	for _, id := range sessionIDs {
+		if _, err := quiz.CompleteQuizSession(ctx, userID, id); err != nil {
+			t.Fatalf("CompleteQuizSession: %v", err)
+		}
+	}
+	if _, err := quiz.CompleteQuizSession(ctx, userID, sessionIDs[0]); !errors.Is(err, ErrConflict) {
+		t.Fatalf("CompleteQuizSession(completed session) error = %v, want ErrConflict", err)
+	}
+
+	dispatcher := events.NewDispatcher(outbox, config.EventsConfig{PollInterval: time.Second, BatchSize: 10, MaxAttempts: 1})
+	(&Services{Leaderboard: leaderboards, Activity: activity}).RegisterSubscribers(dispatcher)
+	// Run delivers one batch before it notices the cancelled context
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	dispatcher.Run(cancelled)
+
+	for _, event := range outbox.events {
+		if event.EventType != events.TypeQuizCompleted || event.Status != "delivered" {
+			t.Errorf("outbox event %s %s is %s, want a delivered QuizCompleted", event.ID, event.EventType, event.Status)
+		}
+	}
+	if len(outbox.events) != 3 || len(activity.completed) != 3 {
+		t.Fatalf("published %d and recorded %d completions, want 3", len(outbox.events), len(activity.completed))
+	}
+	if leaderboardRepo.refreshes != 0 {
+		t.Errorf("delivery refreshed the leaderboards %d times, want 0", leaderboardRepo.refreshes)
+	}
+
+	for i, want := range []bool{true, false} {
+		refreshed, err := leaderboards.RefreshIfStale(ctx)
+		if err != nil {
+			t.Fatalf("RefreshIfStale: %v", err)
+		}
+		if refreshed != want {
+			t.Errorf("RefreshIfStale() call %d = %v, want %v", i+1, refreshed, want)
+		}
+	}
+	if leaderboardRepo.refreshes != 1 {
+		t.Errorf("leaderboards refreshed %d times, want 1", leaderboardRepo.refreshes)
+	}
+}
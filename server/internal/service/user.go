@@ -3,54 +3,92 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
+	"github.com/andy-dam/iq-theory/server/internal/config"
+	"github.com/andy-dam/iq-theory/server/internal/events"
 	"github.com/andy-dam/iq-theory/server/internal/models"
 	"github.com/andy-dam/iq-theory/server/internal/repository"
+	"github.com/andy-dam/iq-theory/server/pkg/mailer"
+	"github.com/andy-dam/iq-theory/server/pkg/password"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // userService implements the UserServiceInterface
 type userService struct {
+	tx       repository.Transactor
+	events   events.Publisher
+	mailer   mailer.Mailer
+	account  config.AccountConfig
+	password config.PasswordConfig
+	policy   password.Policy
+	lockout  config.LockoutConfig
+	// dummyHash is compared against when there is no password to check
+	dummyHash      func() []byte
 	userRepo       repository.UserRepository
+	throttleRepo   repository.LoginThrottleRepository
+	loginCodeRepo  repository.StudentLoginCodeRepository
+	userTokenRepo  repository.UserTokenRepository
+	sessionRepo    repository.SessionRepository
+	identityRepo   repository.UserIdentityRepository
 	friendshipRepo repository.FriendshipRepository
+	// quizSessionRepo feeds the stats on public profiles
+	quizSessionRepo repository.QuizSessionRepository
+	// blockRepo, membershipRepo and quizAnswerRepo decide and fill
+	// head-to-head comparisons
+	blockRepo      repository.BlockRepository
+	membershipRepo repository.GroupMembershipRepository
+	quizAnswerRepo repository.QuizAnswerRepository
 }
 
 // NewUserService creates a new user service instance
-func NewUserService(repos *repository.Repositories) UserService {
+func NewUserService(repos *repository.Repositories, mail mailer.Mailer, account config.AccountConfig, passwords config.PasswordConfig, lockout config.LockoutConfig) UserService {
 	return &userService{
-		userRepo:       repos.User,
-		friendshipRepo: repos.Friendship,
+		tx:       repos.Tx,
+		events:   events.NewPublisher(repos.Outbox),
+		mailer:   mail,
+		account:  account,
+		password: passwords,
+		policy: password.Policy{
+			MinLength:      passwords.MinLength,
+			BlockCommon:    passwords.BlockCommon,
+			RejectUsername: passwords.RejectUsername,
+		},
+		lockout: lockout,
+		dummyHash: sync.OnceValue(func() []byte {
+			hashed, _ := bcrypt.GenerateFromPassword([]byte("not a password"), passwords.BcryptCost)
+			return hashed
+		}),
+		userRepo:        repos.User,
+		throttleRepo:    repos.LoginThrottle,
+		loginCodeRepo:   repos.StudentLoginCode,
+		userTokenRepo:   repos.UserToken,
+		sessionRepo:     repos.Session,
+		identityRepo:    repos.UserIdentity,
+		friendshipRepo:  repos.Friendship,
+		quizSessionRepo: repos.QuizSession,
+		blockRepo:       repos.Block,
+		membershipRepo:  repos.GroupMembership,
+		quizAnswerRepo:  repos.QuizAnswer,
 	}
 }
 
 // CreateUser creates a new user
 func (s *userService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
-	// Check if email already exists
-	existingUser, err := s.userRepo.GetByEmail(ctx, req.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check existing email: %w", err)
-	}
-	if existingUser != nil {
-		return nil, fmt.Errorf("email already exists")
-	}
-
-	// Check if username already exists
-	existingUser, err = s.userRepo.GetByUsername(ctx, req.Username)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check existing username: %w", err)
-	}
-	if existingUser != nil {
-		return nil, fmt.Errorf("username already exists")
-	}
+	ctx, span := tracing.Start(ctx, "UserService.CreateUser")
+	defer span.End()
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.checkRegistration(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+		return nil, err
 	}
 
 	// Create user model
@@ -60,7 +98,7 @@ func (s *userService) CreateUser(ctx context.Context, req *models.CreateUserRequ
 		Email:         req.Email,
 		Username:      req.Username,
 		DisplayName:   req.DisplayName,
-		PasswordHash:  string(hashedPassword),
+		PasswordHash:  hashedPassword,
 		CreatedAt:     now,
 		UpdatedAt:     now,
 		IsActive:      true,
@@ -72,47 +110,65 @@ func (s *userService) CreateUser(ctx context.Context, req *models.CreateUserRequ
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	// The account is usable without a verified email, and the user can ask
+	// for another link, so a mail failure must not fail registration
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+
 	return user, nil
 }
 
 // GetUserByID retrieves a user by ID
 func (s *userService) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByID")
+	defer span.End()
+
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user by ID: %w", err)
 	}
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user %w", ErrNotFound)
 	}
 	return user, nil
 }
 
 // GetUserByEmail retrieves a user by email
 func (s *userService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByEmail")
+	defer span.End()
+
 	user, err := s.userRepo.GetByEmail(ctx, email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user %w", ErrNotFound)
 	}
 	return user, nil
 }
 
 // GetUserByUsername retrieves a user by username
 func (s *userService) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByUsername")
+	defer span.End()
+
 	user, err := s.userRepo.GetByUsername(ctx, username)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user by username: %w", err)
 	}
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, fmt.Errorf("user %w", ErrNotFound)
 	}
 	return user, nil
 }
 
 // UpdateUser updates an existing user
 func (s *userService) UpdateUser(ctx context.Context, user *models.User) error {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUser")
+	defer span.End()
+
 	user.UpdatedAt = time.Now()
 	if err := s.userRepo.Update(ctx, user); err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
@@ -120,55 +176,82 @@ func (s *userService) UpdateUser(ctx context.Context, user *models.User) error {
 	return nil
 }
 
-// DeleteUser soft deletes a user
+// DeleteUser soft deletes a user. The account is purged once the deletion
+// grace period has passed.
 func (s *userService) DeleteUser(ctx context.Context, userID uuid.UUID) error {
-	if err := s.userRepo.Delete(ctx, userID); err != nil {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUser")
+	defer span.End()
+
+	if err := s.userRepo.Delete(ctx, userID, time.Now().Add(s.account.DeletionGracePeriod)); err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 	return nil
 }
 
-// AuthenticateUser validates user credentials
-func (s *userService) AuthenticateUser(ctx context.Context, email, password string) (*models.User, error) {
+// AuthenticateUser checks an email and password. Repeated failures lock the
+// email address and the client IP out for a while. Unknown addresses cost
+// the same bcrypt comparison as known ones, so the response time does not
+// reveal which are registered.
+func (s *userService) AuthenticateUser(ctx context.Context, email, password, ipAddress string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.AuthenticateUser")
+	defer span.End()
+
+	if err := s.checkLockout(ctx, email, ipAddress); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByEmail(ctx, email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	if user == nil {
-		return nil, fmt.Errorf("invalid credentials")
+
+	if !s.checkPassword(user, password) {
+		if err := s.recordLoginFailure(ctx, email, ipAddress, user); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 
-	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+	if err := s.throttleRepo.Clear(ctx, models.LoginThrottleAccount, loginSubject(email)); err != nil {
+		return nil, fmt.Errorf("failed to clear lockout: %w", err)
 	}
 
+	s.rehashPassword(ctx, user, password)
 	return user, nil
 }
 
-// ChangePassword changes a user's password
+// ChangePassword changes a user's password, replacing a generated initial
+// password
 func (s *userService) ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return fmt.Errorf("user %w", ErrNotFound)
 	}
 
 	// Verify old password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword)); err != nil {
-		return fmt.Errorf("invalid current password")
+		return fmt.Errorf("current password is incorrect: %w", ErrInvalidInput)
+	}
+
+	if err := s.checkPasswordPolicy(newPassword, user.Username); err != nil {
+		return err
 	}
 
 	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := s.hashPassword(newPassword)
 	if err != nil {
-		return fmt.Errorf("failed to hash new password: %w", err)
+		return err
 	}
 
 	// Update password
-	user.PasswordHash = string(hashedPassword)
+	user.PasswordHash = hashedPassword
+	user.PasswordChangeRequired = false
 	user.UpdatedAt = time.Now()
 
 	if err := s.userRepo.Update(ctx, user); err != nil {
@@ -178,18 +261,21 @@ func (s *userService) ChangePassword(ctx context.Context, userID uuid.UUID, oldP
 	return nil
 }
 
-// UpdateProfile updates user profile information
-func (s *userService) UpdateProfile(ctx context.Context, userID uuid.UUID, displayName string, avatarURL *string) error {
+// UpdateProfile updates user profile information. Avatars are changed
+// through AvatarService, so they always point at stored uploads.
+func (s *userService) UpdateProfile(ctx context.Context, userID uuid.UUID, displayName string) error {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateProfile")
+	defer span.End()
+
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return fmt.Errorf("user %w", ErrNotFound)
 	}
 
 	user.DisplayName = displayName
-	user.AvatarURL = avatarURL
 	user.UpdatedAt = time.Now()
 
 	if err := s.userRepo.Update(ctx, user); err != nil {
@@ -201,12 +287,15 @@ func (s *userService) UpdateProfile(ctx context.Context, userID uuid.UUID, displ
 
 // VerifyEmail marks a user's email as verified
 func (s *userService) VerifyEmail(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "UserService.VerifyEmail")
+	defer span.End()
+
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return fmt.Errorf("user %w", ErrNotFound)
 	}
 
 	user.EmailVerified = true
@@ -219,77 +308,342 @@ func (s *userService) VerifyEmail(ctx context.Context, userID uuid.UUID) error {
 	return nil
 }
 
+const (
+	// defaultSuggestionLimit and maxSuggestionLimit bound a list of friend
+	// suggestions
+	defaultSuggestionLimit = 10
+	maxSuggestionLimit     = 50
+	// suggestionActiveWindow is how recently a group-mate must have completed
+	// a quiz to rank higher in suggestions
+	suggestionActiveWindow = 30 * 24 * time.Hour
+	// declinedRequestCooldown is how long after a decline the requester must
+	// wait to ask the same user again
+	declinedRequestCooldown = 30 * 24 * time.Hour
+)
+
 // friendshipService implements the FriendshipServiceInterface
 type friendshipService struct {
+	tx             repository.Transactor
+	events         events.Publisher
 	friendshipRepo repository.FriendshipRepository
+	blockRepo      repository.BlockRepository
 	userRepo       repository.UserRepository
 }
 
 // NewFriendshipService creates a new friendship service instance
 func NewFriendshipService(repos *repository.Repositories) FriendshipService {
 	return &friendshipService{
+		tx:             repos.Tx,
+		events:         events.NewPublisher(repos.Outbox),
 		friendshipRepo: repos.Friendship,
+		blockRepo:      repos.Block,
 		userRepo:       repos.User,
 	}
 }
 
-// SendFriendRequest sends a friend request
-func (s *friendshipService) SendFriendRequest(ctx context.Context, requesterID, addresseeID uuid.UUID) error {
+// SendFriendRequest sends a friend request. If the addressee has already
+// sent one to the requester, it is accepted instead. A declined request can
+// be sent again once declinedRequestCooldown has passed. Neither user can
+// send one while either has blocked the other.
+func (s *friendshipService) SendFriendRequest(ctx context.Context, requesterID, addresseeID uuid.UUID) (*models.Friendship, error) {
+	ctx, span := tracing.Start(ctx, "FriendshipService.SendFriendRequest")
+	defer span.End()
+
 	if requesterID == addresseeID {
-		return fmt.Errorf("cannot send friend request to yourself")
+		return nil, fmt.Errorf("cannot send a friend request to yourself: %w", ErrInvalidInput)
 	}
 
-	// Check if users exist
-	if _, err := s.userRepo.GetByID(ctx, requesterID); err != nil {
-		return fmt.Errorf("requester not found: %w", err)
+	addressee, err := s.userRepo.GetByID(ctx, addresseeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	if _, err := s.userRepo.GetByID(ctx, addresseeID); err != nil {
-		return fmt.Errorf("addressee not found: %w", err)
+	if addressee == nil || addressee.IsGuest || addressee.DeletedAt != nil {
+		return nil, fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	var friendship *models.Friendship
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		blocks, err := s.blockRepo.GetBetween(ctx, requesterID, addresseeID)
+		if err != nil {
+			return fmt.Errorf("failed to get blocks: %w", err)
+		}
+		for _, block := range blocks {
+			if block.BlockerID == requesterID {
+				return fmt.Errorf("unblock this user before sending a friend request: %w", ErrConflict)
+			}
+		}
+		if len(blocks) > 0 {
+			return fmt.Errorf("cannot send a friend request to this user: %w", ErrForbidden)
+		}
+
+		existing, err := s.friendshipRepo.GetBetween(ctx, requesterID, addresseeID)
+		if err != nil {
+			return fmt.Errorf("failed to get friendship: %w", err)
+		}
+
+		now := time.Now()
+		switch {
+		case existing == nil:
+			friendship = &models.Friendship{
+				ID:          uuid.New(),
+				RequesterID: requesterID,
+				AddresseeID: addresseeID,
+				Status:      models.FriendshipPending,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}
+			if err := s.friendshipRepo.Create(ctx, friendship); err != nil {
+				return fmt.Errorf("failed to create friend request: %w", err)
+			}
+		case existing.Status == models.FriendshipAccepted:
+			return fmt.Errorf("friendship %w", ErrConflict)
+		case existing.Status == models.FriendshipPending && existing.RequesterID == requesterID:
+			return fmt.Errorf("friend request %w", ErrConflict)
+		case existing.Status == models.FriendshipDeclined && existing.RequesterID == requesterID &&
+			now.Sub(existing.UpdatedAt) < declinedRequestCooldown:
+			// Answered like a request still waiting, so the decline stays
+			// private
+			return fmt.Errorf("friend request %w", ErrConflict)
+		case existing.Status == models.FriendshipPending:
+			// They asked first, so this request answers theirs
+			friendship = existing
+			friendship.Status = models.FriendshipAccepted
+			friendship.UpdatedAt = now
+			if err := s.friendshipRepo.UpdateStatus(ctx, friendship.ID, friendship.Status); err != nil {
+				return fmt.Errorf("failed to accept friend request: %w", err)
+			}
+			return nil
+		default:
+			// Declined: the user who declined can ask, and the requester
+			// can ask again after the cooldown
+			friendship = existing
+			friendship.RequesterID = requesterID
+			friendship.AddresseeID = addresseeID
+			friendship.Status = models.FriendshipPending
+			friendship.UpdatedAt = now
+			if err := s.friendshipRepo.Update(ctx, friendship); err != nil {
+				return fmt.Errorf("failed to send friend request: %w", err)
+			}
+		}
+
+		return s.events.Publish(ctx, events.FriendRequestSent{
+			FriendshipID: friendship.ID,
+			RequesterID:  requesterID,
+			AddresseeID:  addresseeID,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Create friendship request
-	now := time.Now()
-	friendship := &models.Friendship{
-		ID:          uuid.New(),
-		RequesterID: requesterID,
-		AddresseeID: addresseeID,
-		Status:      "pending",
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	}
+	return friendship, nil
+}
 
-	if err := s.friendshipRepo.Create(ctx, friendship); err != nil {
-		return fmt.Errorf("failed to create friend request: %w", err)
-	}
+// AcceptFriendRequest accepts a friend request sent to userID
+func (s *friendshipService) AcceptFriendRequest(ctx context.Context, userID, friendshipID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "FriendshipService.AcceptFriendRequest")
+	defer span.End()
 
-	return nil
+	return s.answerRequest(ctx, userID, friendshipID, models.FriendshipAccepted)
+}
+
+// DeclineFriendRequest declines a friend request sent to userID. The
+// requester is not told, and can send another request later.
+func (s *friendshipService) DeclineFriendRequest(ctx context.Context, userID, friendshipID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "FriendshipService.DeclineFriendRequest")
+	defer span.End()
+
+	return s.answerRequest(ctx, userID, friendshipID, models.FriendshipDeclined)
 }
 
-// AcceptFriendRequest accepts a friend request
-func (s *friendshipService) AcceptFriendRequest(ctx context.Context, friendshipID uuid.UUID) error {
-	return s.friendshipRepo.UpdateStatus(ctx, friendshipID, "accepted")
+// answerRequest sets the status of a pending request. Only its addressee can
+// answer it; to anyone else but the requester it does not exist.
+func (s *friendshipService) answerRequest(ctx context.Context, userID, friendshipID uuid.UUID, status string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		friendship, err := s.friendshipRepo.GetByID(ctx, friendshipID)
+		if err != nil {
+			return fmt.Errorf("failed to get friend request: %w", err)
+		}
+		if friendship == nil || friendship.Status != models.FriendshipPending ||
+			(friendship.AddresseeID != userID && friendship.RequesterID != userID) {
+			return fmt.Errorf("friend request %w", ErrNotFound)
+		}
+		if friendship.AddresseeID != userID {
+			return fmt.Errorf("only the recipient can answer a friend request: %w", ErrForbidden)
+		}
+
+		if err := s.friendshipRepo.UpdateStatus(ctx, friendshipID, status); err != nil {
+			return fmt.Errorf("failed to update friend request: %w", err)
+		}
+		return nil
+	})
 }
 
-// DeclineFriendRequest declines a friend request
-func (s *friendshipService) DeclineFriendRequest(ctx context.Context, friendshipID uuid.UUID) error {
-	return s.friendshipRepo.UpdateStatus(ctx, friendshipID, "declined")
+// RemoveFriend ends a friendship, or withdraws a request userID sent
+func (s *friendshipService) RemoveFriend(ctx context.Context, userID, friendshipID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "FriendshipService.RemoveFriend")
+	defer span.End()
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		friendship, err := s.friendshipRepo.GetByID(ctx, friendshipID)
+		if err != nil {
+			return fmt.Errorf("failed to get friendship: %w", err)
+		}
+		if friendship == nil || !canRemove(friendship, userID) {
+			return fmt.Errorf("friendship %w", ErrNotFound)
+		}
+
+		if err := s.friendshipRepo.Delete(ctx, friendshipID); err != nil {
+			return fmt.Errorf("failed to delete friendship: %w", err)
+		}
+		return nil
+	})
 }
 
-// RemoveFriend removes a friendship
-func (s *friendshipService) RemoveFriend(ctx context.Context, friendshipID uuid.UUID) error {
-	return s.friendshipRepo.Delete(ctx, friendshipID)
+// canRemove reports whether userID can delete friendship: either friend can
+// end a friendship, and a requester can withdraw their pending request
+func canRemove(friendship *models.Friendship, userID uuid.UUID) bool {
+	switch friendship.Status {
+	case models.FriendshipAccepted:
+		return friendship.RequesterID == userID || friendship.AddresseeID == userID
+	case models.FriendshipPending:
+		return friendship.RequesterID == userID
+	default:
+		return false
+	}
 }
 
 // GetUserFriends gets all friends for a user
 func (s *friendshipService) GetUserFriends(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error) {
-	return s.friendshipRepo.GetUserFriends(ctx, userID)
+	ctx, span := tracing.Start(ctx, "FriendshipService.GetUserFriends")
+	defer span.End()
+
+	return friendshipList(s.friendshipRepo.GetUserFriends(ctx, userID))
+}
+
+// GetIncomingRequests gets the pending friend requests sent to a user
+func (s *friendshipService) GetIncomingRequests(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error) {
+	ctx, span := tracing.Start(ctx, "FriendshipService.GetIncomingRequests")
+	defer span.End()
+
+	return friendshipList(s.friendshipRepo.GetIncoming(ctx, userID))
+}
+
+// GetOutgoingRequests gets the pending friend requests a user has sent.
+// Declined requests are left out.
+func (s *friendshipService) GetOutgoingRequests(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error) {
+	ctx, span := tracing.Start(ctx, "FriendshipService.GetOutgoingRequests")
+	defer span.End()
+
+	return friendshipList(s.friendshipRepo.GetOutgoing(ctx, userID))
+}
+
+// BlockUser blocks a user, ending any friendship or pending request
+// between the two. Neither can send the other a friend request until the
+// block is lifted. Blocks are one-way, so each user can block the other.
+func (s *friendshipService) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "FriendshipService.BlockUser")
+	defer span.End()
+
+	if blockerID == blockedID {
+		return fmt.Errorf("cannot block yourself: %w", ErrInvalidInput)
+	}
+
+	blocked, err := s.userRepo.GetByID(ctx, blockedID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if blocked == nil {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		existing, err := s.friendshipRepo.GetBetween(ctx, blockerID, blockedID)
+		if err != nil {
+			return fmt.Errorf("failed to get friendship: %w", err)
+		}
+		// A declined request is kept so its cooldown survives the block
+		if existing != nil && existing.Status != models.FriendshipDeclined {
+			if err := s.friendshipRepo.Delete(ctx, existing.ID); err != nil {
+				return fmt.Errorf("failed to end friendship: %w", err)
+			}
+		}
+
+		err = s.blockRepo.Create(ctx, &models.UserBlock{
+			BlockerID: blockerID,
+			BlockedID: blockedID,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to block user: %w", err)
+		}
+		return nil
+	})
+}
+
+// UnblockUser lifts a block blockerID made. It does not restore the
+// friendship, or lift a block the other user made.
+func (s *friendshipService) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "FriendshipService.UnblockUser")
+	defer span.End()
+
+	err := s.blockRepo.Delete(ctx, blockerID, blockedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("block %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+	return nil
+}
+
+// GetBlockedUsers gets the blocks a user has made
+func (s *friendshipService) GetBlockedUsers(ctx context.Context, userID uuid.UUID) ([]*models.UserBlock, error) {
+	ctx, span := tracing.Start(ctx, "FriendshipService.GetBlockedUsers")
+	defer span.End()
+
+	blocks, err := s.blockRepo.ListByBlocker(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocks: %w", err)
+	}
+	if blocks == nil {
+		blocks = []*models.UserBlock{}
+	}
+	return blocks, nil
 }
 
-// GetPendingRequests gets pending friend requests for a user
-func (s *friendshipService) GetPendingRequests(ctx context.Context, userID uuid.UUID) ([]*models.Friendship, error) {
-	// TODO: Implement method in repository to get pending requests
-	// For now, return empty slice
-	return []*models.Friendship{}, nil
+// GetSuggestions suggests users to befriend: group-mates and friends of
+// friends the user has no friendship, request or block with
+func (s *friendshipService) GetSuggestions(ctx context.Context, userID uuid.UUID, limit int) ([]*models.FriendSuggestion, error) {
+	ctx, span := tracing.Start(ctx, "FriendshipService.GetSuggestions")
+	defer span.End()
+
+	if limit <= 0 {
+		limit = defaultSuggestionLimit
+	}
+	limit = min(limit, maxSuggestionLimit)
+
+	activeSince := time.Now().Add(-suggestionActiveWindow)
+	suggestions, err := s.friendshipRepo.GetSuggestions(ctx, userID, activeSince, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get friend suggestions: %w", err)
+	}
+	if suggestions == nil {
+		suggestions = []*models.FriendSuggestion{}
+	}
+	return suggestions, nil
+}
+
+// friendshipList wraps a repository list for the API, with no friendships
+// as an empty list
+func friendshipList(friendships []*models.Friendship, err error) ([]*models.Friendship, error) {
+	if err != nil {
+		return nil, fmt.Errorf("failed to list friendships: %w", err)
+	}
+	if friendships == nil {
+		friendships = []*models.Friendship{}
+	}
+	return friendships, nil
 }
 
 // generateRandomString generates a random string of specified length
@@ -300,3 +654,77 @@ func generateRandomString(length int) (string, error) {
 	}
 	return hex.EncodeToString(bytes)[:length], nil
 }
+
+// checkRegistration returns ErrConflict if the email or username of a new
+// account is taken, or ErrInvalidInput if its password breaks the policy.
+// Otherwise it returns the password hash.
+func (s *userService) checkRegistration(ctx context.Context, req *models.CreateUserRequest) (string, error) {
+	// Check if email already exists
+	existingUser, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		return "", fmt.Errorf("failed to check existing email: %w", err)
+	}
+	if existingUser != nil {
+		return "", fmt.Errorf("email %w", ErrConflict)
+	}
+
+	// Check if username already exists
+	existingUser, err = s.userRepo.GetByUsername(ctx, req.Username)
+	if err != nil {
+		return "", fmt.Errorf("failed to check existing username: %w", err)
+	}
+	if existingUser != nil {
+		return "", fmt.Errorf("username %w", ErrConflict)
+	}
+
+	if err := s.checkPasswordPolicy(req.Password, req.Username); err != nil {
+		return "", err
+	}
+
+	return s.hashPassword(req.Password)
+}
+
+// checkPasswordPolicy returns ErrInvalidInput, with the reason, if a new
+// password breaks the configured policy
+func (s *userService) checkPasswordPolicy(newPassword, username string) error {
+	if err := s.policy.Check(newPassword, username); err != nil {
+		return fmt.Errorf("%w: %w", err, ErrInvalidInput)
+	}
+	return nil
+}
+
+// rehashPassword upgrades a user's password hash, after a successful sign-in,
+// if the configured cost has changed since it was made
+func (s *userService) rehashPassword(ctx context.Context, user *models.User, password string) {
+	cost, err := bcrypt.Cost([]byte(user.PasswordHash))
+	if err != nil || cost == s.password.BcryptCost {
+		return
+	}
+	if hashed, err := s.hashPassword(password); err != nil {
+		log.Printf("Failed to rehash password for user %s: %v", user.ID, err)
+	} else if err := s.userRepo.UpdatePasswordHash(ctx, user.ID, hashed); err != nil {
+		log.Printf("Failed to rehash password for user %s: %v", user.ID, err)
+	} else {
+		user.PasswordHash = hashed
+	}
+}
+
+// checkPassword reports whether password is the user's. Without a user, or
+// for a user with no password, it compares against a dummy hash so a failed
+// sign-in takes as long either way.
+func (s *userService) checkPassword(user *models.User, password string) bool {
+	if user == nil || user.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(s.dummyHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+// hashPassword hashes a password at the configured bcrypt cost
+func (s *userService) hashPassword(plain string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), s.password.BcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hashed), nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/internal/models"
+	"github.com/andy-dam/iq-theory/server/pkg/mailer"
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+// SendVerificationEmail emails the user a new verification link. Requests
+// are throttled to one per resend interval.
+func (s *userService) SendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "UserService.SendVerificationEmail")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+	if user.EmailVerified {
+		return fmt.Errorf("email address already verified: %w", ErrConflict)
+	}
+
+	latest, err := s.userTokenRepo.GetLatest(ctx, userID, models.TokenPurposeEmailVerification)
+	if err != nil {
+		return fmt.Errorf("failed to get latest verification token: %w", err)
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < s.account.VerificationResendInterval {
+		return fmt.Errorf("verification email sent recently, try again later: %w", ErrTooManyRequests)
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// ConfirmEmail redeems a verification token and marks the owner's email as
+// verified. Any other outstanding verification links are invalidated.
+func (s *userService) ConfirmEmail(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ConfirmEmail")
+	defer span.End()
+
+	stored, err := s.userTokenRepo.GetByHash(ctx, models.TokenPurposeEmailVerification, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to get verification token: %w", err)
+	}
+	if stored == nil || stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return ErrInvalidToken
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userTokenRepo.MarkUsed(ctx, stored.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("failed to use verification token: %w", err)
+		}
+		if err := s.userTokenRepo.InvalidateAll(ctx, stored.UserID, models.TokenPurposeEmailVerification); err != nil {
+			return fmt.Errorf("failed to invalidate verification tokens: %w", err)
+		}
+		return s.VerifyEmail(ctx, stored.UserID)
+	})
+}
+
+// sendVerificationEmail issues a verification token and mails the link
+func (s *userService) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := s.issueToken(ctx, user.ID, models.TokenPurposeEmailVerification, s.account.VerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.account.AppURL + "/verify-email?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your IQ Theory email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %s. If you did not create an IQ Theory account, you can ignore this email.\n",
+			user.DisplayName, link, formatDuration(s.account.VerificationTokenTTL)),
+	})
+}
+
+// issueToken creates a single-use token for the user and returns the raw
+// value to send. Only its hash is stored.
+func (s *userService) issueToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.userTokenRepo.Create(ctx, &models.UserToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return token, nil
+}
+
+// randomToken returns a URL-safe random token
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken returns the hex SHA-256 of a raw token, as stored in user_tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// formatDuration renders a token lifetime for use in email text
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return pluralize(int(d/(24*time.Hour)), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return pluralize(int(d/time.Hour), "hour")
+	case d >= time.Minute && d%time.Minute == 0:
+		return pluralize(int(d/time.Minute), "minute")
+	default:
+		return d.String()
+	}
+}
+
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
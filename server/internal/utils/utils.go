@@ -1,9 +1,31 @@
 package utils
 
-// TODO: Implement internal utility functions
-// These are helper functions used throughout your application
-// Examples:
-// - Validation helpers
-// - String manipulation
-// - Time/date utilities
-// - Response formatting helpers
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+)
+
+// WriteJSON writes v as a JSON response with the given status code
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// WriteError writes an error response in the API's {"error": "..."} format
+func WriteError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, map[string]string{"error": message})
+}
+
+// ClientIP returns the IP address of the client that sent the request
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
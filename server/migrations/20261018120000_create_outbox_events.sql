@@ -0,0 +1,24 @@
+-- Transactional outbox for domain events (QuizCompleted, FriendRequestSent,
+-- MemberJoinedGroup, ...). Events are inserted in the same transaction as the
+-- change they describe and delivered asynchronously by the dispatcher.
+CREATE TABLE outbox_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_type VARCHAR(100) NOT NULL,
+    aggregate_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_outbox_events_aggregate ON outbox_events(aggregate_id);
+
+-- Leaderboards are now refreshed by a QuizCompleted subscriber instead of
+-- inside the completing UPDATE.
+DROP TRIGGER IF EXISTS quiz_completion_trigger ON quiz_sessions;
+DROP FUNCTION IF EXISTS trigger_refresh_leaderboards();
@@ -0,0 +1,14 @@
+-- Feature flags with boolean/percentage rollouts and targeting by user,
+-- group or site role. A disabled flag is off for everyone; explicit targets
+-- are always on; everyone else falls into the percentage rollout.
+CREATE TABLE feature_flags (
+    key VARCHAR(100) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    rollout_percentage INTEGER NOT NULL DEFAULT 0 CHECK (rollout_percentage >= 0 AND rollout_percentage <= 100),
+    target_user_ids UUID[] NOT NULL DEFAULT '{}',
+    target_group_ids UUID[] NOT NULL DEFAULT '{}',
+    target_roles TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- Access tokens issued before tokens_valid_after are rejected. A password
+-- reset moves it forward to sign the user out everywhere.
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP WITH TIME ZONE;
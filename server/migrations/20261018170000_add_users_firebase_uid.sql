@@ -0,0 +1,3 @@
+-- Links users to their Firebase Authentication account so Firebase ID tokens
+-- from the web client are accepted by the API
+ALTER TABLE users ADD COLUMN firebase_uid VARCHAR(128) UNIQUE;
@@ -0,0 +1,35 @@
+-- TOTP two-factor authentication. The secret is stored encrypted; it is
+-- pending until the user confirms enrolment with a first code.
+CREATE TABLE user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    -- last_used_step stops a code from being used twice
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Single-use recovery codes for when the authenticator is lost. Only hashes
+-- are stored.
+CREATE TABLE mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(user_id, code_hash)
+);
+
+-- Second login step: issued after the password (or OIDC) check succeeds and
+-- redeemed with a code
+CREATE TABLE mfa_challenges (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_mfa_challenges_expires ON mfa_challenges(expires_at);
+
+ALTER TABLE groups ADD COLUMN require_admin_2fa BOOLEAN NOT NULL DEFAULT false;
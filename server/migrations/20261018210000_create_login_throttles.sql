@@ -0,0 +1,14 @@
+-- Failed sign-in counters for brute-force protection, keyed by the email
+-- tried (account) or by client IP. Each lockout doubles the next one until
+-- the counter is forgotten after a quiet period.
+CREATE TABLE login_throttles (
+    scope VARCHAR(16) NOT NULL CHECK (scope IN ('account', 'ip')),
+    subject TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (scope, subject)
+);
+
+CREATE INDEX idx_login_throttles_last_failure ON login_throttles(last_failure_at);
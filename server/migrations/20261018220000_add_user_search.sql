@@ -0,0 +1,9 @@
+-- Case-insensitive fuzzy search on usernames and display names
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_users_username_trgm ON users USING gin (lower(username) gin_trgm_ops);
+CREATE INDEX idx_users_display_name_trgm ON users USING gin (lower(display_name) gin_trgm_ops);
+
+-- Profile stats and streaks read completed sessions per user
+CREATE INDEX idx_quiz_sessions_user_completed ON quiz_sessions(user_id, completed_at)
+    WHERE status = 'completed';
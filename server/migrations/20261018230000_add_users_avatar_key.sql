@@ -0,0 +1,3 @@
+-- Storage key of an uploaded avatar's largest rendition. NULL when the user
+-- has no avatar or it comes from a sign-in provider's picture.
+ALTER TABLE users ADD COLUMN avatar_key VARCHAR(255);
@@ -0,0 +1,10 @@
+-- Site-wide role: student (the default), teacher or admin
+ALTER TABLE users ADD COLUMN site_role VARCHAR(20) NOT NULL DEFAULT 'student'
+    CHECK (site_role IN ('student', 'teacher', 'admin'));
+
+-- Creating groups now needs the teacher role, so keep it for everyone who
+-- has already created one
+UPDATE users SET site_role = 'teacher'
+WHERE id IN (SELECT created_by FROM groups);
+
+CREATE INDEX idx_users_site_role ON users(site_role) WHERE site_role <> 'student';
@@ -0,0 +1,17 @@
+-- Per-user quiz defaults and display preferences. A missing row means every
+-- preference is at its default.
+CREATE TABLE user_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    -- Quiz defaults used when a new session omits them
+    default_clef VARCHAR(20) REFERENCES clef_types(name),
+    default_duration_seconds INTEGER REFERENCES duration_options(duration_seconds),
+    default_max_ledger_lines INTEGER REFERENCES ledger_line_options(max_lines),
+    note_naming VARCHAR(20) NOT NULL DEFAULT 'english'
+        CHECK (note_naming IN ('english', 'german', 'solfege')),
+    answer_input VARCHAR(20) NOT NULL DEFAULT 'buttons'
+        CHECK (answer_input IN ('buttons', 'piano', 'keyboard')),
+    sound_enabled BOOLEAN NOT NULL DEFAULT true,
+    -- IANA time zone name, e.g. Europe/London
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,38 @@
+-- Guests are server-issued anonymous accounts that can play quizzes before
+-- signing up. They have placeholder credentials and stay off leaderboards.
+ALTER TABLE users ADD COLUMN is_guest BOOLEAN NOT NULL DEFAULT false;
+
+-- Stale guests are found by age and deleted in the background
+CREATE INDEX idx_users_guest_created_at ON users(created_at) WHERE is_guest;
+
+DROP MATERIALIZED VIEW leaderboards;
+
+CREATE MATERIALIZED VIEW leaderboards AS
+SELECT 
+    qs.clef,
+    qs.duration_seconds,
+    qs.max_ledger_lines,
+    CONCAT(
+        (SELECT display_name FROM clef_types WHERE name = qs.clef), 
+        ' - ', 
+        (SELECT display_name FROM duration_options WHERE duration_seconds = qs.duration_seconds),
+        ' - ',
+        (SELECT display_name FROM ledger_line_options WHERE max_lines = qs.max_ledger_lines)
+    ) as quiz_name,
+    u.id as user_id,
+    u.username,
+    u.display_name,
+    MAX(qs.score) as best_score,
+    MAX(qs.accuracy_percentage) as best_accuracy,
+    MIN(qs.time_taken_seconds) as fastest_time,
+    COUNT(qs.id) as total_attempts,
+    AVG(qs.score) as average_score,
+    MAX(qs.completed_at) as last_attempt,
+    RANK() OVER (
+        PARTITION BY qs.clef, qs.duration_seconds, qs.max_ledger_lines 
+        ORDER BY MAX(qs.score) DESC, MIN(qs.time_taken_seconds) ASC
+    ) as global_rank
+FROM quiz_sessions qs
+JOIN users u ON qs.user_id = u.id
+WHERE qs.status = 'completed' AND NOT u.is_guest
+GROUP BY qs.clef, qs.duration_seconds, qs.max_ledger_lines, u.id, u.username, u.display_name;
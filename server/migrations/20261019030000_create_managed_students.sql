@@ -0,0 +1,17 @@
+-- Student accounts created by a teacher from a group roster. They may have
+-- no email address, and the managing group's admins reset their
+-- credentials instead of an emailed link.
+ALTER TABLE users ADD COLUMN managed_by_group_id UUID REFERENCES groups(id) ON DELETE SET NULL;
+-- Set for generated initial passwords until the student picks their own
+ALTER TABLE users ADD COLUMN password_change_required BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_users_managed_by_group ON users(managed_by_group_id) WHERE managed_by_group_id IS NOT NULL;
+
+-- Printable login codes that sign a managed student in without a username
+-- or password. Only hashes are stored; a student has at most one code.
+CREATE TABLE student_login_codes (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE
+);
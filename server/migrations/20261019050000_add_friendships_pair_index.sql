@@ -0,0 +1,16 @@
+-- A pair of users has at most one friendship row, whichever of them sent
+-- the request. Where both sent one, keep the most settled row.
+DELETE FROM friendships f
+USING friendships g
+WHERE f.requester_id = g.addressee_id
+  AND f.addressee_id = g.requester_id
+  AND (
+      CASE f.status WHEN 'blocked' THEN 3 WHEN 'accepted' THEN 2 WHEN 'pending' THEN 1 ELSE 0 END,
+      g.created_at, g.id
+  ) < (
+      CASE g.status WHEN 'blocked' THEN 3 WHEN 'accepted' THEN 2 WHEN 'pending' THEN 1 ELSE 0 END,
+      f.created_at, f.id
+  );
+
+CREATE UNIQUE INDEX idx_friendships_pair
+    ON friendships (LEAST(requester_id, addressee_id), GREATEST(requester_id, addressee_id));
@@ -0,0 +1,4 @@
+-- Friends leaderboards look up a few hundred users' entries for one quiz
+-- configuration at a time
+CREATE INDEX idx_leaderboards_user_config
+    ON leaderboards(user_id, clef, duration_seconds, max_ledger_lines);
@@ -0,0 +1,20 @@
+-- Notable events shown in friends' activity feeds, written by event
+-- subscribers and read per friend set. dedupe_key makes redelivered events
+-- no-ops.
+CREATE TABLE user_activities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(30) NOT NULL
+        CHECK (kind IN ('personal_best', 'streak_milestone', 'joined_group')),
+    dedupe_key VARCHAR(100) NOT NULL,
+    -- Set for joined_group so the activity goes when the group does
+    group_id UUID REFERENCES groups(id) ON DELETE CASCADE,
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, kind, dedupe_key)
+);
+
+CREATE INDEX idx_user_activities_feed ON user_activities(user_id, created_at DESC, id DESC);
+
+-- Users can keep their activity out of their friends' feeds
+ALTER TABLE user_preferences ADD COLUMN share_activity BOOLEAN NOT NULL DEFAULT true;
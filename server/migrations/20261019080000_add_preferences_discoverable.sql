@@ -0,0 +1,2 @@
+-- Users can keep themselves out of other users' friend suggestions
+ALTER TABLE user_preferences ADD COLUMN discoverable BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,19 @@
+-- Blocks are one-way and kept apart from friendships, so two users can
+-- block each other and lifting one block leaves the other in place
+CREATE TABLE user_blocks (
+    blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked ON user_blocks (blocked_id);
+
+-- Move blocks stored as friendships, where the requester was the blocker
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+SELECT requester_id, addressee_id, updated_at
+FROM friendships
+WHERE status = 'blocked';
+
+DELETE FROM friendships WHERE status = 'blocked';
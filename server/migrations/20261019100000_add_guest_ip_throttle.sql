@@ -0,0 +1,5 @@
+-- login_throttles also counts guest accounts created per client IP, so the
+-- unauthenticated guest endpoint cannot fill the users table
+ALTER TABLE login_throttles DROP CONSTRAINT login_throttles_scope_check;
+ALTER TABLE login_throttles ADD CONSTRAINT login_throttles_scope_check
+    CHECK (scope IN ('account', 'ip', 'guest_ip'));
@@ -0,0 +1,46 @@
+-- Purged accounts are anonymized rather than deleted, so their quiz results
+-- stay in the aggregate statistics but drop off leaderboards
+DROP MATERIALIZED VIEW leaderboards;
+
+CREATE MATERIALIZED VIEW leaderboards AS
+SELECT 
+    qs.clef,
+    qs.duration_seconds,
+    qs.max_ledger_lines,
+    CONCAT(
+        (SELECT display_name FROM clef_types WHERE name = qs.clef), 
+        ' - ', 
+        (SELECT display_name FROM duration_options WHERE duration_seconds = qs.duration_seconds),
+        ' - ',
+        (SELECT display_name FROM ledger_line_options WHERE max_lines = qs.max_ledger_lines)
+    ) as quiz_name,
+    u.id as user_id,
+    u.username,
+    u.display_name,
+    MAX(qs.score) as best_score,
+    MAX(qs.accuracy_percentage) as best_accuracy,
+    MIN(qs.time_taken_seconds) as fastest_time,
+    COUNT(qs.id) as total_attempts,
+    AVG(qs.score) as average_score,
+    MAX(qs.completed_at) as last_attempt,
+    RANK() OVER (
+        PARTITION BY qs.clef, qs.duration_seconds, qs.max_ledger_lines 
+        ORDER BY MAX(qs.score) DESC, MIN(qs.time_taken_seconds) ASC
+    ) as global_rank
+FROM quiz_sessions qs
+JOIN users u ON qs.user_id = u.id
+WHERE qs.status = 'completed' AND NOT u.is_guest AND u.deleted_at IS NULL
+GROUP BY qs.clef, qs.duration_seconds, qs.max_ledger_lines, u.id, u.username, u.display_name;
+
+-- Dropped with the old view. Each row is one user's results for one quiz
+-- configuration, so the friends leaderboard index can be unique, which lets
+-- the view be refreshed concurrently without locking out reads.
+CREATE UNIQUE INDEX idx_leaderboards_user_config
+    ON leaderboards(user_id, clef, duration_seconds, max_ledger_lines);
+
+CREATE OR REPLACE FUNCTION refresh_leaderboards()
+RETURNS void AS $$
+BEGIN
+    REFRESH MATERIALIZED VIEW CONCURRENTLY leaderboards;
+END;
+$$ LANGUAGE plpgsql;
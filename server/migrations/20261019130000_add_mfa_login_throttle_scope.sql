@@ -0,0 +1,5 @@
+-- login_throttles also counts wrong two-factor codes per user, so a stolen
+-- session cannot guess its way to disabling 2FA
+ALTER TABLE login_throttles DROP CONSTRAINT login_throttles_scope_check;
+ALTER TABLE login_throttles ADD CONSTRAINT login_throttles_scope_check
+    CHECK (scope IN ('account', 'ip', 'guest_ip', 'mfa'));
@@ -0,0 +1,4 @@
+-- Blocks live in user_blocks, so 'blocked' is no longer a friendship status
+ALTER TABLE friendships DROP CONSTRAINT friendships_status_check;
+ALTER TABLE friendships ADD CONSTRAINT friendships_status_check
+    CHECK (status IN ('pending', 'accepted', 'declined'));
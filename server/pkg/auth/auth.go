@@ -1,8 +1,4 @@
+// Package auth provides token generation and validation for API clients, and
+// verification of ID tokens issued by external identity providers such as
+// Firebase.
 package auth
-
-// TODO: Implement authentication utilities
-// This might include:
-// - JWT token generation/validation
-// - Password hashing/verification
-// - Session management
-// - OAuth integration helpers
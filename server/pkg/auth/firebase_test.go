@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testProject = "iq-theory-test"
+
+// testKey is shared by the tests because generating RSA keys is slow
+var testKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+func testJWKS(kid string, key *rsa.PublicKey) []byte {
+	data, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	return data
+}
+
+func testCertificates(t *testing.T, kid string, key *rsa.PrivateKey) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "securetoken.system.gserviceaccount.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	data, _ := json.Marshal(map[string]string{
+		kid: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	})
+	return data
+}
+
+// signRS256 builds a token with the given header and claims signed by key
+func signRS256(t *testing.T, header map[string]string, claims any, key *rsa.PrivateKey) string {
+	t.Helper()
+	h, err := encodeSegment(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := encodeSegment(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(h + "." + p))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h + "." + p + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestParseKeySet(t *testing.T) {
+	encOnly, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB",
+	}}})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantKid string
+		wantErr bool
+	}{
+		{"jwks", testJWKS("k1", &testKey.PublicKey), "k1", false},
+		{"certificate map", testCertificates(t, "k2", testKey), "k2", false},
+		{"empty jwks", []byte(`{"keys": []}`), "", true},
+		{"only encryption keys", encOnly, "", true},
+		{"certificate not PEM", []byte(`{"k3": "not a certificate"}`), "", true},
+		{"not JSON", []byte(`keys`), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeySet(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKeySet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			key, ok := keys[tt.wantKid]
+			if !ok || !key.Equal(&testKey.PublicKey) {
+				t.Errorf("ParseKeySet() = %v, want the test key under %q", keys, tt.wantKid)
+			}
+		})
+	}
+}
+
+func TestFirebaseVerifierVerify(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "firebase-keys.json")
+	if err := os.WriteFile(keyFile, testJWKS("k1", &testKey.PublicKey), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewFirebaseVerifier(testProject, keyFile)
+	if err != nil {
+		t.Fatalf("NewFirebaseVerifier: %v", err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	claims := func(modify func(c map[string]any)) map[string]any {
+		c := map[string]any{
+			"iss":       "https://securetoken.google.com/" + testProject,
+			"aud":       testProject,
+			"sub":       "firebase-uid",
+			"iat":       now.Add(-time.Minute).Unix(),
+			"exp":       now.Add(time.Hour).Unix(),
+			"auth_time": now.Add(-time.Minute).Unix(),
+			"email":     "player@example.com",
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+	rs256 := map[string]string{"alg": "RS256", "kid": "k1"}
+	valid := signRS256(t, rs256, claims(nil), testKey)
+	parts := strings.Split(valid, ".")
+	otherPayload, _ := encodeSegment(claims(func(c map[string]any) { c["sub"] = "someone-else" }))
+	hs256, _ := NewTokenManager("secret", time.Hour).sign(claims(nil))
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", valid, nil},
+		{"unknown kid", signRS256(t, map[string]string{"alg": "RS256", "kid": "k2"}, claims(nil), testKey), ErrInvalidToken},
+		{"signed by another key", signRS256(t, rs256, claims(nil), otherKey), ErrInvalidToken},
+		{"payload swapped", parts[0] + "." + otherPayload + "." + parts[2], ErrInvalidToken},
+		{"HS256", hs256, ErrInvalidToken},
+		{"other project issuer", signRS256(t, rs256, claims(func(c map[string]any) {
+			c["iss"] = "https://securetoken.google.com/other"
+		}), testKey), ErrInvalidToken},
+		{"other project audience", signRS256(t, rs256, claims(func(c map[string]any) { c["aud"] = "other" }), testKey), ErrInvalidToken},
+		{"no subject", signRS256(t, rs256, claims(func(c map[string]any) { c["sub"] = "" }), testKey), ErrInvalidToken},
+		{"issued in the future", signRS256(t, rs256, claims(func(c map[string]any) {
+			c["iat"] = now.Add(time.Hour).Unix()
+		}), testKey), ErrInvalidToken},
+		{"authenticated in the future", signRS256(t, rs256, claims(func(c map[string]any) {
+			c["auth_time"] = now.Add(time.Hour).Unix()
+		}), testKey), ErrInvalidToken},
+		{"expired", signRS256(t, rs256, claims(func(c map[string]any) {
+			c["exp"] = now.Add(-time.Second).Unix()
+		}), testKey), ErrExpiredToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.want)
+			}
+			if err == nil && (got.Subject != "firebase-uid" || got.Email != "player@example.com") {
+				t.Errorf("Verify() = %+v, want the token's claims", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTokenManagerRoundTrip(t *testing.T) {
+	m := NewTokenManager("secret", time.Hour)
+	userID, sessionID := uuid.New(), uuid.New()
+
+	token, expiresAt, err := m.Generate(userID, sessionID)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if d := time.Until(expiresAt); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expiresAt is %s away, want about an hour", d)
+	}
+
+	claims, err := m.Validate(token)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if got, err := claims.UserID(); err != nil || got != userID {
+		t.Errorf("UserID() = %s, %v; want %s", got, err, userID)
+	}
+	if got, err := claims.SessionID(); err != nil || got != sessionID {
+		t.Errorf("SessionID() = %s, %v; want %s", got, err, sessionID)
+	}
+}
+
+func TestTokenManagerValidate(t *testing.T) {
+	m := NewTokenManager("secret", time.Hour)
+	valid, _, err := m.Generate(uuid.New(), uuid.New())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	parts := strings.Split(valid, ".")
+
+	otherKey, _, _ := NewTokenManager("other", time.Hour).Generate(uuid.New(), uuid.New())
+	expired, _, _ := NewTokenManager("secret", -time.Minute).Generate(uuid.New(), uuid.New())
+	noneHeader, _ := encodeSegment(map[string]string{"alg": "none", "typ": "JWT"})
+	otherPayload, _ := encodeSegment(&Claims{Subject: uuid.NewString(), ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid", valid, nil},
+		{"empty", "", ErrInvalidToken},
+		{"two segments", parts[0] + "." + parts[1], ErrInvalidToken},
+		{"signed with another secret", otherKey, ErrInvalidToken},
+		{"payload swapped", parts[0] + "." + otherPayload + "." + parts[2], ErrInvalidToken},
+		{"alg none", noneHeader + "." + parts[1] + ".", ErrInvalidToken},
+		{"alg none keeping the signature", noneHeader + "." + parts[1] + "." + parts[2], ErrInvalidToken},
+		{"signature not base64", parts[0] + "." + parts[1] + ".!!!", ErrInvalidToken},
+		{"expired", expired, ErrExpiredToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Validate(tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -5,20 +5,97 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/andy-dam/iq-theory/server/internal/config"
 	_ "github.com/lib/pq"
 )
 
+// DB is the primary connection pool plus any read replicas. The pools are
+// not exposed, so every query goes through the timeout, tracing and routing
+// in query.go.
 type DB struct {
-	*sql.DB
+	primary *sql.DB
+
+	replicas    []*replica
+	nextReplica atomic.Uint32
+	recentWrite *writeTracker
+
+	queryTimeout       time.Duration
+	slowQueryThreshold time.Duration
+
+	stopHealthCheck chan struct{}
+	closeOnce       sync.Once
+}
+
+// replica is a read-only connection pool that is skipped while unhealthy
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
 }
 
 // New creates a new database connection
 func New(cfg *config.DatabaseConfig) (*DB, error) {
+	db, err := open(cfg, cfg.Host, cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	log.Println("Successfully connected to database")
+
+	database := &DB{
+		primary:            db,
+		recentWrite:        newWriteTracker(cfg.ReadYourWritesWindow),
+		queryTimeout:       cfg.QueryTimeout,
+		slowQueryThreshold: cfg.SlowQueryThreshold,
+		stopHealthCheck:    make(chan struct{}),
+	}
+
+	for _, hostPort := range cfg.ReplicaHosts {
+		host, port, err := splitHostPort(hostPort, cfg.Port)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("invalid replica address %q: %w", hostPort, err)
+		}
+
+		replicaDB, err := open(cfg, host, port)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("failed to open replica %s: %w", hostPort, err)
+		}
+
+		r := &replica{name: hostPort, db: replicaDB}
+		// A replica that is down at startup is not fatal; reads fall back
+		// to the primary until the health check sees it recover.
+		r.healthy.Store(replicaDB.Ping() == nil)
+		if !r.healthy.Load() {
+			log.Printf("Read replica %s is unreachable, routing reads to primary", hostPort)
+		}
+		database.replicas = append(database.replicas, r)
+	}
+
+	if len(database.replicas) > 0 {
+		log.Printf("Configured %d read replica(s)", len(database.replicas))
+		go database.monitorReplicas(cfg.ReplicaHealthInterval)
+	}
+
+	return database, nil
+}
+
+// open creates a connection pool for a single host using the shared settings
+func open(cfg *config.DatabaseConfig, host string, port int) (*sql.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
+		host, port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -26,22 +103,41 @@ func New(cfg *config.DatabaseConfig) (*DB, error) {
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	return db, nil
+}
+
+// splitHostPort parses host or host:port, falling back to defaultPort
+func splitHostPort(hostPort string, defaultPort int) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// No port given
+		return hostPort, defaultPort, nil
 	}
 
-	log.Println("Successfully connected to database")
-	return &DB{db}, nil
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, err
+	}
+	return host, port, nil
 }
 
-// Close closes the database connection
+// Close closes the primary and replica connections
 func (db *DB) Close() error {
-	return db.DB.Close()
+	db.closeOnce.Do(func() {
+		close(db.stopHealthCheck)
+	})
+
+	for _, r := range db.replicas {
+		if err := r.db.Close(); err != nil {
+			log.Printf("Failed to close read replica %s: %v", r.name, err)
+		}
+	}
+	return db.primary.Close()
 }
 
 // HealthCheck checks if the database is healthy
@@ -49,7 +145,34 @@ func (db *DB) HealthCheck() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	return db.PingContext(ctx)
+	return db.primary.PingContext(ctx)
+}
+
+// monitorReplicas pings every replica on an interval and updates its health
+func (db *DB) monitorReplicas(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stopHealthCheck:
+			return
+		case <-ticker.C:
+			for _, r := range db.replicas {
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				healthy := r.db.PingContext(ctx) == nil
+				cancel()
+
+				if was := r.healthy.Swap(healthy); was != healthy {
+					if healthy {
+						log.Printf("Read replica %s recovered", r.name)
+					} else {
+						log.Printf("Read replica %s is unhealthy, routing reads to primary", r.name)
+					}
+				}
+			}
+		}
+	}
 }
 
 // Migrate runs database migrations (placeholder for now)
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/pkg/tracing"
+)
+
+// Rows wraps *sql.Rows so the per-query timeout is released and the query
+// span ends when the caller closes the result set. The span then covers
+// reading the rows as well as running the query.
+type Rows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+	span   *tracing.Span
+}
+
+// Close closes the result set, ends the query span and releases the query
+// context
+func (r *Rows) Close() error {
+	defer r.cancel()
+	err := r.Rows.Close()
+	r.span.RecordError(r.Rows.Err())
+	r.span.End()
+	return err
+}
+
+// Row wraps *sql.Row so the per-query timeout is released and the query
+// span ends after Scan.
+type Row struct {
+	*sql.Row
+	cancel context.CancelFunc
+	span   *tracing.Span
+}
+
+// Scan copies the row into dest, ends the query span and releases the
+// query context
+func (r *Row) Scan(dest ...any) error {
+	defer r.cancel()
+	err := r.Row.Scan(dest...)
+	if !errors.Is(err, sql.ErrNoRows) {
+		r.span.RecordError(err)
+	}
+	r.span.End()
+	return err
+}
+
+// ExecContext executes a query without returning rows on the primary, or
+// on the transaction carried by ctx. It applies the default query timeout,
+// traces the query and logs it when slow.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := tracing.Start(ctx, "SQL exec")
+	defer span.End()
+
+	queryCtx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	t := db.writer(ctx)
+	start := time.Now()
+	result, err := t.db.ExecContext(queryCtx, query, args...)
+	db.observe(span, t.name, query, start, err)
+
+	if err == nil {
+		db.recentWrite.record(ctx)
+	}
+	return result, err
+}
+
+// QueryContext executes a query that returns rows. It applies the default
+// query timeout, traces the query and logs it when slow. Read-only queries
+// may be served by a replica. The caller must close the rows.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	ctx, span := tracing.Start(ctx, "SQL query")
+
+	queryCtx, cancel := db.queryContext(ctx)
+
+	t := db.reader(ctx)
+	start := time.Now()
+	rows, err := t.db.QueryContext(queryCtx, query, args...)
+	db.observe(span, t.name, query, start, err)
+
+	if t.fallBack(queryCtx, err) {
+		span.SetAttribute("db.fallback", true)
+		start = time.Now()
+		rows, err = db.primary.QueryContext(queryCtx, query, args...)
+		db.observe(span, "primary", query, start, err)
+	}
+
+	if err != nil {
+		cancel()
+		span.End()
+		return nil, err
+	}
+	return &Rows{Rows: rows, cancel: cancel, span: span}, nil
+}
+
+// QueryRowContext executes a query that returns at most one row. It applies
+// the default query timeout, traces the query and logs it when slow.
+// Read-only queries may be served by a replica. The caller must call Scan.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	ctx, span := tracing.Start(ctx, "SQL query")
+
+	queryCtx, cancel := db.queryContext(ctx)
+
+	t := db.reader(ctx)
+	start := time.Now()
+	row := t.db.QueryRowContext(queryCtx, query, args...)
+	db.observe(span, t.name, query, start, row.Err())
+
+	if t.fallBack(queryCtx, row.Err()) {
+		span.SetAttribute("db.fallback", true)
+		start = time.Now()
+		row = db.primary.QueryRowContext(queryCtx, query, args...)
+		db.observe(span, "primary", query, start, row.Err())
+	}
+
+	return &Row{Row: row, cancel: cancel, span: span}
+}
+
+// queryContext bounds ctx by the configured default query timeout.
+// A shorter deadline already present on ctx still wins.
+func (db *DB) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// observe annotates the query span and logs the query if it took longer
+// than the slow-query threshold
+func (db *DB) observe(span *tracing.Span, target, query string, start time.Time, err error) {
+	elapsed := time.Since(start)
+
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.target", target)
+	span.SetAttribute("db.statement", Fingerprint(query))
+	if !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+	}
+
+	if db.slowQueryThreshold <= 0 || elapsed < db.slowQueryThreshold {
+		return
+	}
+	log.Printf("Slow query (%s) on %s from %s: %s", elapsed.Round(time.Millisecond), target, caller(), Fingerprint(query))
+}
+
+var (
+	stringLiteralPattern  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numericLiteralPattern = regexp.MustCompile(`(^|[^$\w.])\d+(?:\.\d+)?`)
+	whitespacePattern     = regexp.MustCompile(`\s+`)
+)
+
+// Fingerprint normalizes a SQL statement so that queries differing only in
+// literal values or formatting produce the same string
+func Fingerprint(query string) string {
+	fp := stringLiteralPattern.ReplaceAllString(query, "?")
+	fp = numericLiteralPattern.ReplaceAllString(fp, "${1}?")
+	fp = whitespacePattern.ReplaceAllString(fp, " ")
+	return strings.TrimSpace(fp)
+}
+
+// caller returns the first stack frame outside of this package, which is
+// normally the repository method that issued the query
+func caller() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.Function, "/pkg/database.") {
+			function := frame.Function[strings.LastIndex(frame.Function, "/")+1:]
+			return fmt.Sprintf("%s (%s:%d)", function, filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
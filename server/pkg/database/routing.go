@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+type contextKey int
+
+const (
+	readOnlyKey contextKey = iota
+	userKey
+	txKey
+)
+
+// ReadOnly marks queries issued with the returned context as safe to serve
+// from a read replica. Use it for reads that tolerate replication lag, such
+// as leaderboards and quiz history.
+func ReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey, true)
+}
+
+// WithUser records which user is acting on ctx. Writes made with that user
+// pin the user's subsequent read-only queries to the primary for the
+// read-your-writes window. The auth middleware calls it for every
+// authenticated request.
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey, userID)
+}
+
+func isReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey).(bool)
+	return readOnly
+}
+
+func userFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userKey).(string)
+	return userID
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// target is the connection pool or transaction chosen for a query
+type target struct {
+	name    string
+	db      querier
+	replica *replica
+}
+
+// writer picks the transaction on ctx if there is one, otherwise the primary
+func (db *DB) writer(ctx context.Context) target {
+	if tx, ok := ctx.Value(txKey).(*sql.Tx); ok {
+		return target{name: "transaction", db: tx}
+	}
+	return target{name: "primary", db: db.primary}
+}
+
+// reader picks the pool for a read query: the transaction on ctx if there
+// is one, a healthy replica in round-robin order when the query is read-only
+// and the user has not written recently, otherwise the primary
+func (db *DB) reader(ctx context.Context) target {
+	primary := db.writer(ctx)
+	if _, inTx := ctx.Value(txKey).(*sql.Tx); inTx || len(db.replicas) == 0 || !isReadOnly(ctx) {
+		return primary
+	}
+	if userID := userFromContext(ctx); userID != "" && db.recentWrite.has(userID) {
+		return primary
+	}
+
+	start := db.nextReplica.Add(1)
+	for i := range db.replicas {
+		r := db.replicas[(int(start)+i)%len(db.replicas)]
+		if r.healthy.Load() {
+			return target{name: "replica " + r.name, db: r.db, replica: r}
+		}
+	}
+	return primary
+}
+
+// fallBack reports whether a failed replica query should be retried on the
+// primary, marking the replica unhealthy when it should
+func (t target) fallBack(ctx context.Context, err error) bool {
+	if t.replica == nil || err == nil || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+		return false
+	}
+	t.replica.healthy.Store(false)
+	return true
+}
+
+// writeTracker remembers when each user last wrote to the primary
+type writeTracker struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	writes map[string]time.Time
+	pruned time.Time
+}
+
+func newWriteTracker(window time.Duration) *writeTracker {
+	return &writeTracker{window: window, writes: make(map[string]time.Time)}
+}
+
+// record notes a write by the user on ctx, if any
+func (w *writeTracker) record(ctx context.Context) {
+	userID := userFromContext(ctx)
+	if userID == "" || w.window <= 0 {
+		return
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writes[userID] = now
+	// Sweep expired entries at most once per window, so the map only holds
+	// users who wrote in the last two windows without every write paying
+	// for a full scan
+	if now.Sub(w.pruned) > w.window {
+		for id, at := range w.writes {
+			if now.Sub(at) > w.window {
+				delete(w.writes, id)
+			}
+		}
+		w.pruned = now
+	}
+}
+
+// has reports whether the user wrote within the window
+func (w *writeTracker) has(userID string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	at, ok := w.writes[userID]
+	if ok && time.Since(at) > w.window {
+		delete(w.writes, userID)
+		return false
+	}
+	return ok
+}
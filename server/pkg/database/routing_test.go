@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReaderReadYourWrites(t *testing.T) {
+	r := &replica{name: "replica-1"}
+	r.healthy.Store(true)
+	db := &DB{replicas: []*replica{r}, recentWrite: newWriteTracker(time.Minute)}
+
+	ada := WithUser(context.Background(), "ada")
+	grace := WithUser(context.Background(), "grace")
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		wantReplica bool
+	}{
+		{"read-only before writing", ReadOnly(ada), true},
+		{"not read-only", ada, false},
+		{"anonymous read-only", ReadOnly(context.Background()), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := db.reader(tt.ctx).replica != nil; got != tt.wantReplica {
+				t.Errorf("reader() used a replica = %v, want %v", got, tt.wantReplica)
+			}
+		})
+	}
+
+	db.recentWrite.record(ada)
+	if db.reader(ReadOnly(ada)).replica != nil {
+		t.Error("read-only query after a write went to a replica, want the primary")
+	}
+	if db.reader(ReadOnly(grace)).replica == nil {
+		t.Error("another user's read-only query went to the primary, want a replica")
+	}
+
+	r.healthy.Store(false)
+	if db.reader(ReadOnly(grace)).replica != nil {
+		t.Error("read-only query went to an unhealthy replica")
+	}
+}
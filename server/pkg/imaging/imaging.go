@@ -0,0 +1,104 @@
+// Package imaging decodes, orients, crops and resizes uploaded PNG and JPEG
+// images. Re-encoding the pixels drops all metadata, including EXIF
+// location data.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// Supported formats
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+)
+
+var (
+	// ErrUnsupportedFormat is returned for anything other than PNG or JPEG
+	ErrUnsupportedFormat = errors.New("image must be a PNG or JPEG")
+	// ErrTooLarge is returned for images with more pixels than allowed
+	ErrTooLarge = errors.New("image dimensions are too large")
+)
+
+// Decode sniffs and decodes a PNG or JPEG, rotating JPEGs upright according
+// to their EXIF orientation. The dimensions are checked against maxPixels
+// before the pixels are decoded, so a small file cannot claim a huge canvas.
+func Decode(data []byte, maxPixels int) (image.Image, string, error) {
+	var format string
+	switch http.DetectContentType(data) {
+	case "image/png":
+		format = FormatPNG
+	case "image/jpeg":
+		format = FormatJPEG
+	default:
+		return nil, "", ErrUnsupportedFormat
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	var img image.Image
+	if format == FormatPNG {
+		img, err = png.Decode(bytes.NewReader(data))
+	} else {
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	if format == FormatJPEG {
+		img = orient(img, jpegOrientation(data))
+	}
+	return img, format, nil
+}
+
+// Square centre-crops the image to a square and scales it to size×size
+func Square(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2))
+
+	src := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(src, src.Bounds(), img, crop.Min, draw.Src)
+	if side == size {
+		return src
+	}
+	return resize(src, size, size)
+}
+
+// Encode writes the image in the given format. JPEGs are encoded at a
+// quality that keeps small avatars sharp.
+func Encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatPNG:
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	case FormatJPEG:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	return "image/" + format
+}
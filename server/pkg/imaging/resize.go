@@ -0,0 +1,88 @@
+package imaging
+
+import "image"
+
+// contribution is one source pixel's weight in a destination pixel
+type contribution struct {
+	index  int
+	weight float32
+}
+
+// resize scales src to w×h with an area-averaging (box) filter, which gives
+// clean results for the large reductions avatars need. It works on
+// premultiplied pixels so transparent edges do not darken.
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	xs := contributions(sw, w)
+	ys := contributions(sh, h)
+
+	// Scale horizontally into a float buffer, then vertically into dst
+	tmp := make([]float32, sh*w*4)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x, cs := range xs {
+			var r, g, b, a float32
+			for _, c := range cs {
+				p := row[c.index*4:]
+				r += float32(p[0]) * c.weight
+				g += float32(p[1]) * c.weight
+				b += float32(p[2]) * c.weight
+				a += float32(p[3]) * c.weight
+			}
+			t := tmp[(y*w+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, b, a
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, cs := range ys {
+		for x := 0; x < w; x++ {
+			var px [4]float32
+			for _, c := range cs {
+				t := tmp[(c.index*w+x)*4:]
+				for i := range px {
+					px[i] += t[i] * c.weight
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			for i := range px {
+				d[i] = clamp(px[i])
+			}
+		}
+	}
+	return dst
+}
+
+// contributions maps each destination pixel to the source pixels it
+// overlaps, weighted by the overlap
+func contributions(srcLen, dstLen int) [][]contribution {
+	scale := float64(srcLen) / float64(dstLen)
+	out := make([][]contribution, dstLen)
+	for i := range out {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		var total float64
+		for j := int(start); j < srcLen && float64(j) < end; j++ {
+			overlap := min(end, float64(j+1)) - max(start, float64(j))
+			if overlap <= 0 {
+				continue
+			}
+			out[i] = append(out[i], contribution{index: j, weight: float32(overlap)})
+			total += overlap
+		}
+		for k := range out[i] {
+			out[i][k].weight /= float32(total)
+		}
+	}
+	return out
+}
+
+func clamp(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return uint8(v + 0.5)
+	}
+}
@@ -0,0 +1,326 @@
+// Package oidc implements the OpenID Connect authorization code flow with
+// PKCE for signing users in through external identity providers.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andy-dam/iq-theory/server/pkg/auth"
+)
+
+const (
+	// jwksRefreshInterval bounds how long signing keys are cached
+	jwksRefreshInterval = time.Hour
+	// clockSkew is the tolerance applied to ID token timestamps
+	clockSkew = time.Minute
+)
+
+var (
+	// ErrInvalidIDToken is returned when an ID token fails verification
+	ErrInvalidIDToken = errors.New("invalid ID token")
+)
+
+// Config describes a provider registration
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Claims are the standard ID token claims used to identify the user
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Audience      audience `json:"aud"`
+	Subject       string   `json:"sub"`
+	IssuedAt      int64    `json:"iat"`
+	ExpiresAt     int64    `json:"exp"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Name          string   `json:"name"`
+	Picture       string   `json:"picture"`
+}
+
+// audience accepts both forms of the aud claim: a string or an array
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// metadata is the subset of the discovery document the flow needs
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider runs the authorization code flow against one OIDC provider.
+// Discovery happens on first use, so an unreachable provider does not stop
+// the server from starting.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu            sync.Mutex
+	meta          *metadata
+	keys          auth.KeySet
+	keysFetchedAt time.Time
+}
+
+// NewProvider creates a provider from its registration
+func NewProvider(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the provider's configured name
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL returns the URL to send the user to. The PKCE challenge is
+// derived from verifier, which must be kept for Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {S256Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return meta.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified claims of
+// the ID token, which must carry the expected nonce
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &tokens)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if status != http.StatusOK || tokens.IDToken == "" {
+		return nil, fmt.Errorf("token request failed with status %d: %s %s", status, tokens.Error, tokens.ErrorDescription)
+	}
+
+	return p.verifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// verifyIDToken checks the ID token's signature, issuer, audience, lifetime
+// and nonce
+func (p *Provider) verifyIDToken(ctx context.Context, token, nonce string) (*Claims, error) {
+	keys, err := p.signingKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	err = auth.VerifyRS256(token, keys, claims)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		// The provider may have rotated its keys since they were cached
+		if keys, err = p.signingKeys(ctx, true); err != nil {
+			return nil, err
+		}
+		err = auth.VerifyRS256(token, keys, claims)
+	}
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != meta.Issuer,
+		!slices.Contains(claims.Audience, p.cfg.ClientID),
+		claims.Subject == "",
+		claims.Nonce != nonce,
+		time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)),
+		!now.Before(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return nil, ErrInvalidIDToken
+	}
+
+	return claims, nil
+}
+
+// discover fetches and caches the provider's discovery document
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	meta := p.meta
+	p.mu.Unlock()
+	if meta != nil {
+		return meta, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	meta = &metadata{}
+	status, err := p.doJSON(req, meta)
+	if err != nil {
+		return nil, fmt.Errorf("discovery for %s failed: %w", p.cfg.Name, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("discovery for %s failed with status %d", p.cfg.Name, status)
+	}
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery for %s returned issuer %q, expected %q", p.cfg.Name, meta.Issuer, p.cfg.Issuer)
+	}
+
+	p.mu.Lock()
+	p.meta = meta
+	p.mu.Unlock()
+	return meta, nil
+}
+
+// signingKeys returns the cached JWKS, fetching it when stale or forced
+func (p *Provider) signingKeys(ctx context.Context, force bool) (auth.KeySet, error) {
+	p.mu.Lock()
+	keys, fetchedAt := p.keys, p.keysFetchedAt
+	p.mu.Unlock()
+	if keys != nil && !force && time.Since(fetchedAt) < jwksRefreshInterval {
+		return keys, nil
+	}
+
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	status, err := p.doJSON(req, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys for %s: %w", p.cfg.Name, err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch signing keys for %s: status %d", p.cfg.Name, status)
+	}
+	keys, err = auth.ParseKeySet(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing keys for %s: %w", p.cfg.Name, err)
+	}
+
+	p.mu.Lock()
+	p.keys, p.keysFetchedAt = keys, time.Now()
+	p.mu.Unlock()
+	return keys, nil
+}
+
+// doJSON sends the request and decodes a JSON response body into v
+func (p *Provider) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return resp.StatusCode, fmt.Errorf("invalid JSON response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// RandomString returns a URL-safe random string for use as state, nonce or
+// PKCE verifier
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// S256Challenge derives the PKCE code challenge from a verifier
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
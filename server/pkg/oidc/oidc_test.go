@@ -0,0 +1,163 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/andy-dam/iq-theory/server/pkg/oidc"
+	"github.com/andy-dam/iq-theory/server/pkg/oidc/oidctest"
+)
+
+// newIssuer serves a fake provider and returns its issuer URL
+func newIssuer(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewUnstartedServer(nil)
+	server.Start()
+	t.Cleanup(server.Close)
+
+	fake, err := oidctest.NewProvider(server.URL)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	server.Config.Handler = fake
+	return server.URL
+}
+
+// authorize runs the authorization step as the fake provider's user with
+// the given email and returns the code and state from the redirect
+func authorize(t *testing.T, p *oidc.Provider, email, state, nonce, verifier string) (string, string) {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL + "&login_hint=" + url.QueryEscape(email))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %s, want a redirect", resp.Status)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	issuer := newIssuer(t)
+	p := oidc.NewProvider(oidc.Config{
+		Name:        "fake",
+		Issuer:      issuer,
+		ClientID:    "iq-theory",
+		RedirectURL: "https://app.example.com/callback",
+	})
+
+	raw, err := p.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	authURL, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned an invalid URL: %v", err)
+	}
+	if got := authURL.Scheme + "://" + authURL.Host + authURL.Path; got != issuer+"/authorize" {
+		t.Errorf("endpoint = %s, want %s/authorize", got, issuer)
+	}
+
+	query := authURL.Query()
+	for param, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             "iq-theory",
+		"redirect_uri":          "https://app.example.com/callback",
+		"scope":                 "openid email profile",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        oidc.S256Challenge("verifier"),
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	issuer := newIssuer(t)
+
+	tests := []struct {
+		name string
+		// issuer the relying party is configured with
+		issuer       string
+		clientSecret string
+		// clientID the code is exchanged as, when not the one it was issued to
+		exchangeClientID string
+		verifier         string
+		nonce            string
+		redeemTwice      bool
+		wantErr          bool
+		wantInvalidToken bool
+	}{
+		{name: "public client", verifier: "verifier", nonce: "nonce"},
+		{name: "confidential client", clientSecret: "secret", verifier: "verifier", nonce: "nonce"},
+		{name: "wrong PKCE verifier", verifier: "other", nonce: "nonce", wantErr: true},
+		{name: "wrong nonce", verifier: "verifier", nonce: "other", wantErr: true, wantInvalidToken: true},
+		{name: "another client's code", exchangeClientID: "other-client", verifier: "verifier", nonce: "nonce", wantErr: true},
+		{name: "code redeemed twice", verifier: "verifier", nonce: "nonce", redeemTwice: true, wantErr: true},
+		{name: "discovery issuer mismatch", issuer: issuer + "/", verifier: "verifier", nonce: "nonce", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := oidc.Config{
+				Name:         "fake",
+				Issuer:       issuer,
+				ClientID:     "iq-theory",
+				ClientSecret: tt.clientSecret,
+				RedirectURL:  "https://app.example.com/callback",
+			}
+			p := oidc.NewProvider(cfg)
+			code, state := authorize(t, p, "Player@Example.com", "state", "nonce", "verifier")
+			if state != "state" {
+				t.Fatalf("redirect state = %q, want state", state)
+			}
+
+			if tt.issuer != "" {
+				cfg.Issuer = tt.issuer
+			}
+			if tt.exchangeClientID != "" {
+				cfg.ClientID = tt.exchangeClientID
+			}
+			p = oidc.NewProvider(cfg)
+
+			if tt.redeemTwice {
+				if _, err := p.Exchange(context.Background(), code, tt.verifier, tt.nonce); err != nil {
+					t.Fatalf("first Exchange: %v", err)
+				}
+			}
+			claims, err := p.Exchange(context.Background(), code, tt.verifier, tt.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, oidc.ErrInvalidIDToken) != tt.wantInvalidToken {
+				t.Errorf("Exchange() error = %v, want ErrInvalidIDToken %v", err, tt.wantInvalidToken)
+			}
+			if err != nil {
+				return
+			}
+			if claims.Subject != "fake|player@example.com" || claims.Email != "Player@Example.com" || !claims.EmailVerified {
+				t.Errorf("Exchange() = %+v, want the fake user's claims", claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Package password checks new passwords against a configurable policy.
+package password
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxLength is the longest password bcrypt can hash, in bytes
+const MaxLength = 72
+
+// minUsernameLength is the shortest username the policy looks for inside a
+// password; shorter ones match too many passwords by accident
+const minUsernameLength = 3
+
+var (
+	// ErrCommon is returned for a password on the common passwords list
+	ErrCommon = errors.New("password is too common")
+	// ErrContainsUsername is returned for a password containing the username
+	ErrContainsUsername = errors.New("password must not contain the username")
+)
+
+//go:embed common.txt
+var commonList string
+
+// common holds the lower-cased blocklist
+var common = parseList(commonList)
+
+// Policy describes what a new password must satisfy
+type Policy struct {
+	MinLength int
+	// BlockCommon rejects passwords on the embedded common passwords list
+	BlockCommon bool
+	// RejectUsername rejects passwords that contain the username
+	RejectUsername bool
+}
+
+// Check returns an error describing the first rule the password breaks
+func (p Policy) Check(password, username string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > MaxLength {
+		return fmt.Errorf("password must be at most %d bytes", MaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	if p.BlockCommon {
+		if _, ok := common[lower]; ok {
+			return ErrCommon
+		}
+	}
+	if p.RejectUsername && len(username) >= minUsernameLength && strings.Contains(lower, strings.ToLower(username)) {
+		return ErrContainsUsername
+	}
+	return nil
+}
+
+// parseList reads one entry per line, skipping blank lines and # comments
+func parseList(list string) map[string]struct{} {
+	entries := make(map[string]struct{})
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries[strings.ToLower(line)] = struct{}{}
+	}
+	return entries
+}
@@ -0,0 +1,55 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy := Policy{MinLength: 8, BlockCommon: true, RejectUsername: true}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		username string
+		wantErr  bool
+		want     error
+	}{
+		{name: "acceptable", policy: policy, password: "treble clef sight reading", username: "ada"},
+		{name: "too short", policy: policy, password: "G#m7b5", username: "ada", wantErr: true},
+		{name: "length counts characters, not bytes", policy: policy, password: "ñññññññk", username: "ada"},
+		{name: "longest bcrypt accepts", policy: policy, password: strings.Repeat("x", MaxLength), username: "ada"},
+		{name: "longer than bcrypt accepts", policy: policy, password: strings.Repeat("x", MaxLength+1), username: "ada", wantErr: true},
+		{name: "common", policy: policy, password: "password123", username: "ada", wantErr: true, want: ErrCommon},
+		{name: "common in another case", policy: policy, password: "PassWord123", username: "ada", wantErr: true, want: ErrCommon},
+		{name: "common allowed when not blocked", policy: Policy{MinLength: 8}, password: "password123", username: "ada"},
+		{name: "contains username", policy: policy, password: "my-ADA-lovelace!", username: "Ada", wantErr: true, want: ErrContainsUsername},
+		{name: "short usernames are not matched", policy: policy, password: "jo-jo-music-notes", username: "jo"},
+		{name: "username allowed when not rejected", policy: Policy{MinLength: 8}, password: "my-ada-lovelace!", username: "ada"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password, tt.username)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Check() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseList(t *testing.T) {
+	entries := parseList("# comment\n\nQwerty\n  letmein  \n")
+	if len(entries) != 2 {
+		t.Errorf("parseList() has %d entries, want 2", len(entries))
+	}
+	for _, want := range []string{"qwerty", "letmein"} {
+		if _, ok := entries[want]; !ok {
+			t.Errorf("parseList() is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want error
+	}{
+		{"avatars/1/256.webp", nil},
+		{"exports/1.zip", nil},
+		{"", ErrInvalidKey},
+		{"/avatars/1.webp", ErrInvalidKey},
+		{"avatars//1.webp", ErrInvalidKey},
+		{"avatars/1/", ErrInvalidKey},
+		{"avatars/./1.webp", ErrInvalidKey},
+		{"avatars/../../etc/passwd", ErrInvalidKey},
+		{`avatars\..\1.webp`, ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		if err := validKey(tt.key); !errors.Is(err, tt.want) {
+			t.Errorf("validKey(%q) = %v, want %v", tt.key, err, tt.want)
+		}
+	}
+}
+
+func TestLocalObjects(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocal(dir, "/media/")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "avatars/1/64.webp", "image/webp", []byte("image")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got, err := s.Get(ctx, "avatars/1/64.webp"); err != nil || string(got) != "image" {
+		t.Errorf("Get() = %q, %v; want image", got, err)
+	}
+	if got := s.URL("avatars/1/64.webp"); got != "/media/avatars/1/64.webp" {
+		t.Errorf("URL() = %s, want /media/avatars/1/64.webp", got)
+	}
+
+	if err := s.Delete(ctx, "avatars/1/64.webp"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "avatars/1/64.webp"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "avatars")); !os.IsNotExist(err) {
+		t.Errorf("Delete left the emptied avatars directory behind")
+	}
+	if err := s.Delete(ctx, "avatars/1/64.webp"); err != nil {
+		t.Errorf("Delete() of a missing object = %v, want nil", err)
+	}
+}
+
+func TestLocalHandler(t *testing.T) {
+	s, err := NewLocal(t.TempDir(), "/media")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	if err := s.Put(context.Background(), "avatars/1/64.webp", "image/webp", []byte("image")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/avatars/1/64.webp", http.StatusOK},
+		{"/avatars/1/", http.StatusNotFound},
+		{"/avatars/1/128.webp", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+}
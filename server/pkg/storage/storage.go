@@ -0,0 +1,46 @@
+// Package storage stores files such as avatars and data exports on the local
+// filesystem or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys that are empty or could escape the
+// storage root
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// ErrNotFound is returned by Get when no object is stored under the key
+var ErrNotFound = errors.New("storage object not found")
+
+// Storage stores objects under slash-separated keys. Whether the objects are
+// served from public URLs depends on how the storage is deployed.
+type Storage interface {
+	// Put stores data under key, replacing any existing object. Objects are
+	// served with long-lived cache headers, so new content should be written
+	// under a new key.
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get reads the object under key
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the object under key. Deleting a missing object is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL the object under key is served from, for
+	// storage that is served publicly
+	URL(key string) string
+}
+
+// validKey rejects keys that are empty, absolute or contain dot segments
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors,
+// "12345678901234567890", in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// The RFC lists eight digit codes; six digit codes are their last six
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatalf("Code: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Code() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{"upper case", rfcSecret, false},
+		{"lower case", strings.ToLower(rfcSecret), false},
+		{"not base32", "not-a-secret!", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Code(tt.secret, Step(time.Unix(59, 0)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Code() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != "287082" {
+				t.Errorf("Code() = %s, want 287082", got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	code := func(offset int64) string {
+		c, err := Code(rfcSecret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(0), 1, step, true},
+		{"previous step within skew", code(-1), 1, step - 1, true},
+		{"next step within skew", code(1), 1, step + 1, true},
+		{"previous step without skew", code(-1), 0, 0, false},
+		{"two steps old", code(-2), 1, 0, false},
+		{"wrong code", "000000", 1, 0, false},
+		{"too short", code(0)[:5], 1, 0, false},
+		{"too long", code(0) + "0", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("Validate() = %d, %v; want %d, %v", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret: %v", err)
+	}
+	if key, err := encoding.DecodeString(secret); err != nil || len(key) != secretSize {
+		t.Errorf("NewSecret() = %q, want %d base32 bytes", secret, secretSize)
+	}
+	if _, err := Code(secret, 0); err != nil {
+		t.Errorf("Code with a new secret: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("IQ Theory", "player@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("URI is not a URL: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/IQ Theory:player@example.com" {
+		t.Errorf("URI() = %s, want otpauth://totp/IQ Theory:player@example.com", uri)
+	}
+	query := uri.Query()
+	for param, want := range map[string]string{
+		"secret": rfcSecret, "issuer": "IQ Theory", "algorithm": "SHA1", "digits": "6", "period": "30",
+	} {
+		if got := query.Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+}
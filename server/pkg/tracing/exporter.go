@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// Exporter receives finished spans
+type Exporter interface {
+	Export(span *SpanData)
+	Close() error
+}
+
+// NoopExporter discards spans
+type NoopExporter struct{}
+
+func (NoopExporter) Export(*SpanData) {}
+func (NoopExporter) Close() error     { return nil }
+
+// WriterExporter writes each span as a JSON line, which is enough to
+// inspect traces locally without a collector
+type WriterExporter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// NewStdoutExporter writes spans to standard output
+func NewStdoutExporter() *WriterExporter {
+	return &WriterExporter{enc: json.NewEncoder(os.Stdout)}
+}
+
+// NewFileExporter appends spans to a JSON-lines file
+func NewFileExporter(path string) (*WriterExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	return &WriterExporter{enc: json.NewEncoder(file), closer: file}, nil
+}
+
+// Export writes the span
+func (e *WriterExporter) Export(span *SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.enc.Encode(span); err != nil {
+		log.Printf("Failed to export span %s: %v", span.Name, err)
+	}
+}
+
+// Close closes the underlying file, if any
+func (e *WriterExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
@@ -0,0 +1,217 @@
+// Package tracing records spans around HTTP handlers, service methods and
+// SQL queries, propagates W3C trace context, and hands finished spans to a
+// pluggable exporter.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a whole trace
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID identifies a single span within a trace
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext is the part of a span that crosses process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether the trace and span IDs are non-zero
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Span is an operation being timed. A nil *Span is valid and records nothing.
+type Span struct {
+	ctx      SpanContext
+	parentID SpanID
+	name     string
+	start    time.Time
+
+	mu         sync.Mutex
+	attributes map[string]any
+	err        string
+	ended      bool
+}
+
+// SetAttribute attaches a key/value pair to the span
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil || !s.ctx.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+}
+
+// RecordError marks the span as failed. Nil errors are ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil || !s.ctx.Sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err.Error()
+}
+
+// SpanContext returns the span's propagation context
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.ctx
+}
+
+// End finishes the span and exports it if sampled. Calling End twice is a no-op.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := &SpanData{
+		Name:       s.name,
+		TraceID:    s.ctx.TraceID.String(),
+		SpanID:     s.ctx.SpanID.String(),
+		StartTime:  s.start,
+		EndTime:    time.Now(),
+		Attributes: s.attributes,
+		Error:      s.err,
+	}
+	if s.parentID != (SpanID{}) {
+		data.ParentSpanID = s.parentID.String()
+	}
+	data.DurationMs = float64(data.EndTime.Sub(data.StartTime).Microseconds()) / 1000
+	s.mu.Unlock()
+
+	if s.ctx.Sampled {
+		exporter().Export(data)
+	}
+}
+
+// SpanData is a finished span as handed to exporters
+type SpanData struct {
+	Name         string         `json:"name"`
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	StartTime    time.Time      `json:"start_time"`
+	EndTime      time.Time      `json:"end_time"`
+	DurationMs   float64        `json:"duration_ms"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	remoteKey
+)
+
+// Start begins a span as a child of the span (or remote parent) on ctx and
+// returns a context carrying the new span
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{name: name, start: time.Now()}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.ctx.TraceID = parent.ctx.TraceID
+		span.ctx.Sampled = parent.ctx.Sampled
+		span.parentID = parent.ctx.SpanID
+	} else if remote, ok := ctx.Value(remoteKey).(SpanContext); ok && remote.IsValid() {
+		span.ctx.TraceID = remote.TraceID
+		span.ctx.Sampled = remote.Sampled
+		span.parentID = remote.SpanID
+	} else {
+		rand.Read(span.ctx.TraceID[:])
+		span.ctx.Sampled = sampled()
+	}
+	rand.Read(span.ctx.SpanID[:])
+
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+// SpanFromContext returns the current span, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// WithRemoteParent makes spans started from ctx children of a span in
+// another process
+func WithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey, parent)
+}
+
+var (
+	exporterMu     sync.RWMutex
+	globalExporter Exporter = NoopExporter{}
+
+	// sampleThreshold is the sample ratio scaled to the uint64 range
+	sampleThreshold atomic.Uint64
+)
+
+func init() {
+	sampleThreshold.Store(math.MaxUint64)
+}
+
+// SetExporter installs the exporter that receives finished spans
+func SetExporter(exp Exporter) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	globalExporter = exp
+}
+
+// SetSampleRatio sets the fraction (0-1) of new traces that are recorded.
+// Traces continued from a remote parent follow the parent's decision.
+func SetSampleRatio(ratio float64) {
+	switch {
+	case ratio >= 1:
+		sampleThreshold.Store(math.MaxUint64)
+	case ratio <= 0:
+		sampleThreshold.Store(0)
+	default:
+		sampleThreshold.Store(uint64(ratio * math.MaxUint64))
+	}
+}
+
+func exporter() Exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+	return globalExporter
+}
+
+func sampled() bool {
+	threshold := sampleThreshold.Load()
+	if threshold == math.MaxUint64 {
+		return true
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint64(b[:]) < threshold
+}